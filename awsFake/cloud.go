@@ -0,0 +1,212 @@
+// Package awsFake keeps in memory the SQS queues and the SNS topics of an account, so that the
+// tests can run the code talking with AWS without credentials nor network.
+// The calls go through the usual clients of the SDK: only the transport is replaced.
+package awsFake
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Region is the region of the accounts
+const Region = "eu-west-1"
+
+// accounts counts the accounts created, every one gets its own id: the calls left running by a
+// test cannot reach the queues and the topics of the next one
+var accounts int64
+
+// Cloud is the state of the account, shared by the sessions it returns.
+// Its methods are safe for concurrent use.
+type Cloud struct {
+	account  string
+	mtx      sync.Mutex
+	changed  chan struct{}      // closed at every change, wakes up the long polls
+	offset   time.Duration      // moved forward by Advance
+	queues   map[string]*queue  // url : queue
+	topics   map[string]*topic  // arn : topic
+	handles  map[string]bool    // receipt handles given out, also of the messages deleted
+	calls    map[string]int     // operation : calls received
+	failures map[string][]error // operation : errors returned by its next calls
+}
+
+// New returns an account with no queue and no topic
+func New() *Cloud {
+	return &Cloud{
+		account:  fmt.Sprintf("%012d", atomic.AddInt64(&accounts, 1)),
+		changed:  make(chan struct{}),
+		queues:   make(map[string]*queue),
+		topics:   make(map[string]*topic),
+		handles:  make(map[string]bool),
+		calls:    make(map[string]int),
+		failures: make(map[string][]error),
+	}
+}
+
+// Account returns the id of the account, it appears in the urls and the arns
+func (c *Cloud) Account() string {
+	return c.account
+}
+
+// Session returns a session whose SQS and SNS calls are answered by c
+func (c *Cloud) Session() *session.Session {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(Region),
+		Credentials: credentials.NewStaticCredentials("AKIDFAKE", "fake", ""),
+		MaxRetries:  aws.Int(0),
+		// never sent, but the session would set up the shared default client
+		HTTPClient: &http.Client{},
+	}))
+	sess.Handlers.Send.Clear()
+	sess.Handlers.Send.PushBack(c.send)
+	return sess
+}
+
+// Advance moves the clock of c forward: the messages delayed or hidden for less than d
+// become visible
+func (c *Cloud) Advance(d time.Duration) {
+	c.mtx.Lock()
+	c.offset += d
+	c.notify()
+	c.mtx.Unlock()
+}
+
+// Fail makes the next call of the operation, e.g. "DeleteMessageBatch", return err
+func (c *Cloud) Fail(operation string, err error) {
+	c.mtx.Lock()
+	c.failures[operation] = append(c.failures[operation], err)
+	c.mtx.Unlock()
+}
+
+// Calls returns how many times the operation has been called
+func (c *Cloud) Calls(operation string) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.calls[operation]
+}
+
+// Queues returns the names of the queues, sorted
+func (c *Cloud) Queues() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var names []string
+	for _, q := range c.queues {
+		names = append(names, q.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Messages returns how many messages the queue holds, also the ones in flight or delayed
+func (c *Cloud) Messages(name string) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	q, err := c.queueByName(name)
+	if err != nil {
+		return 0
+	}
+	c.expire(q)
+	return len(q.messages)
+}
+
+// Subscriptions returns the arn of the queues subscribed to the topic, sorted
+func (c *Cloud) Subscriptions(topicARN string) []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var endpoints []string
+	if t, exists := c.topics[topicARN]; exists {
+		for _, endpoint := range t.subscriptions {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// now is the time of the account, the caller must hold the lock
+func (c *Cloud) now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// notify wakes up the long polls, the caller must hold the lock
+func (c *Cloud) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// send answers the request in place of the http call
+func (c *Cloud) send(r *request.Request) {
+	r.HTTPResponse = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Amzn-Requestid": []string{uuid.NewString()}},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+	err := c.dispatch(r)
+	if err == nil {
+		return
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() != request.CanceledErrorCode {
+		r.HTTPResponse.StatusCode = http.StatusBadRequest
+		err = awserr.NewRequestFailure(aerr, http.StatusBadRequest, r.HTTPResponse.Header.Get("X-Amzn-Requestid"))
+	}
+	r.Error = err
+	r.Retryable = aws.Bool(false)
+}
+
+func (c *Cloud) dispatch(r *request.Request) error {
+	if err := r.Context().Err(); err != nil {
+		return canceled(err)
+	}
+	c.mtx.Lock()
+	c.calls[r.Operation.Name]++
+	if errs := c.failures[r.Operation.Name]; len(errs) > 0 {
+		c.failures[r.Operation.Name] = errs[1:]
+		c.mtx.Unlock()
+		return errs[0]
+	}
+	defer c.mtx.Unlock()
+	if in, ok := r.Params.(*sqs.ReceiveMessageInput); ok {
+		// it waits for the messages, releasing the lock meanwhile
+		return c.receiveMessage(r.Context(), in, r.Data.(*sqs.ReceiveMessageOutput))
+	}
+	if err := c.sqsCall(r.Params, r.Data); err != errUnknownOperation {
+		return err
+	}
+	return c.snsCall(r.Params, r.Data)
+}
+
+var errUnknownOperation = awserr.New("InvalidAction", "operation not supported by the fake", nil)
+
+func canceled(err error) error {
+	return awserr.New(request.CanceledErrorCode, "request context canceled", err)
+}
+
+// wait waits until the state changes, the clock reaches deadline or ctx is done.
+// The caller must hold the lock, it is released while waiting.
+func (c *Cloud) wait(ctx context.Context, deadline time.Time) error {
+	changed := c.changed
+	timer := time.NewTimer(deadline.Sub(c.now()))
+	defer timer.Stop()
+	c.mtx.Unlock()
+	defer c.mtx.Lock()
+	select {
+	case <-ctx.Done():
+		return canceled(ctx.Err())
+	case <-changed:
+	case <-timer.C:
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package awsFake
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+type topic struct {
+	arn           string
+	subscriptions map[string]string // subscription arn : arn of the queue
+}
+
+// Notification is the body SNS delivers to the queues subscribed to a topic
+type Notification struct {
+	Type      string `json:"Type"`
+	MessageId string `json:"MessageId"`
+	TopicArn  string `json:"TopicArn"`
+	Subject   string `json:"Subject,omitempty"`
+	Message   string `json:"Message"`
+	Timestamp string `json:"Timestamp"`
+}
+
+// snsCall runs the sns operations, errUnknownOperation if in is not one of them.
+// The caller must hold the lock.
+func (c *Cloud) snsCall(in, out interface{}) error {
+	switch in := in.(type) {
+	case *sns.CreateTopicInput:
+		arn := "arn:aws:sns:" + Region + ":" + c.account + ":" + aws.StringValue(in.Name)
+		if _, exists := c.topics[arn]; !exists {
+			c.topics[arn] = &topic{arn: arn, subscriptions: make(map[string]string)}
+		}
+		out.(*sns.CreateTopicOutput).TopicArn = aws.String(arn)
+		return nil
+	case *sns.ListTopicsInput:
+		var arns []string
+		for arn := range c.topics {
+			arns = append(arns, arn)
+		}
+		sort.Strings(arns)
+		for _, arn := range arns {
+			out.(*sns.ListTopicsOutput).Topics = append(out.(*sns.ListTopicsOutput).Topics, &sns.Topic{TopicArn: aws.String(arn)})
+		}
+		return nil
+	case *sns.SubscribeInput:
+		t, err := c.topic(in.TopicArn)
+		if err != nil {
+			return err
+		}
+		if aws.StringValue(in.Protocol) != "sqs" {
+			return awserr.New(sns.ErrCodeInvalidParameterException, "Invalid parameter: only the sqs protocol is supported by the fake", nil)
+		}
+		endpoint := aws.StringValue(in.Endpoint)
+		for arn, queueARN := range t.subscriptions {
+			if queueARN == endpoint {
+				out.(*sns.SubscribeOutput).SubscriptionArn = aws.String(arn)
+				return nil
+			}
+		}
+		arn := t.arn + ":" + uuid.NewString()
+		t.subscriptions[arn] = endpoint
+		out.(*sns.SubscribeOutput).SubscriptionArn = aws.String(arn)
+		return nil
+	case *sns.UnsubscribeInput:
+		for _, t := range c.topics {
+			if _, exists := t.subscriptions[aws.StringValue(in.SubscriptionArn)]; exists {
+				delete(t.subscriptions, aws.StringValue(in.SubscriptionArn))
+				return nil
+			}
+		}
+		return awserr.New(sns.ErrCodeNotFoundException, "Subscription does not exist", nil)
+	case *sns.PublishInput:
+		return c.publish(in, out.(*sns.PublishOutput))
+	}
+	return errUnknownOperation
+}
+
+func (c *Cloud) topic(arn *string) (*topic, error) {
+	t, exists := c.topics[aws.StringValue(arn)]
+	if !exists {
+		return nil, awserr.New(sns.ErrCodeNotFoundException, "Topic does not exist", nil)
+	}
+	return t, nil
+}
+
+// publish delivers the message to the queues subscribed to the topic whose policy lets the
+// topic send to them
+func (c *Cloud) publish(in *sns.PublishInput, out *sns.PublishOutput) error {
+	t, err := c.topic(in.TopicArn)
+	if err != nil {
+		return err
+	}
+	n := Notification{
+		Type:      "Notification",
+		MessageId: uuid.NewString(),
+		TopicArn:  t.arn,
+		Subject:   aws.StringValue(in.Subject),
+		Message:   aws.StringValue(in.Message),
+		Timestamp: c.now().UTC().Format(time.RFC3339Nano),
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	for _, queueARN := range t.subscriptions {
+		for _, q := range c.queues {
+			if q.arn != queueARN || !strings.Contains(q.attributes["Policy"], t.arn) {
+				continue
+			}
+			if _, err = c.enqueue(q, entry{body: string(b)}); err != nil {
+				return err
+			}
+		}
+	}
+	out.MessageId = aws.String(n.MessageId)
+	return nil
+}
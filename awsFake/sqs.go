@@ -0,0 +1,578 @@
+package awsFake
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	deduplicationTime = 5 * time.Minute // how long a FIFO queue remembers a deduplication id
+	maxBatch          = 10
+)
+
+var queueName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,80}$`)
+
+// defaults of the attributes of a new queue
+var queueDefaults = map[string]string{
+	sqs.QueueAttributeNameDelaySeconds:                  "0",
+	sqs.QueueAttributeNameMaximumMessageSize:            "262144",
+	sqs.QueueAttributeNameMessageRetentionPeriod:        "345600",
+	sqs.QueueAttributeNameReceiveMessageWaitTimeSeconds: "0",
+	sqs.QueueAttributeNameVisibilityTimeout:             "30",
+}
+
+type queue struct {
+	name, url, arn string
+	attributes     map[string]string
+	tags           map[string]string
+	messages       []*message          // in the order they have been sent
+	deduplication  map[string]*message // FIFO only, deduplication id : message sent with it
+	sequence       int64
+}
+
+type message struct {
+	id, body, md5   string
+	attributes      map[string]*sqs.MessageAttributeValue
+	group, dedupID  string
+	sequence        int64
+	sent, visibleAt time.Time
+	firstReceive    time.Time
+	receiveCount    int
+	receipt         string // handle of the last receive
+}
+
+func (q *queue) fifo() bool {
+	return q.attributes[sqs.QueueAttributeNameFifoQueue] == "true"
+}
+
+func (q *queue) seconds(name string) time.Duration {
+	n, _ := strconv.Atoi(q.attributes[name])
+	return time.Duration(n) * time.Second
+}
+
+// sqsCall runs the sqs operations but ReceiveMessage, errUnknownOperation if in is not one of them.
+// The caller must hold the lock.
+func (c *Cloud) sqsCall(in, out interface{}) error {
+	switch in := in.(type) {
+	case *sqs.CreateQueueInput:
+		return c.createQueue(in, out.(*sqs.CreateQueueOutput))
+	case *sqs.GetQueueUrlInput:
+		q, err := c.queueByName(aws.StringValue(in.QueueName))
+		if err == nil {
+			out.(*sqs.GetQueueUrlOutput).QueueUrl = aws.String(q.url)
+		}
+		return err
+	case *sqs.ListQueuesInput:
+		var urls []string
+		for url, q := range c.queues {
+			if strings.HasPrefix(q.name, aws.StringValue(in.QueueNamePrefix)) {
+				urls = append(urls, url)
+			}
+		}
+		sort.Strings(urls)
+		out.(*sqs.ListQueuesOutput).QueueUrls = aws.StringSlice(urls)
+		return nil
+	case *sqs.DeleteQueueInput:
+		q, err := c.queue(in.QueueUrl)
+		if err == nil {
+			delete(c.queues, q.url)
+			c.notify()
+		}
+		return err
+	case *sqs.PurgeQueueInput:
+		q, err := c.queue(in.QueueUrl)
+		if err == nil {
+			q.messages = nil
+		}
+		return err
+	case *sqs.GetQueueAttributesInput:
+		return c.getQueueAttributes(in, out.(*sqs.GetQueueAttributesOutput))
+	case *sqs.SetQueueAttributesInput:
+		q, err := c.queue(in.QueueUrl)
+		if err != nil {
+			return err
+		}
+		for name, value := range in.Attributes {
+			q.attributes[name] = aws.StringValue(value)
+		}
+		return nil
+	case *sqs.TagQueueInput:
+		q, err := c.queue(in.QueueUrl)
+		if err != nil {
+			return err
+		}
+		for name, value := range in.Tags {
+			q.tags[name] = aws.StringValue(value)
+		}
+		return nil
+	case *sqs.ListQueueTagsInput:
+		q, err := c.queue(in.QueueUrl)
+		if err == nil && len(q.tags) > 0 {
+			out.(*sqs.ListQueueTagsOutput).Tags = aws.StringMap(q.tags)
+		}
+		return err
+	case *sqs.SendMessageInput:
+		return c.sendMessage(in, out.(*sqs.SendMessageOutput))
+	case *sqs.SendMessageBatchInput:
+		return c.sendMessageBatch(in, out.(*sqs.SendMessageBatchOutput))
+	case *sqs.DeleteMessageInput:
+		q, err := c.queue(in.QueueUrl)
+		if err != nil {
+			return err
+		}
+		return c.deleteMessage(q, aws.StringValue(in.ReceiptHandle))
+	case *sqs.DeleteMessageBatchInput:
+		return c.deleteMessageBatch(in, out.(*sqs.DeleteMessageBatchOutput))
+	case *sqs.ChangeMessageVisibilityInput:
+		q, err := c.queue(in.QueueUrl)
+		if err != nil {
+			return err
+		}
+		for _, m := range q.messages {
+			if m.receipt == aws.StringValue(in.ReceiptHandle) {
+				m.visibleAt = c.now().Add(time.Duration(aws.Int64Value(in.VisibilityTimeout)) * time.Second)
+				c.notify()
+				return nil
+			}
+		}
+		return awserr.New(sqs.ErrCodeMessageNotInflight, "the message is not in flight", nil)
+	}
+	return errUnknownOperation
+}
+
+func (c *Cloud) queue(url *string) (*queue, error) {
+	q, exists := c.queues[aws.StringValue(url)]
+	if !exists {
+		return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist for this wsdl version.", nil)
+	}
+	return q, nil
+}
+
+func (c *Cloud) queueByName(name string) (*queue, error) {
+	url := c.queueURL(name)
+	return c.queue(&url)
+}
+
+func (c *Cloud) queueURL(name string) string {
+	return "https://sqs." + Region + ".amazonaws.com/" + c.account + "/" + name
+}
+
+func invalidParameter(message string) error {
+	return awserr.New("InvalidParameterValue", message, nil)
+}
+
+func (c *Cloud) createQueue(in *sqs.CreateQueueInput, out *sqs.CreateQueueOutput) error {
+	name := aws.StringValue(in.QueueName)
+	attributes := aws.StringValueMap(in.Attributes)
+	fifo := attributes[sqs.QueueAttributeNameFifoQueue] == "true"
+	if !queueName.MatchString(strings.TrimSuffix(name, ".fifo")) || len(name) > 80 {
+		return invalidParameter("Can only include alphanumeric characters, hyphens, or underscores. 1 to 80 in length")
+	}
+	if fifo != strings.HasSuffix(name, ".fifo") {
+		return invalidParameter("The name of a FIFO queue can only include alphanumeric characters, hyphens, or underscores, must end with .fifo suffix")
+	}
+	if q, err := c.queueByName(name); err == nil {
+		for attribute, value := range attributes {
+			if q.attributes[attribute] != value {
+				return awserr.New(sqs.ErrCodeQueueNameExists, "A queue already exists with the same name and a different value for attribute "+attribute, nil)
+			}
+		}
+		out.QueueUrl = aws.String(q.url)
+		return nil
+	}
+
+	q := &queue{
+		name:          name,
+		url:           c.queueURL(name),
+		arn:           "arn:aws:sqs:" + Region + ":" + c.account + ":" + name,
+		attributes:    make(map[string]string),
+		tags:          aws.StringValueMap(in.Tags),
+		deduplication: make(map[string]*message),
+	}
+	for attribute, value := range queueDefaults {
+		q.attributes[attribute] = value
+	}
+	for attribute, value := range attributes {
+		q.attributes[attribute] = value
+	}
+	q.attributes[sqs.QueueAttributeNameCreatedTimestamp] = strconv.FormatInt(c.now().Unix(), 10)
+	c.queues[q.url] = q
+	out.QueueUrl = aws.String(q.url)
+	return nil
+}
+
+func (c *Cloud) getQueueAttributes(in *sqs.GetQueueAttributesInput, out *sqs.GetQueueAttributesOutput) error {
+	q, err := c.queue(in.QueueUrl)
+	if err != nil {
+		return err
+	}
+	c.expire(q)
+	all := map[string]string{sqs.QueueAttributeNameQueueArn: q.arn}
+	for name, value := range q.attributes {
+		all[name] = value
+	}
+	var visible, hidden, delayed int
+	now := c.now()
+	for _, m := range q.messages {
+		switch {
+		case m.receiveCount == 0 && m.visibleAt.After(now):
+			delayed++
+		case m.visibleAt.After(now):
+			hidden++
+		default:
+			visible++
+		}
+	}
+	all[sqs.QueueAttributeNameApproximateNumberOfMessages] = strconv.Itoa(visible)
+	all[sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible] = strconv.Itoa(hidden)
+	all[sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed] = strconv.Itoa(delayed)
+
+	out.Attributes = make(map[string]*string)
+	for _, name := range aws.StringValueSlice(in.AttributeNames) {
+		if name == sqs.QueueAttributeNameAll {
+			out.Attributes = aws.StringMap(all)
+			break
+		}
+		if value, exists := all[name]; exists {
+			out.Attributes[name] = aws.String(value)
+		}
+	}
+	return nil
+}
+
+// entry is what SendMessage and an entry of SendMessageBatch have in common
+type entry struct {
+	body           string
+	attributes     map[string]*sqs.MessageAttributeValue
+	delay          *int64
+	group, dedupID string
+}
+
+func (c *Cloud) sendMessage(in *sqs.SendMessageInput, out *sqs.SendMessageOutput) error {
+	q, err := c.queue(in.QueueUrl)
+	if err != nil {
+		return err
+	}
+	m, err := c.enqueue(q, entry{
+		body:       aws.StringValue(in.MessageBody),
+		attributes: in.MessageAttributes,
+		delay:      in.DelaySeconds,
+		group:      aws.StringValue(in.MessageGroupId),
+		dedupID:    aws.StringValue(in.MessageDeduplicationId),
+	})
+	if err != nil {
+		return err
+	}
+	// the checksum of the body sent, also when a duplicate is dropped
+	out.MessageId, out.MD5OfMessageBody = aws.String(m.id), aws.String(checksum(aws.StringValue(in.MessageBody)))
+	if q.fifo() {
+		out.SequenceNumber = aws.String(strconv.FormatInt(m.sequence, 10))
+	}
+	return nil
+}
+
+func (c *Cloud) sendMessageBatch(in *sqs.SendMessageBatchInput, out *sqs.SendMessageBatchOutput) error {
+	q, err := c.queue(in.QueueUrl)
+	if err != nil {
+		return err
+	}
+	if err = checkBatch(len(in.Entries), func(i int) string { return aws.StringValue(in.Entries[i].Id) }); err != nil {
+		return err
+	}
+	for _, e := range in.Entries {
+		m, err := c.enqueue(q, entry{
+			body:       aws.StringValue(e.MessageBody),
+			attributes: e.MessageAttributes,
+			delay:      e.DelaySeconds,
+			group:      aws.StringValue(e.MessageGroupId),
+			dedupID:    aws.StringValue(e.MessageDeduplicationId),
+		})
+		if err != nil {
+			out.Failed = append(out.Failed, batchError(e.Id, err))
+			continue
+		}
+		result := &sqs.SendMessageBatchResultEntry{Id: e.Id, MessageId: aws.String(m.id), MD5OfMessageBody: aws.String(checksum(aws.StringValue(e.MessageBody)))}
+		if q.fifo() {
+			result.SequenceNumber = aws.String(strconv.FormatInt(m.sequence, 10))
+		}
+		out.Successful = append(out.Successful, result)
+	}
+	return nil
+}
+
+func checkBatch(n int, id func(i int) string) error {
+	if n == 0 {
+		return awserr.New(sqs.ErrCodeEmptyBatchRequest, "There should be at least one entry in the request.", nil)
+	}
+	if n > maxBatch {
+		return awserr.New(sqs.ErrCodeTooManyEntriesInBatchRequest, "Maximum number of entries per request are 10.", nil)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		if seen[id(i)] {
+			return awserr.New(sqs.ErrCodeBatchEntryIdsNotDistinct, "Id "+id(i)+" repeated.", nil)
+		}
+		seen[id(i)] = true
+	}
+	return nil
+}
+
+func batchError(id *string, err error) *sqs.BatchResultErrorEntry {
+	code, message := "InternalError", err.Error()
+	if aerr, ok := err.(awserr.Error); ok {
+		code, message = aerr.Code(), aerr.Message()
+	}
+	return &sqs.BatchResultErrorEntry{Id: id, Code: aws.String(code), Message: aws.String(message), SenderFault: aws.Bool(true)}
+}
+
+// enqueue appends a message to q, a duplicate of a message of a FIFO queue is not appended:
+// the message sent first is returned
+func (c *Cloud) enqueue(q *queue, e entry) (*message, error) {
+	size := len(e.body)
+	for name, value := range e.attributes {
+		size += len(name) + len(aws.StringValue(value.DataType)) + len(aws.StringValue(value.StringValue)) + len(value.BinaryValue)
+	}
+	if max, _ := strconv.Atoi(q.attributes[sqs.QueueAttributeNameMaximumMessageSize]); size > max {
+		return nil, invalidParameter(fmt.Sprintf("One or more parameters are invalid. Reason: Message must be shorter than %d bytes.", max))
+	}
+	now := c.now()
+	m := &message{
+		id:         uuid.NewString(),
+		body:       e.body,
+		md5:        checksum(e.body),
+		attributes: e.attributes,
+		sent:       now,
+		visibleAt:  now.Add(q.seconds(sqs.QueueAttributeNameDelaySeconds)),
+	}
+	if q.fifo() {
+		if e.group == "" {
+			return nil, awserr.New("MissingParameter", "The request must contain the parameter MessageGroupId.", nil)
+		}
+		if e.delay != nil {
+			return nil, invalidParameter("Value " + strconv.FormatInt(*e.delay, 10) + " for parameter DelaySeconds is invalid. Reason: The request include parameter that is not valid for this queue type.")
+		}
+		m.group, m.dedupID = e.group, e.dedupID
+		if m.dedupID == "" {
+			if q.attributes[sqs.QueueAttributeNameContentBasedDeduplication] != "true" {
+				return nil, invalidParameter("The queue should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly")
+			}
+			sum := sha256.Sum256([]byte(e.body))
+			m.dedupID = hex.EncodeToString(sum[:])
+		}
+		if first, exists := q.deduplication[m.dedupID]; exists && now.Sub(first.sent) < deduplicationTime {
+			return first, nil
+		}
+		q.deduplication[m.dedupID] = m
+		q.sequence++
+		m.sequence = q.sequence
+	} else if e.delay != nil {
+		m.visibleAt = now.Add(time.Duration(*e.delay) * time.Second)
+	}
+	q.messages = append(q.messages, m)
+	c.notify()
+	return m, nil
+}
+
+func checksum(body string) string {
+	sum := md5.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// expire drops the messages older than the retention period of q
+func (c *Cloud) expire(q *queue) {
+	retention := q.seconds(sqs.QueueAttributeNameMessageRetentionPeriod)
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if c.now().Sub(m.sent) < retention {
+			kept = append(kept, m)
+		}
+	}
+	q.messages = kept
+}
+
+// receiveMessage waits up to the wait time of the request, or of the queue, for the messages.
+// The caller must hold the lock.
+func (c *Cloud) receiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, out *sqs.ReceiveMessageOutput) error {
+	q, err := c.queue(in.QueueUrl)
+	if err != nil {
+		return err
+	}
+	wait := q.seconds(sqs.QueueAttributeNameReceiveMessageWaitTimeSeconds)
+	if in.WaitTimeSeconds != nil {
+		wait = time.Duration(*in.WaitTimeSeconds) * time.Second
+	}
+	deadline := c.now().Add(wait)
+	for {
+		if q, err = c.queue(in.QueueUrl); err != nil {
+			// deleted while waiting
+			return err
+		}
+		out.Messages = c.receive(q, in)
+		if len(out.Messages) > 0 || !c.now().Before(deadline) {
+			return nil
+		}
+		if err = c.wait(ctx, deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// receive hides and returns the messages of q available, moving to the dead-letter queue the
+// ones received too many times
+func (c *Cloud) receive(q *queue, in *sqs.ReceiveMessageInput) []*sqs.Message {
+	c.expire(q)
+	max := 1
+	if in.MaxNumberOfMessages != nil {
+		max = int(*in.MaxNumberOfMessages)
+	}
+	visibility := q.seconds(sqs.QueueAttributeNameVisibilityTimeout)
+	if in.VisibilityTimeout != nil {
+		visibility = time.Duration(*in.VisibilityTimeout) * time.Second
+	}
+	deadLetters, maxReceiveCount := c.redrivePolicy(q)
+
+	now := c.now()
+	busy := make(map[string]bool) // FIFO groups with a message in flight, or skipped
+	var received []*sqs.Message
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if len(received) == max {
+			kept = append(kept, m)
+			continue
+		}
+		if m.visibleAt.After(now) || busy[m.group] {
+			if m.group != "" {
+				// the group is received in order
+				busy[m.group] = true
+			}
+			kept = append(kept, m)
+			continue
+		}
+		if deadLetters != nil && m.receiveCount >= maxReceiveCount {
+			m.visibleAt, m.receiveCount = now, 0
+			deadLetters.messages = append(deadLetters.messages, m)
+			continue
+		}
+		m.receiveCount++
+		if m.firstReceive.IsZero() {
+			m.firstReceive = now
+		}
+		m.visibleAt = now.Add(visibility)
+		m.receipt = uuid.NewString()
+		c.handles[m.receipt] = true
+		received = append(received, m.output(in))
+		kept = append(kept, m)
+	}
+	q.messages = kept
+	return received
+}
+
+// redrivePolicy returns the dead-letter queue of q and after how many receives a message goes
+// there, nil if q has none
+func (c *Cloud) redrivePolicy(q *queue) (*queue, int) {
+	var policy struct {
+		DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+		MaxReceiveCount     json.Number `json:"maxReceiveCount"`
+	}
+	if err := json.Unmarshal([]byte(q.attributes[sqs.QueueAttributeNameRedrivePolicy]), &policy); err != nil {
+		return nil, 0
+	}
+	max, err := strconv.Atoi(policy.MaxReceiveCount.String())
+	if err != nil {
+		return nil, 0
+	}
+	for _, dlq := range c.queues {
+		if dlq.arn == policy.DeadLetterTargetArn {
+			return dlq, max
+		}
+	}
+	return nil, 0
+}
+
+// output returns m as received with in
+func (m *message) output(in *sqs.ReceiveMessageInput) *sqs.Message {
+	system := map[string]string{
+		sqs.MessageSystemAttributeNameSentTimestamp:                    strconv.FormatInt(m.sent.UnixNano()/int64(time.Millisecond), 10),
+		sqs.MessageSystemAttributeNameApproximateReceiveCount:          strconv.Itoa(m.receiveCount),
+		sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp: strconv.FormatInt(m.firstReceive.UnixNano()/int64(time.Millisecond), 10),
+		sqs.MessageSystemAttributeNameSenderId:                         "AIDAFAKE",
+	}
+	if m.group != "" {
+		system[sqs.MessageSystemAttributeNameMessageGroupId] = m.group
+		system[sqs.MessageSystemAttributeNameMessageDeduplicationId] = m.dedupID
+		system[sqs.MessageSystemAttributeNameSequenceNumber] = strconv.FormatInt(m.sequence, 10)
+	}
+	out := &sqs.Message{
+		MessageId:     aws.String(m.id),
+		ReceiptHandle: aws.String(m.receipt),
+		Body:          aws.String(m.body),
+		MD5OfBody:     aws.String(m.md5),
+	}
+	for _, name := range aws.StringValueSlice(in.AttributeNames) {
+		for attribute, value := range system {
+			if name == sqs.QueueAttributeNameAll || name == attribute {
+				if out.Attributes == nil {
+					out.Attributes = make(map[string]*string)
+				}
+				out.Attributes[attribute] = aws.String(value)
+			}
+		}
+	}
+	for _, name := range aws.StringValueSlice(in.MessageAttributeNames) {
+		for attribute, value := range m.attributes {
+			if name == sqs.QueueAttributeNameAll || name == ".*" || name == attribute {
+				if out.MessageAttributes == nil {
+					out.MessageAttributes = make(map[string]*sqs.MessageAttributeValue)
+				}
+				out.MessageAttributes[attribute] = value
+			}
+		}
+	}
+	return out
+}
+
+// deleteMessage deletes the message received with handle, a handle of a message already
+// deleted is accepted
+func (c *Cloud) deleteMessage(q *queue, handle string) error {
+	for i, m := range q.messages {
+		if m.receipt == handle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+	if !c.handles[handle] {
+		return awserr.New(sqs.ErrCodeReceiptHandleIsInvalid, "The input receipt handle \""+handle+"\" is not a valid receipt handle.", nil)
+	}
+	return nil
+}
+
+func (c *Cloud) deleteMessageBatch(in *sqs.DeleteMessageBatchInput, out *sqs.DeleteMessageBatchOutput) error {
+	q, err := c.queue(in.QueueUrl)
+	if err != nil {
+		return err
+	}
+	if err = checkBatch(len(in.Entries), func(i int) string { return aws.StringValue(in.Entries[i].Id) }); err != nil {
+		return err
+	}
+	for _, e := range in.Entries {
+		if err = c.deleteMessage(q, aws.StringValue(e.ReceiptHandle)); err != nil {
+			out.Failed = append(out.Failed, batchError(e.Id, err))
+			continue
+		}
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package blobStorage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore keeps the payloads as files of a local directory, useful for tests
+// or when producers and consumers share a file system
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("the directory of the file store is missing")
+	}
+	// the references are file urls, they must not depend on the working directory
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (st *FileStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	// the keys are made of topic names: a ".." level must not lead out of the directory
+	path := filepath.Join(st.dir, filepath.FromSlash(key))
+	if !st.contains(path) {
+		return "", errors.New("the key " + key + " is outside of " + st.dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), nil
+}
+
+func (st *FileStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := st.path(ref)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+func (st *FileStore) Delete(ctx context.Context, ref string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := st.path(ref)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// path returns the file of ref, that must be inside the directory of the store
+func (st *FileStore) path(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+	if u.Scheme != "file" || !st.contains(path) {
+		return "", errors.New(ref + " is not stored in " + st.dir)
+	}
+	return path, nil
+}
+
+// contains tells whether the clean path is a file below the directory of the store
+func (st *FileStore) contains(path string) bool {
+	return strings.HasPrefix(path, st.dir+string(filepath.Separator))
+}
@@ -0,0 +1,80 @@
+package blobStorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// S3Store keeps the payloads as objects of an Amazon S3 bucket
+type S3Store struct {
+	bucket string
+	svc    *s3.S3
+}
+
+func NewS3Store(bucket string) *S3Store {
+	// Create a session that gets credential values from ~/.aws/credentials
+	// and the default region from ~/.aws/config
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	return &S3Store{bucket: bucket, svc: s3.New(sess)}
+}
+
+func (st *S3Store) Put(ctx context.Context, key string, data []byte) (string, error) {
+	_, err := st.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(st.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", err
+	}
+	return "s3://" + st.bucket + "/" + key, nil
+}
+
+func (st *S3Store) Get(ctx context.Context, ref string) ([]byte, error) {
+	key, err := st.key(ref)
+	if err != nil {
+		return nil, err
+	}
+	result, err := st.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(st.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+	return ioutil.ReadAll(result.Body)
+}
+
+func (st *S3Store) Delete(ctx context.Context, ref string) error {
+	key, err := st.key(ref)
+	if err != nil {
+		return err
+	}
+	_, err = st.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(st.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// key returns the object key of ref, that must belong to the bucket of the store
+func (st *S3Store) key(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "s3" || u.Host != st.bucket {
+		return "", errors.New(ref + " is not stored in bucket " + st.bucket)
+	}
+	return strings.TrimPrefix(u.Path, "/"), nil
+}
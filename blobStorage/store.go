@@ -0,0 +1,88 @@
+package blobStorage
+
+import (
+	"SDCC-A3-Project/envelope"
+	"context"
+	"errors"
+	"net/url"
+)
+
+// DefaultThreshold is the size of the encoded envelope above which the payload is moved to the store,
+// sqs refuses messages larger than 256 KiB (attributes included)
+const DefaultThreshold = 240 * 1024
+
+// Store keeps the payloads too large to travel inside a message.
+// A reference is an URI identifying the store and the object, e.g. s3://bucket/key or file:///dir/key;
+// Get and Delete refuse the references to objects outside the store. ctx cancels the calls.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) (ref string, err error)
+	Get(ctx context.Context, ref string) ([]byte, error)
+	Delete(ctx context.Context, ref string) error
+}
+
+// Open returns the store identified by uri, its scheme tells the kind of store
+func Open(uri string) (Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "s3":
+		return NewS3Store(u.Host), nil
+	case "file":
+		return NewFileStore(u.Path)
+	}
+	return nil, errors.New("unsupported blob store: " + uri)
+}
+
+// Offload moves the payload of e to store when the encoded envelope is larger than threshold,
+// e then carries only the reference to the payload
+func Offload(ctx context.Context, store Store, e *envelope.Envelope, threshold int) error {
+	body, _, err := e.Encode()
+	if err != nil {
+		return err
+	}
+	if len(body) <= threshold {
+		return nil
+	}
+	if store == nil {
+		return errors.New("message too large and no blob store configured")
+	}
+	ref, err := store.Put(ctx, e.Topic+"/"+e.MessageID, []byte(e.Payload))
+	if err != nil {
+		return err
+	}
+	e.PayloadRef = ref
+	e.Payload = ""
+	return nil
+}
+
+// Resolve puts back into e the payload referenced by e.PayloadRef, the reference is kept
+// so that the object can be deleted with Release once the message has been deleted.
+// The reference comes from the sender: only the objects of store, the one configured by the
+// receiver, are read.
+func Resolve(ctx context.Context, store Store, e *envelope.Envelope) error {
+	if e.PayloadRef == "" {
+		return nil
+	}
+	if store == nil {
+		return errors.New("payload stored at " + e.PayloadRef + " and no blob store configured")
+	}
+	data, err := store.Get(ctx, e.PayloadRef)
+	if err != nil {
+		return err
+	}
+	e.Payload = string(data)
+	return nil
+}
+
+// Release deletes from store the object referenced by e, if any
+func Release(ctx context.Context, store Store, e *envelope.Envelope) error {
+	if e.PayloadRef == "" {
+		return nil
+	}
+	if store == nil {
+		return errors.New("payload stored at " + e.PayloadRef + " and no blob store configured")
+	}
+	return store.Delete(ctx, e.PayloadRef)
+}
@@ -0,0 +1,217 @@
+package blobStorage
+
+import (
+	"SDCC-A3-Project/envelope"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var ctx = context.Background()
+
+func TestOffloadSmallPayload(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", "abc", "EU", "hello")
+	if err = Offload(ctx, store, e, DefaultThreshold); err != nil {
+		t.Fatalf("expected a small payload to stay in the message, got %v", err)
+	}
+	if e.PayloadRef != "" || e.Payload != "hello" {
+		t.Errorf("expected the payload in the message, got %q ref %q", e.Payload, e.PayloadRef)
+	}
+}
+
+func TestOffloadResolveRelease(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Repeat("x", 1000)
+	e := envelope.New("sensors/rome", "abc", "EU", payload)
+
+	if err = Offload(ctx, store, e, 500); err != nil {
+		t.Fatalf("expected the payload to be offloaded, got %v", err)
+	}
+	if e.PayloadRef == "" || e.Payload != "" {
+		t.Fatalf("expected only a reference in the message, got %d bytes ref %q", len(e.Payload), e.PayloadRef)
+	}
+	if err = Resolve(ctx, store, e); err != nil || e.Payload != payload {
+		t.Fatalf("expected the payload back, got %d bytes, error %v", len(e.Payload), err)
+	}
+	if err = Release(ctx, store, e); err != nil {
+		t.Fatalf("expected the object to be deleted, got %v", err)
+	}
+	if err = Resolve(ctx, store, e); err == nil {
+		t.Errorf("expected an error resolving a released payload")
+	}
+}
+
+func TestOffloadWithoutStore(t *testing.T) {
+	e := envelope.New("news", "abc", "EU", strings.Repeat("x", 1000))
+	if err := Offload(ctx, nil, e, 500); err == nil {
+		t.Errorf("expected an error offloading a large payload without a store")
+	}
+	e.PayloadRef = "s3://bucket/news/id"
+	if err := Resolve(ctx, nil, e); err == nil {
+		t.Errorf("expected an error resolving a reference without a store")
+	}
+	if err := Release(ctx, nil, e); err == nil {
+		t.Errorf("expected an error releasing a reference without a store")
+	}
+}
+
+// the reference comes from the sender: a store doesn't follow one of another store
+func TestResolveOtherStore(t *testing.T) {
+	sender, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", "abc", "EU", strings.Repeat("x", 1000))
+	if err = Offload(ctx, sender, e, 500); err != nil {
+		t.Fatal(err)
+	}
+	if err = Resolve(ctx, receiver, e); err == nil {
+		t.Errorf("expected an error resolving %s from another store", e.PayloadRef)
+	}
+	if err = Release(ctx, receiver, e); err == nil {
+		t.Errorf("expected an error releasing %s from another store", e.PayloadRef)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{"s3://bucket", true},
+		{"file://" + filepath.ToSlash(t.TempDir()), true},
+		{"file://", false},
+		{"ftp://host/dir", false},
+		{"bucket", false},
+	}
+
+	for _, test := range tests {
+		_, err := Open(test.uri)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to open, got %v", test.uri, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected an error opening %q", test.uri)
+		}
+	}
+}
+
+// a file store reads and deletes only the files of its own directory
+func TestFileStoreOutside(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{
+		"file://" + filepath.ToSlash(filepath.Join(dir, "store", "..", "other", "id")),
+		"file://" + filepath.ToSlash(filepath.Join(dir, "store2", "id")),
+		"s3://bucket/news/id",
+	} {
+		if _, err = store.Get(ctx, ref); err == nil {
+			t.Errorf("expected an error reading %s", ref)
+		}
+		if err = store.Delete(ctx, ref); err == nil {
+			t.Errorf("expected an error deleting %s", ref)
+		}
+	}
+}
+
+// a canceled context stops the operations before they touch the store
+func TestFileStoreCanceled(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", "abc", "EU", strings.Repeat("x", 1000))
+	if err = Offload(ctx, store, e, 500); err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err = store.Put(canceled, "news/id", []byte("x")); err == nil {
+		t.Errorf("expected an error storing with a canceled context")
+	}
+	if err = Release(canceled, store, e); err == nil {
+		t.Errorf("expected an error deleting with a canceled context")
+	}
+	if err = Resolve(ctx, store, e); err != nil {
+		t.Errorf("expected the object to be still there, got %v", err)
+	}
+}
+
+// a key made of topic levels cannot lead out of the directory
+func TestFileStorePutOutside(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../other/id", "news/../../other/id", "..", "", "."} {
+		if ref, err := store.Put(ctx, key, []byte("x")); err == nil {
+			t.Errorf("expected an error storing %q, got %s", key, ref)
+		}
+	}
+	ref, err := store.Put(ctx, "news/../sports/id", []byte("x"))
+	if err != nil {
+		t.Fatalf("expected a key staying inside to be stored, got %v", err)
+	}
+	if expected := "file://" + filepath.ToSlash(filepath.Join(dir, "store", "sports", "id")); ref != expected {
+		t.Errorf("expected %q, got %q", expected, ref)
+	}
+}
+
+// the references of a store opened with a relative directory still work from another directory
+func TestFileStoreRelative(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	dir := t.TempDir()
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileStore("payloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", "abc", "EU", strings.Repeat("x", 1000))
+	if err = Offload(ctx, store, e, 500); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(e.PayloadRef, "file://"+filepath.ToSlash(dir)+"/") {
+		t.Errorf("expected an absolute reference, got %s", e.PayloadRef)
+	}
+
+	if err = os.Chdir(wd); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(filepath.Join(dir, "payloads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []*FileStore{store, reopened} {
+		if err = Resolve(ctx, st, e); err != nil || len(e.Payload) != 1000 {
+			t.Errorf("expected the payload back, got %d bytes, error %v", len(e.Payload), err)
+		}
+		e.Payload = ""
+	}
+	if err = Release(ctx, store, e); err != nil {
+		t.Errorf("expected the object to be deleted, got %v", err)
+	}
+}
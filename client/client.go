@@ -0,0 +1,364 @@
+// Package client gives Go programs access to the message service: registration,
+// subscriptions, topics, sending and receiving messages.
+package client
+
+import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"sync"
+)
+
+// ErrNotRegistered is returned by the methods needing a user id before Register
+var ErrNotRegistered = errors.New("client not registered")
+
+// Client talks with the servers of the message service on behalf of one user.
+// Its methods are safe for concurrent use.
+type Client struct {
+	pool          *serverPool
+	id            string
+	zone          string
+	blobStore     blobStorage.Store
+	blobThreshold int
+	keys          payloadCodec.KeyProvider
+	topicKeys     map[string]string
+	logger        *log.Logger
+	sess          *session.Session
+
+	mtx       sync.Mutex
+	queueURLs map[string]string                  // url of the queue of the topics we used
+	settings  map[string]utilities.QueueSettings // settings of the topics we used
+}
+
+// Registration is the outcome of a subscription or a publisher registration
+type Registration struct {
+	Topic    string
+	QueueURL string                  // empty for a pattern
+	Settings utilities.QueueSettings // settings of the topic, zero for a pattern
+	Retained []*Message              // last values of the topics, subscriptions only
+}
+
+// New connects with the first reachable server and learns from it the servers alive,
+// so that the ones in the zone of the user are tried first
+func New(ctx context.Context, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.servers) == 0 {
+		return nil, errors.New("no server given")
+	}
+	c := &Client{
+		pool:          &serverPool{servers: o.servers, zone: o.zone, logger: o.logger},
+		id:            o.userID,
+		zone:          o.zone,
+		blobStore:     o.blobStore,
+		blobThreshold: o.blobThreshold,
+		keys:          o.keys,
+		topicKeys:     o.topicKeys,
+		logger:        o.logger,
+		queueURLs:     make(map[string]string),
+		settings:      make(map[string]utilities.QueueSettings),
+	}
+	c.sess = o.sess
+	if c.sess == nil {
+		sess, err := session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.sess = sess
+	}
+
+	c.pool.mtx.Lock()
+	err := c.pool.connect(ctx)
+	c.pool.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.pool.refresh(ctx); err != nil {
+		c.pool.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection with the server
+func (c *Client) Close() error {
+	return c.pool.close()
+}
+
+// ID returns the user id, empty before Register
+func (c *Client) ID() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.id
+}
+
+// Zone returns the zone of the user
+func (c *Client) Zone() string {
+	return c.zone
+}
+
+// Register asks the server for a new user id, used by the following calls
+func (c *Client) Register(ctx context.Context) (string, error) {
+	var id string
+	if err := c.pool.call(ctx, "MessageService.GenerateUserId", new(utilities.RequestArg), &id, false); err != nil {
+		return "", callError("GenerateUserId", err)
+	}
+	c.mtx.Lock()
+	c.id = id
+	c.mtx.Unlock()
+	return id, nil
+}
+
+// Subscribe subscribes to topic, or to the topics matching a pattern. The topic is created
+// if it doesn't exist yet.
+func (c *Client) Subscribe(ctx context.Context, topic string, opts ...TopicOption) (*Registration, error) {
+	return c.register(ctx, "MakeSubscriptionToTopic", topic, opts)
+}
+
+// Unsubscribe deletes the subscription to topic
+func (c *Client) Unsubscribe(ctx context.Context, topic string) error {
+	return c.release(ctx, "DeleteSubscription", topic)
+}
+
+// RegisterPublisher lets the user send to topic without receiving its messages.
+// The topic is created if it doesn't exist yet.
+func (c *Client) RegisterPublisher(ctx context.Context, topic string, opts ...TopicOption) (*Registration, error) {
+	return c.register(ctx, "RegisterPublisher", topic, opts)
+}
+
+// UnregisterPublisher undoes RegisterPublisher
+func (c *Client) UnregisterPublisher(ctx context.Context, topic string) error {
+	return c.release(ctx, "UnregisterPublisher", topic)
+}
+
+func (c *Client) register(ctx context.Context, method, topic string, opts []TopicOption) (*Registration, error) {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+	reply := new(utilities.SubscriptionOutput)
+	// not idempotent, a retry could create the topic twice
+	if err = c.pool.call(ctx, "MessageService."+method, &arg, reply, false); err != nil {
+		return nil, callError(method, err)
+	}
+	if reply.QueueURL != "" {
+		c.mtx.Lock()
+		c.queueURLs[topic] = reply.QueueURL
+		c.settings[topic] = reply.Settings
+		c.mtx.Unlock()
+	}
+	r := &Registration{Topic: topic, QueueURL: reply.QueueURL, Settings: reply.Settings}
+	for _, msg := range reply.Retained {
+		m, err := c.open(ctx, msg)
+		if err != nil {
+			c.logger.Printf("retained message %s not readable: %v", *msg.MessageId, err)
+			continue
+		}
+		r.Retained = append(r.Retained, m)
+	}
+	return r, nil
+}
+
+func (c *Client) release(ctx context.Context, method, topic string) error {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return err
+	}
+	var status int
+	if err = c.pool.call(ctx, "MessageService."+method, &arg, &status, true); err != nil {
+		return callError(method, err)
+	}
+	return nil
+}
+
+// CreateTopic creates topic, owned by the user
+func (c *Client) CreateTopic(ctx context.Context, topic string, opts ...TopicOption) (utilities.TopicInfo, error) {
+	var info utilities.TopicInfo
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return info, err
+	}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+	if err = c.pool.call(ctx, "MessageService.CreateTopic", &arg, &info, false); err != nil {
+		return info, callError("CreateTopic", err)
+	}
+	return info, nil
+}
+
+// DeleteTopic deletes topic with its queue, only its owner can
+func (c *Client) DeleteTopic(ctx context.Context, topic string) error {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return err
+	}
+	var status int
+	if err = c.pool.call(ctx, "MessageService.DeleteTopic", &arg, &status, false); err != nil {
+		return callError("DeleteTopic", err)
+	}
+	c.forget(topic)
+	return nil
+}
+
+// ListTopics returns the topics of the catalog matching pattern, all of them if empty
+func (c *Client) ListTopics(ctx context.Context, pattern string) ([]utilities.TopicInfo, error) {
+	var list []utilities.TopicInfo
+	arg := utilities.RequestArg{ID: c.ID(), Tag: pattern}
+	if err := c.pool.call(ctx, "MessageService.ListTopics", &arg, &list, true); err != nil {
+		return nil, callError("ListTopics", err)
+	}
+	return list, nil
+}
+
+// DescribeTopic returns the catalog entry of topic
+func (c *Client) DescribeTopic(ctx context.Context, topic string) (utilities.TopicInfo, error) {
+	var info utilities.TopicInfo
+	arg := utilities.RequestArg{ID: c.ID(), Tag: topic}
+	if err := c.pool.call(ctx, "MessageService.DescribeTopic", &arg, &info, true); err != nil {
+		return info, callError("DescribeTopic", err)
+	}
+	return info, nil
+}
+
+// SearchTopics returns the topics whose name or description contains query,
+// owner and deliveryMode restrict the search if not empty
+func (c *Client) SearchTopics(ctx context.Context, query, owner, deliveryMode string) ([]utilities.TopicInfo, error) {
+	var list []utilities.TopicInfo
+	arg := utilities.SearchArg{ID: c.ID(), Query: query, Owner: owner, DeliveryMode: deliveryMode}
+	if err := c.pool.call(ctx, "MessageService.SearchTopics", &arg, &list, true); err != nil {
+		return nil, callError("SearchTopics", err)
+	}
+	return list, nil
+}
+
+// TopicSettings returns the configuration of topic, asking the server only the first time
+func (c *Client) TopicSettings(ctx context.Context, topic string) (utilities.QueueSettings, error) {
+	c.mtx.Lock()
+	settings, isPresent := c.settings[topic]
+	c.mtx.Unlock()
+	if isPresent {
+		return settings, nil
+	}
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return settings, err
+	}
+	if err = c.pool.call(ctx, "MessageService.GetTopicSettings", &arg, &settings, true); err != nil {
+		return settings, callError("GetTopicSettings", err)
+	}
+	c.mtx.Lock()
+	c.settings[topic] = settings
+	c.mtx.Unlock()
+	return settings, nil
+}
+
+// QueueURL returns the url of the queue of topic, asking the server only the first time.
+// The user must be subscribed to the topic or registered as its publisher.
+func (c *Client) QueueURL(ctx context.Context, topic string) (string, error) {
+	c.mtx.Lock()
+	url, isPresent := c.queueURLs[topic]
+	c.mtx.Unlock()
+	if isPresent {
+		return url, nil
+	}
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return "", err
+	}
+	if err = c.pool.call(ctx, "MessageService.GetQueueURL", &arg, &url, true); err != nil {
+		return "", callError("GetQueueURL", err)
+	}
+	c.mtx.Lock()
+	c.queueURLs[topic] = url
+	c.mtx.Unlock()
+	return url, nil
+}
+
+// InspectDeadLetters returns the messages of topic that could not be delivered
+func (c *Client) InspectDeadLetters(ctx context.Context, topic string) (*utilities.DeadLetterOutput, error) {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return nil, err
+	}
+	reply := new(utilities.DeadLetterOutput)
+	if err = c.pool.call(ctx, "MessageService.InspectDeadLetters", &arg, reply, true); err != nil {
+		return nil, callError("InspectDeadLetters", err)
+	}
+	return reply, nil
+}
+
+// RedriveDeadLetters moves the messages that could not be delivered back to topic,
+// it returns how many have been moved
+func (c *Client) RedriveDeadLetters(ctx context.Context, topic string) (int, error) {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return 0, err
+	}
+	var moved int
+	if err = c.pool.call(ctx, "MessageService.RedriveDeadLetters", &arg, &moved, false); err != nil {
+		return moved, callError("RedriveDeadLetters", err)
+	}
+	return moved, nil
+}
+
+// PurgeDeadLetters deletes the messages of topic that could not be delivered
+func (c *Client) PurgeDeadLetters(ctx context.Context, topic string) error {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return err
+	}
+	var status int
+	if err = c.pool.call(ctx, "MessageService.PurgeDeadLetters", &arg, &status, true); err != nil {
+		return callError("PurgeDeadLetters", err)
+	}
+	return nil
+}
+
+// requestArg returns the argument of the calls about topic, the user must be registered
+func (c *Client) requestArg(topic string) (utilities.RequestArg, error) {
+	id := c.ID()
+	if id == "" {
+		return utilities.RequestArg{}, ErrNotRegistered
+	}
+	return utilities.RequestArg{ID: id, Tag: topic}, nil
+}
+
+func (c *Client) forget(topic string) {
+	c.mtx.Lock()
+	delete(c.queueURLs, topic)
+	delete(c.settings, topic)
+	c.mtx.Unlock()
+}
+
+// callError tells which call failed, the errors of the service end with a newline
+func callError(method string, err error) error {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	return fmt.Errorf("%s: %w", method, trimmedError{err})
+}
+
+type trimmedError struct{ err error }
+
+func (e trimmedError) Error() string {
+	msg := e.err.Error()
+	for len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	return msg
+}
+
+func (e trimmedError) Unwrap() error { return e.err }
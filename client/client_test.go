@@ -0,0 +1,229 @@
+package client
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/retainedLog"
+	"SDCC-A3-Project/rpcFunctions"
+	"SDCC-A3-Project/scheduler"
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+// testServer is a server of the message service listening on a local port
+type testServer struct {
+	addr    string
+	service *rpcFunctions.Service
+	l       net.Listener
+	mtx     sync.Mutex
+	conns   []net.Conn
+}
+
+// newCloud makes the servers talk with an account held in memory
+func newCloud(t *testing.T) *awsFake.Cloud {
+	cloud := awsFake.New()
+	old := utilities.NewSession
+	utilities.NewSession = cloud.Session
+	t.Cleanup(func() { utilities.NewSession = old })
+	return cloud
+}
+
+// startServer starts a server of the zone, like initServer does, until the end of the test
+func startServer(t *testing.T, zone string) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{addr: l.Addr().String(), l: l}
+	dir := t.TempDir()
+	s := new(rpcFunctions.Service)
+	s.URLQueueMap = make(map[string]string)
+	s.UsersIdMap = make(map[string][]string)
+	s.QueueSubscribersMap = make(map[string]int)
+	s.DeadLetterURLMap = make(map[string]string)
+	s.SettingsMap = make(map[string]utilities.QueueSettings)
+	s.FiltersMap = make(map[string]map[string]*messageFilter.Filter)
+	s.PatternsMap = make(map[string]int)
+	s.QueueNamesMap = make(map[string]string)
+	s.CatalogMap = make(map[string]utilities.TopicInfo)
+	s.EmptySinceMap = make(map[string]time.Time)
+	s.PublishersMap = make(map[string][]string)
+	s.MaxReceiveCount = utilities.MaxReceiveCount
+	s.Zone = zone
+	if s.Scheduler, err = scheduler.Open(filepath.Join(dir, "scheduled.json")); err != nil {
+		t.Fatal(err)
+	}
+	s.DeliveryLogs = retainedLog.NewLogs(filepath.Join(dir, "logs"))
+	if s.LastValues, err = retainedLog.OpenLastValues(filepath.Join(dir, "last-values.json")); err != nil {
+		t.Fatal(err)
+	}
+	self := utilities.ServerInfo{Zone: zone, Address: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port, StartTime: time.Now()}
+	snsManagement.SnsToSqsConfig(&s.QueueURL, &s.TopicARN, &s.SubscriptionARN, self)
+	s.Peers = membership.NewView(self)
+	ts.service = s
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		rpcFunctions.LookForMessages(ctx, s)
+		wg.Done()
+	}()
+	go func() {
+		membership.Run(ctx, s.Peers, &s.TopicARN)
+		wg.Done()
+	}()
+	server := rpc.NewServer()
+	if err = server.RegisterName("MessageService", s); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			ts.mtx.Lock()
+			ts.conns = append(ts.conns, conn)
+			ts.mtx.Unlock()
+			go server.ServeConn(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		ts.stop()
+		cancel()
+		wg.Wait()
+		s.DeliveryLogs.Close()
+	})
+	return ts
+}
+
+// stop closes the listener and the connections, as if the server crashed
+func (ts *testServer) stop() {
+	ts.l.Close()
+	ts.mtx.Lock()
+	for _, conn := range ts.conns {
+		conn.Close()
+	}
+	ts.conns = nil
+	ts.mtx.Unlock()
+}
+
+// newClient connects a new client of the zone with the servers, the first one preferred
+func newClient(t *testing.T, cloud *awsFake.Cloud, zone string, servers ...*testServer) *Client {
+	var addrs []string
+	for _, ts := range servers {
+		addrs = append(addrs, ts.addr)
+	}
+	c, err := New(ctx, WithServers(addrs...), WithZone(zone), WithSession(cloud.Session()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// eventually fails the test if cond does not become true within a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// noWait makes the receives of a topic return at once when no message is available
+var noWait = utilities.QueueSettings{ReceiveWaitTime: utilities.Int64(0)}
+
+func TestNewErrors(t *testing.T) {
+	cloud := newCloud(t)
+	if _, err := New(ctx, WithSession(cloud.Session())); err == nil {
+		t.Errorf("expected a client without servers refused")
+	}
+	// nothing listens on the port of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err = New(ctx, WithServers(l.Addr().String()), WithSession(cloud.Session())); err == nil {
+		t.Errorf("expected no server reachable")
+	}
+}
+
+func TestNotRegistered(t *testing.T) {
+	cloud := newCloud(t)
+	c := newClient(t, cloud, "eu", startServer(t, "eu"))
+	if _, err := c.Subscribe(ctx, "news"); err != ErrNotRegistered {
+		t.Errorf("subscribe: expected %v, got %v", ErrNotRegistered, err)
+	}
+	if _, err := c.Send(ctx, "news", []string{"hello"}); err != ErrNotRegistered {
+		t.Errorf("send: expected %v, got %v", ErrNotRegistered, err)
+	}
+	if _, err := c.Receive(ctx, "news", 10); err != ErrNotRegistered {
+		t.Errorf("receive: expected %v, got %v", ErrNotRegistered, err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	cloud := newCloud(t)
+	c := newClient(t, cloud, "eu", startServer(t, "eu"))
+	if _, err := c.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Subscribe(ctx, "news", WithSettings(utilities.QueueSettings{Fifo: true, ReceiveWaitTime: utilities.Int64(0)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Topic != "news" || r.QueueURL == "" || !r.Settings.Fifo {
+		t.Errorf("unexpected registration %+v", r)
+	}
+	// the errors of the service tell the call, without the newline
+	_, err = c.Subscribe(ctx, "news")
+	if err == nil || err.Error() != "MakeSubscriptionToTopic: subscription already exists" {
+		t.Errorf("expected the subscription refused, got %v", err)
+	}
+	if err = c.Unsubscribe(ctx, "news"); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := c.ListTopics(ctx, ""); err != nil || len(list) != 0 {
+		t.Errorf("expected the topic deleted with its last subscriber, got %v %v", list, err)
+	}
+}
+
+// the context of a call reaches sqs
+func TestSendCancelled(t *testing.T) {
+	cloud := newCloud(t)
+	c := newClient(t, cloud, "eu", startServer(t, "eu"))
+	if _, err := c.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Subscribe(ctx, "news", WithSettings(noWait)); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.Send(cancelled, "news", []string{"hello"}); err == nil {
+		t.Errorf("expected the send cancelled")
+	}
+	messages, err := c.Receive(ctx, "news", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("expected nothing sent, got %d messages", len(messages))
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"SDCC-A3-Project/utilities"
+	"context"
+)
+
+// RegisterCronJob has the servers of the zone publish on topic, at every time matching the
+// cron expression schedule, a message made from template. It returns the id of the job.
+// Only WithContentType and WithHeaders apply to the messages of a job. They are compressed as the
+// topic requires and encrypted with its key set by WithTopicKeys, which the servers must also hold.
+func (c *Client) RegisterCronJob(ctx context.Context, topic, schedule, template string, opts ...SendOption) (string, error) {
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	arg := utilities.CronArg{ID: c.ID(), Tag: topic, Schedule: schedule, Template: template,
+		ContentType: o.contentType, Headers: o.headers, KeyID: c.topicKeys[topic]}
+	if arg.ID == "" {
+		return "", ErrNotRegistered
+	}
+	var id string
+	if err := c.pool.call(ctx, "MessageService.RegisterCronJob", &arg, &id, false); err != nil {
+		return "", callError("RegisterCronJob", err)
+	}
+	return id, nil
+}
+
+// DeleteCronJob deletes a job registered by the user
+func (c *Client) DeleteCronJob(ctx context.Context, jobID string) error {
+	arg := utilities.CronArg{ID: c.ID(), JobID: jobID}
+	if arg.ID == "" {
+		return ErrNotRegistered
+	}
+	var status int
+	if err := c.pool.call(ctx, "MessageService.DeleteCronJob", &arg, &status, true); err != nil {
+		return callError("DeleteCronJob", err)
+	}
+	return nil
+}
+
+// ListCronJobs returns the jobs of the user publishing on topic, all of them if empty
+func (c *Client) ListCronJobs(ctx context.Context, topic string) ([]utilities.CronJob, error) {
+	arg, err := c.requestArg(topic)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []utilities.CronJob
+	if err = c.pool.call(ctx, "MessageService.ListCronJobs", &arg, &jobs, true); err != nil {
+		return nil, callError("ListCronJobs", err)
+	}
+	return jobs, nil
+}
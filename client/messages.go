@@ -0,0 +1,425 @@
+package client
+
+import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"strings"
+	"time"
+)
+
+// DefaultRequestTimeout is how long Request waits for the response when ctx has no deadline
+const DefaultRequestTimeout = 30 * time.Second
+
+const replyQueueMargin = 60 // seconds the reply queue outlives the timeout of the request
+
+// Message is a message received from a topic. Envelope is nil when the body is not an envelope,
+// in that case Body holds it as it is.
+type Message struct {
+	Envelope *envelope.Envelope `json:"envelope,omitempty"`
+	ID       string             `json:"id"` // sqs id of the message
+	Body     string             `json:"body,omitempty"`
+}
+
+// SendResult tells what became of the messages passed to Send
+type SendResult struct {
+	Messages    []*envelope.Envelope         // envelopes built from the payloads, in the same order
+	Failures    []sqsManagement.BatchFailure // messages not sent, Index refers to Messages
+	ScheduleIDs []string                     // ids of the scheduled messages, to cancel them
+}
+
+// ReplayPage is a page of the delivery log of a server
+type ReplayPage struct {
+	Messages   []*Message
+	Offsets    []int64 // offset of each message in the delivery log of the server
+	NextOffset int64   // where the next page starts
+}
+
+// Send sends a message for each payload to topic. With WithDeliverAt or WithDelay the server
+// keeps the messages and sends them when due. On a topic retaining its last value, the newest
+// message the servers receive from the topic becomes the value new subscribers receive.
+func (c *Client) Send(ctx context.Context, topic string, payloads []string, opts ...SendOption) (*SendResult, error) {
+	if topics.IsPattern(topic) {
+		return nil, errors.New("cannot send to the pattern " + topic)
+	}
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	url, err := c.QueueURL(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := c.TopicSettings(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	groupID := groupOf(url, o, c.ID())
+	var deduplicationIDs []string
+	if groupID != "" {
+		deduplicationIDs = append(deduplicationIDs, o.deduplicationIDs...)
+	}
+	result := new(SendResult)
+	for j, payload := range payloads {
+		e := c.newEnvelope(topic, payload, o)
+		if groupID != "" && j >= len(deduplicationIDs) {
+			deduplicationIDs = append(deduplicationIDs, deduplicationID(e, settings))
+		}
+		if err = payloadCodec.Pack(e, settings.Compression, c.keys, c.topicKeys[topic]); err != nil {
+			return nil, err
+		}
+		// a large payload would be as large for the server
+		if err = blobStorage.Offload(ctx, c.blobStore, e, c.blobThreshold); err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, e)
+	}
+	if len(result.Messages) == 0 {
+		return result, nil
+	}
+
+	if !o.deliverAt.IsZero() || o.delay > 0 {
+		result.ScheduleIDs, err = c.schedule(ctx, topic, result.Messages, groupID, deduplicationIDs, o)
+		return result, err
+	}
+	result.Failures, err = sqsManagement.SendMsgBatch(ctx, c.sess, &url, result.Messages, groupID, deduplicationIDs)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// deduplicationID returns the id deduplicating e, not yet packed, on a FIFO topic. The body of
+// every envelope is unique, the deduplication of sqs based on it would never find a duplicate:
+// a topic with content based deduplication deduplicates on the payload, the others only the
+// retries of the same message.
+func deduplicationID(e *envelope.Envelope, settings utilities.QueueSettings) string {
+	if !settings.ContentBasedDeduplication {
+		return e.MessageID
+	}
+	sum := sha256.Sum256([]byte(e.Payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// schedule hands the messages to the server, which sends them when due
+func (c *Client) schedule(ctx context.Context, topic string, messages []*envelope.Envelope, groupID string, deduplicationIDs []string, o sendOptions) ([]string, error) {
+	arg := utilities.ScheduleArg{ID: c.ID(), Tag: topic, GroupID: groupID, DeliverAt: o.deliverAt}
+	if o.deliverAt.IsZero() {
+		arg.Delay = int64(o.delay / time.Second)
+	}
+	var ids []string
+	for j, e := range messages {
+		arg.Message = *e
+		arg.DeduplicationID = ""
+		if j < len(deduplicationIDs) {
+			arg.DeduplicationID = deduplicationIDs[j]
+		}
+		var id string
+		// not idempotent, a retry would schedule the message twice
+		if err := c.pool.call(ctx, "MessageService.ScheduleMessage", &arg, &id, false); err != nil {
+			return ids, callError("ScheduleMessage", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CancelScheduled cancels a message scheduled on topic and not yet sent
+func (c *Client) CancelScheduled(ctx context.Context, topic, scheduleID string) error {
+	arg := utilities.ScheduleArg{ID: c.ID(), Tag: topic, ScheduleID: scheduleID}
+	if arg.ID == "" {
+		return ErrNotRegistered
+	}
+	var status int
+	if err := c.pool.call(ctx, "MessageService.CancelScheduledMessage", &arg, &status, true); err != nil {
+		return callError("CancelScheduledMessage", err)
+	}
+	return nil
+}
+
+// Receive receives and deletes up to max messages, at most 10, passing the filter of the
+// subscription to topic or to a pattern. It returns no message if none is available.
+// A message whose payload cannot be read is left in the queue.
+func (c *Client) Receive(ctx context.Context, topic string, max int, opts ...ReceiveOption) ([]*Message, error) {
+	if max > sqsManagement.MaxBatchSize {
+		max = sqsManagement.MaxBatchSize
+	}
+	arg := utilities.ReceiveArg{ID: c.ID(), Tag: topic, MaxMessages: int64(max), VisibilityTimeout: utilities.VisibilityTimeOut}
+	if arg.ID == "" {
+		return nil, ErrNotRegistered
+	}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+	// the server applies the filter
+	msgResult := new(utilities.MessagesOutput)
+	if err := c.pool.call(ctx, "MessageService.ReceiveMessages", &arg, msgResult, true); err != nil {
+		return nil, callError("ReceiveMessages", err)
+	}
+
+	// decode first, otherwise the messages return visible after the visibility timeout
+	messages := make([]*Message, len(msgResult.Messages))
+	// with a pattern the messages come from several queues
+	byQueue := make(map[string][]int)
+	for i, msg := range msgResult.Messages {
+		m, err := c.open(ctx, msg)
+		if err != nil {
+			c.logger.Printf("message %s not readable, left in the queue: %v", *msg.MessageId, err)
+			continue
+		}
+		messages[i] = m
+		byQueue[msgResult.QueueURLs[i]] = append(byQueue[msgResult.QueueURLs[i]], i)
+	}
+	for url, idx := range byQueue {
+		var handles []*string
+		for _, i := range idx {
+			handles = append(handles, msgResult.Messages[i].ReceiptHandle)
+		}
+		failures, err := sqsManagement.DeleteMsgBatch(ctx, c.sess, &url, handles)
+		if err != nil {
+			c.logger.Printf("messages of %s not deleted, they will be received again: %v", url, err)
+			for _, i := range idx {
+				messages[i] = nil
+			}
+			continue
+		}
+		for _, f := range failures {
+			i := idx[f.Index]
+			c.logger.Printf("message %s not deleted, it will be received again: %s %s", *msgResult.Messages[i].MessageId, f.Code, f.Message)
+			messages[i] = nil
+		}
+	}
+
+	var received []*Message
+	for _, m := range messages {
+		if m == nil {
+			continue
+		}
+		received = append(received, m)
+		if m.Envelope != nil && m.Envelope.PayloadRef != "" {
+			c.releasePayload(ctx, m.Envelope)
+		}
+	}
+	return received, nil
+}
+
+// releasePayload deletes the stored payload of a consumed message, unless the topic keeps it
+func (c *Client) releasePayload(ctx context.Context, e *envelope.Envelope) {
+	settings, err := c.TopicSettings(ctx, e.Topic)
+	if err != nil || settings.Retain {
+		// the log may still refer to the payload, the server deletes it when trimmed;
+		// the last value has a copy of its own
+		return
+	}
+	// nobody else can receive the message, its payload is not needed anymore
+	if err = blobStorage.Release(ctx, c.blobStore, e); err != nil {
+		c.logger.Printf("payload %s not deleted: %v", e.PayloadRef, err)
+	}
+}
+
+// Request sends payload to topic and waits for the response correlated to it, until the
+// deadline of ctx or DefaultRequestTimeout. The response arrives on a temporary reply queue.
+func (c *Client) Request(ctx context.Context, topic, payload string, opts ...SendOption) (*envelope.Envelope, error) {
+	if topics.IsPattern(topic) {
+		return nil, errors.New("cannot send to the pattern " + topic)
+	}
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	url, err := c.QueueURL(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := c.TopicSettings(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := utilities.ReplyQueueArg{ID: c.ID(), TTL: int64(time.Until(deadline)/time.Second) + replyQueueMargin}
+	queue := new(utilities.ReplyQueueOutput)
+	if err = c.pool.call(ctx, "MessageService.CreateReplyQueue", &arg, queue, false); err != nil {
+		return nil, callError("CreateReplyQueue", err)
+	}
+	defer func() {
+		// ctx may be over already
+		arg.Name = queue.Name
+		var status int
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := c.pool.call(deleteCtx, "MessageService.DeleteReplyQueue", &arg, &status, true); err != nil {
+			c.logger.Printf("reply queue %s not deleted, the server will do it: %v", queue.Name, err)
+		}
+	}()
+
+	e := c.newEnvelope(topic, payload, o)
+	e.ReplyTo = queue.QueueURL
+	if e.CorrelationID == "" {
+		e.CorrelationID = e.MessageID
+	}
+	if err = payloadCodec.Pack(e, settings.Compression, c.keys, c.topicKeys[topic]); err != nil {
+		return nil, err
+	}
+	if err = blobStorage.Offload(ctx, c.blobStore, e, c.blobThreshold); err != nil {
+		return nil, err
+	}
+	groupID := groupOf(url, o, c.ID())
+	var deduplicationIDs []string
+	if groupID != "" {
+		// two equal requests are still two requests
+		deduplicationIDs = []string{e.MessageID}
+	}
+	failures, err := sqsManagement.SendMsgBatch(ctx, c.sess, &url, []*envelope.Envelope{e}, groupID, deduplicationIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) > 0 {
+		return nil, errors.New(failures[0].Code + " " + failures[0].Message)
+	}
+
+	maxMessages := int64(sqsManagement.MaxBatchSize)
+	to := int64(utilities.VisibilityTimeOut)
+	waitTime := int64(20)
+	for {
+		result, err := sqsManagement.ReceiveMessages(ctx, c.sess, &queue.QueueURL, &maxMessages, &to, &waitTime)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range result.Messages {
+			// the queue is ours, nobody else would read the message
+			if err = sqsManagement.DeleteMessageWithContext(ctx, c.sess, &queue.QueueURL, msg.ReceiptHandle); err != nil {
+				c.logger.Printf("response %s not deleted: %v", *msg.MessageId, err)
+			}
+			response, err := c.open(ctx, msg)
+			if err != nil || response.Envelope == nil {
+				c.logger.Printf("invalid response %s: %v", *msg.MessageId, err)
+				continue
+			}
+			if response.Envelope.CorrelationID != e.CorrelationID {
+				// late response to another request
+				continue
+			}
+			if err = blobStorage.Release(ctx, c.blobStore, response.Envelope); err != nil {
+				c.logger.Printf("payload %s not deleted: %v", response.Envelope.PayloadRef, err)
+			}
+			return response.Envelope, nil
+		}
+	}
+}
+
+// Reply sends payload to the reply queue of request, with its correlation id. The reply queue
+// is chosen by the requester: it must be a reply queue of the account of the topic.
+func (c *Client) Reply(ctx context.Context, request *envelope.Envelope, payload string, opts ...SendOption) error {
+	if request.ReplyTo == "" {
+		return errors.New("message " + request.MessageID + " is not a request")
+	}
+	url, err := c.QueueURL(ctx, request.Topic)
+	if err != nil {
+		return err
+	}
+	if !topics.IsReplyQueueURL(request.ReplyTo, url) {
+		return errors.New("message " + request.MessageID + " asks a reply to " + request.ReplyTo + ", not a reply queue")
+	}
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	response := c.newEnvelope(request.Topic, payload, o)
+	response.CorrelationID = request.CorrelationID
+	if err := blobStorage.Offload(ctx, c.blobStore, response, c.blobThreshold); err != nil {
+		return err
+	}
+	failures, err := sqsManagement.SendMsgBatch(ctx, c.sess, &request.ReplyTo, []*envelope.Envelope{response}, "", nil)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return errors.New(failures[0].Code + " " + failures[0].Message)
+	}
+	return nil
+}
+
+// Replay returns up to max messages, at most utilities.MaxReplay, of the delivery log of the server
+// for topic, from offset or from since if not zero. The messages are not consumed.
+// It is not a log of the topic: a server logs only the messages it handed out, with its own
+// offsets, so the offsets of a page are meaningful only on the server that returned it.
+func (c *Client) Replay(ctx context.Context, topic string, offset int64, since time.Time, max int) (*ReplayPage, error) {
+	arg := utilities.ReplayArg{ID: c.ID(), Tag: topic, Offset: offset, Since: since, MaxMessages: int64(max)}
+	if arg.ID == "" {
+		return nil, ErrNotRegistered
+	}
+	reply := new(utilities.ReplayOutput)
+	if err := c.pool.call(ctx, "MessageService.Replay", &arg, reply, true); err != nil {
+		return nil, callError("Replay", err)
+	}
+	page := &ReplayPage{NextOffset: reply.NextOffset}
+	for i, msg := range reply.Messages {
+		m, err := c.open(ctx, msg)
+		if err != nil {
+			c.logger.Printf("message %s not readable: %v", *msg.MessageId, err)
+			continue
+		}
+		page.Messages = append(page.Messages, m)
+		page.Offsets = append(page.Offsets, reply.Offsets[i])
+	}
+	return page, nil
+}
+
+// newEnvelope wraps payload, sent by the user on topic
+func (c *Client) newEnvelope(topic, payload string, o sendOptions) *envelope.Envelope {
+	e := envelope.New(topic, c.ID(), c.zone, payload)
+	if o.contentType != "" {
+		e.ContentType = o.contentType
+	}
+	e.CorrelationID = o.correlationID
+	e.Headers = o.headers
+	return e
+}
+
+// open decodes the message and restores its original payload. A body that is not an
+// envelope is returned as it is.
+func (c *Client) open(ctx context.Context, msg *sqs.Message) (*Message, error) {
+	m := &Message{ID: *msg.MessageId}
+	e, err := envelope.Decode(msg)
+	if err != nil {
+		c.logger.Printf("message %s is not an envelope: %v", *msg.MessageId, err)
+		m.Body = *msg.Body
+		return m, nil
+	}
+	if err = blobStorage.Resolve(ctx, c.blobStore, e); err != nil {
+		return nil, err
+	}
+	if err = payloadCodec.Unpack(e, c.keys); err != nil {
+		return nil, err
+	}
+	m.Envelope = e
+	return m, nil
+}
+
+// groupOf returns the group of the messages sent to the queue at url, empty if not FIFO
+func groupOf(url string, o sendOptions, userID string) string {
+	if !strings.HasSuffix(url, ".fifo") {
+		return ""
+	}
+	if o.groupID != "" {
+		return o.groupID
+	}
+	return userID
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// payloads returns the payloads of the messages, in order
+func payloads(messages []*Message) []string {
+	var list []string
+	for _, m := range messages {
+		list = append(list, m.Envelope.Payload)
+	}
+	return list
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var deduplicationTests = []struct {
+	contentBased bool
+	sent         [][]string // payloads of each Send
+	ids          []string   // deduplication ids given to every Send
+	received     []string
+}{
+	// the payload is sent again
+	{true, [][]string{{"a", "a", "b"}, {"a"}}, nil, []string{"a", "b"}},
+	// every message is a new one
+	{false, [][]string{{"a", "a", "b"}, {"a"}}, nil, []string{"a", "a", "b", "a"}},
+	// the ids of the caller win
+	{true, [][]string{{"a", "a"}}, []string{"1", "2"}, []string{"a", "a"}},
+	{false, [][]string{{"a", "b"}, {"c", "d"}}, []string{"1", "2"}, []string{"a", "b"}},
+}
+
+func TestSendDeduplication(t *testing.T) {
+	for _, test := range deduplicationTests {
+		cloud := newCloud(t)
+		server := startServer(t, "eu")
+		c := newClient(t, cloud, "eu", server)
+		if _, err := c.Register(ctx); err != nil {
+			t.Fatal(err)
+		}
+		settings := noWait
+		settings.Fifo, settings.ContentBasedDeduplication = true, test.contentBased
+		if _, err := c.Subscribe(ctx, "orders", WithSettings(settings)); err != nil {
+			t.Fatal(err)
+		}
+		for _, sent := range test.sent {
+			result, err := c.Send(ctx, "orders", sent, WithDeduplicationIDs(test.ids...))
+			if err != nil || len(result.Failures) > 0 {
+				t.Fatalf("expected the messages sent, got %v %v", result, err)
+			}
+		}
+
+		messages, err := c.Receive(ctx, "orders", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received := payloads(messages); !equal(received, test.received) {
+			t.Errorf("content based %v, sent %v: expected %q, got %q", test.contentBased, test.sent, test.received, received)
+		}
+	}
+}
+
+// a standard topic has no deduplication
+func TestSendStandard(t *testing.T) {
+	cloud := newCloud(t)
+	server := startServer(t, "eu")
+	c := newClient(t, cloud, "eu", server)
+	if _, err := c.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Subscribe(ctx, "news", WithSettings(utilities.QueueSettings{ReceiveWaitTime: utilities.Int64(0)})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Send(ctx, "news", []string{"a", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := c.Receive(ctx, "news", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := payloads(messages); !equal(received, []string{"a", "a"}) {
+		t.Errorf("expected %q, got %q", []string{"a", "a"}, received)
+	}
+}
+
+// a request gets the response correlated to it, on a reply queue deleted afterwards
+func TestRequestReply(t *testing.T) {
+	cloud := newCloud(t)
+	server := startServer(t, "eu")
+	requester, responder := newClient(t, cloud, "eu", server), newClient(t, cloud, "eu", server)
+	for _, c := range []*Client{requester, responder} {
+		if _, err := c.Register(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := responder.Subscribe(ctx, "rpc", WithSettings(noWait)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := requester.RegisterPublisher(ctx, "rpc"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			messages, err := responder.Receive(ctx, "rpc", 1)
+			if err != nil {
+				done <- err
+				return
+			}
+			for _, m := range messages {
+				done <- responder.Reply(ctx, m.Envelope, "pong to "+m.Envelope.Payload)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	response, err := requester.Request(reqCtx, "rpc", "ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if response.Payload != "pong to ping" {
+		t.Errorf("expected %q, got %q", "pong to ping", response.Payload)
+	}
+	for _, name := range cloud.Queues() {
+		if strings.HasPrefix(name, topics.ReplyQueuePrefix("eu")) {
+			t.Errorf("expected the reply queue deleted, got %s", name)
+		}
+	}
+
+	// the reply goes only to a reply queue
+	url, err := responder.QueueURL(ctx, "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := envelope.New("rpc", requester.ID(), "eu", "ping")
+	request.ReplyTo = url
+	if err = responder.Reply(ctx, request, "pong"); err == nil {
+		t.Errorf("expected a reply to the queue of a topic refused")
+	}
+}
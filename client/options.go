@@ -0,0 +1,159 @@
+package client
+
+import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/utilities"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"io"
+	"log"
+	"time"
+)
+
+// Option configures a Client
+type Option func(*options)
+
+type options struct {
+	servers       []string
+	zone          string
+	userID        string
+	blobStore     blobStorage.Store
+	blobThreshold int
+	keys          payloadCodec.KeyProvider
+	topicKeys     map[string]string
+	logger        *log.Logger
+	sess          *session.Session
+}
+
+func defaultOptions() options {
+	return options{
+		servers:       []string{fmt.Sprintf("localhost:%d", utilities.ServerPort)},
+		zone:          utilities.Zone,
+		blobThreshold: blobStorage.DefaultThreshold,
+		logger:        log.New(io.Discard, "", 0),
+	}
+}
+
+// WithServers sets the host:port of the servers to try, in order of preference
+func WithServers(addrs ...string) Option {
+	return func(o *options) { o.servers = addrs }
+}
+
+// WithZone sets the zone of the user, its servers are preferred
+func WithZone(zone string) Option {
+	return func(o *options) { o.zone = zone }
+}
+
+// WithUserID reuses a user id got from a previous Register
+func WithUserID(id string) Option {
+	return func(o *options) { o.userID = id }
+}
+
+// WithBlobStore sends through store the payloads larger than threshold bytes. The stored
+// payloads of the messages received are read only from store, the other references are refused.
+func WithBlobStore(store blobStorage.Store, threshold int) Option {
+	return func(o *options) {
+		o.blobStore = store
+		o.blobThreshold = threshold
+	}
+}
+
+// WithKeyProvider gives the master keys of the encrypted topics
+func WithKeyProvider(keys payloadCodec.KeyProvider) Option {
+	return func(o *options) { o.keys = keys }
+}
+
+// WithTopicKeys sets the master key encrypting the messages sent on each topic,
+// the topics not listed are not encrypted
+func WithTopicKeys(keys map[string]string) Option {
+	return func(o *options) { o.topicKeys = keys }
+}
+
+// WithLogger reports what the client recovers from by itself, by default nothing is reported
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithSession sends and deletes the messages through sess, by default a session configured by
+// ~/.aws/credentials and ~/.aws/config
+func WithSession(sess *session.Session) Option {
+	return func(o *options) { o.sess = sess }
+}
+
+// TopicOption configures a topic created by Subscribe, RegisterPublisher or CreateTopic
+type TopicOption func(*utilities.RequestArg)
+
+// WithSettings sets the queue configuration, used only if the topic is created
+func WithSettings(settings utilities.QueueSettings) TopicOption {
+	return func(arg *utilities.RequestArg) { arg.Settings = &settings }
+}
+
+// WithDescription sets the description in the catalog, used only if the topic is created
+func WithDescription(description string) TopicOption {
+	return func(arg *utilities.RequestArg) { arg.Description = description }
+}
+
+// WithFilter sets the filter policy of a subscription, a JSON document
+func WithFilter(policy string) TopicOption {
+	return func(arg *utilities.RequestArg) { arg.Filter = policy }
+}
+
+// SendOption configures the messages sent by Send and Request
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	contentType      string
+	correlationID    string
+	headers          map[string]string
+	groupID          string
+	deduplicationIDs []string
+	deliverAt        time.Time
+	delay            time.Duration
+}
+
+// WithContentType sets the media type of the payloads, default text/plain
+func WithContentType(contentType string) SendOption {
+	return func(o *sendOptions) { o.contentType = contentType }
+}
+
+// WithCorrelationID ties together the messages of the same conversation
+func WithCorrelationID(id string) SendOption {
+	return func(o *sendOptions) { o.correlationID = id }
+}
+
+// WithHeaders sets application defined metadata, filter policies can match them
+func WithHeaders(headers map[string]string) SendOption {
+	return func(o *sendOptions) { o.headers = headers }
+}
+
+// WithGroupID sets the group of the messages sent on a FIFO topic, default the user id
+func WithGroupID(groupID string) SendOption {
+	return func(o *sendOptions) { o.groupID = groupID }
+}
+
+// WithDeduplicationIDs sets the deduplication id of each message sent on a FIFO topic: a message
+// with the id of one sent in the previous 5 minutes is dropped. By default the id is the hash of
+// the payload on a topic with content based deduplication, the message id on the others.
+func WithDeduplicationIDs(ids ...string) SendOption {
+	return func(o *sendOptions) { o.deduplicationIDs = ids }
+}
+
+// WithDeliverAt has the server keep the messages and deliver them at t
+func WithDeliverAt(t time.Time) SendOption {
+	return func(o *sendOptions) { o.deliverAt = t }
+}
+
+// WithDelay has the server keep the messages and deliver them after d
+func WithDelay(d time.Duration) SendOption {
+	return func(o *sendOptions) { o.delay = d }
+}
+
+// ReceiveOption configures Receive
+type ReceiveOption func(*utilities.ReceiveArg)
+
+// WithVisibilityTimeout sets how long the messages received are hidden to the other subscribers
+// before being deleted, default utilities.VisibilityTimeOut seconds
+func WithVisibilityTimeout(d time.Duration) ReceiveOption {
+	return func(arg *utilities.ReceiveArg) { arg.VisibilityTimeout = int64(d / time.Second) }
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+)
+
+// serverPool keeps the list of the known servers, the preferred ones first, and the
+// connection with the one currently in use
+type serverPool struct {
+	servers []string // host:port of the servers, in order of preference
+	zone    string   // zone of the user, its servers are preferred
+	current int      // index of the server we are connected to
+	client  *rpc.Client
+	logger  *log.Logger
+	mtx     sync.Mutex // guards the fields above, not the calls
+}
+
+// connect dials the servers starting from the current one, the first that answers is used.
+// The caller must hold the lock.
+func (p *serverPool) connect(ctx context.Context) error {
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+	var dialer net.Dialer
+	for i := 0; i < len(p.servers); i++ {
+		idx := (p.current + i) % len(p.servers)
+		conn, err := dialer.DialContext(ctx, "tcp", p.servers[idx])
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.logger.Printf("server %s unreachable: %v", p.servers[idx], err)
+			continue
+		}
+		p.current = idx
+		p.client = rpc.NewClient(conn)
+		p.logger.Println("connected with server " + p.servers[idx])
+		return nil
+	}
+	return errors.New("no server is reachable")
+}
+
+// refresh replaces the list of servers with the one known by the server in use,
+// the servers in the zone of the user come first
+func (p *serverPool) refresh(ctx context.Context) error {
+	var list []utilities.ServerInfo
+	if err := p.call(ctx, "MessageService.ListServers", new(utilities.RequestArg), &list, true); err != nil {
+		// old server or not reachable anymore, we keep the list given by the user
+		p.logger.Println("cannot retrieve the list of servers:", err)
+		return nil
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	inUse := p.servers[p.current]
+	var local, others []string
+	for i := 0; i < len(list); i++ {
+		addr := fmt.Sprintf("%s:%d", list[i].Address, list[i].Port)
+		if list[i].Zone == p.zone {
+			local = append(local, addr)
+		} else {
+			others = append(others, addr)
+		}
+	}
+	servers := append(local, others...)
+	if len(servers) == 0 {
+		return nil
+	}
+	// the server in use may know itself with another address, keep it as fallback
+	if indexOf(servers, inUse) < 0 {
+		servers = append(servers, inUse)
+	}
+	p.servers = servers
+	p.current = indexOf(servers, inUse)
+	// move to a server of our zone if we aren't already using one
+	if len(local) > 0 && indexOf(local, inUse) < 0 {
+		p.current = 0
+		return p.connect(ctx)
+	}
+	return nil
+}
+
+// call invokes serviceMethod on the server in use. When the server is unreachable the call
+// moves to the next one; a call that may have reached the server is repeated only if idempotent.
+// If ctx is done first the call is abandoned, reply may still be written afterwards.
+func (p *serverPool) call(ctx context.Context, serviceMethod string, args interface{}, reply interface{}, idempotent bool) error {
+	p.mtx.Lock()
+	attempts := len(p.servers)
+	p.mtx.Unlock()
+	for i := 0; i < attempts; i++ {
+		p.mtx.Lock()
+		if p.client == nil {
+			if err := p.connect(ctx); err != nil {
+				p.mtx.Unlock()
+				return err
+			}
+		}
+		client := p.client
+		p.mtx.Unlock()
+
+		var err error
+		call := client.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.Done:
+			err = call.Error
+		}
+		if err == nil || !isConnectionError(err) {
+			return err
+		}
+
+		p.mtx.Lock()
+		if p.client == client {
+			// nobody else has moved on yet
+			p.logger.Printf("server %s failed: %v", p.servers[p.current], err)
+			p.current = (p.current + 1) % len(p.servers)
+			err = p.connect(ctx)
+		} else {
+			err = nil
+		}
+		p.mtx.Unlock()
+		if err != nil {
+			return err
+		}
+		if !idempotent {
+			return errors.New(serviceMethod + " interrupted, it may have been executed or not")
+		}
+	}
+	return errors.New("no server is able to serve " + serviceMethod)
+}
+
+func (p *serverPool) close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.Close()
+	p.client = nil
+	return err
+}
+
+// isConnectionError tells whether err is due to the connection rather than returned by the service
+func isConnectionError(err error) bool {
+	if err == rpc.ErrShutdown || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func indexOf(a []string, x string) int {
+	for i, n := range a {
+		if x == n {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"SDCC-A3-Project/snsManagement"
+	"strings"
+	"testing"
+)
+
+// alive waits until every server knows all the others. A server learns the ones started
+// before it only from their next heartbeat, which is sent at once.
+func alive(t *testing.T, servers ...*testServer) {
+	t.Helper()
+	for _, ts := range servers {
+		snsManagement.PublishHeartbeat(ts.service.Peers.Self(), &ts.service.TopicARN)
+	}
+	for _, ts := range servers {
+		eventually(t, "the servers known by "+ts.addr, func() bool { return len(ts.service.Peers.Alive()) == len(servers) })
+	}
+}
+
+// inUse returns the address of the server the client is connected to
+func inUse(c *Client) string {
+	c.pool.mtx.Lock()
+	defer c.pool.mtx.Unlock()
+	return c.pool.servers[c.pool.current]
+}
+
+// knowsUser tells whether the server has learnt the user and its subscriptions
+func knowsUser(ts *testServer, id string, topics ...string) bool {
+	ts.service.RwMtx.RLock()
+	defer ts.service.RwMtx.RUnlock()
+	l, exists := ts.service.UsersIdMap[id]
+	return exists && equal(l, topics)
+}
+
+// the servers of the zone of the user are preferred to the ones given first
+func TestPoolPrefersZone(t *testing.T) {
+	cloud := newCloud(t)
+	america, europe := startServer(t, "us"), startServer(t, "eu")
+	alive(t, america, europe)
+
+	c := newClient(t, cloud, "eu", america, europe)
+	if addr := inUse(c); addr != europe.addr {
+		t.Errorf("expected %q, got %q", europe.addr, addr)
+	}
+}
+
+// an idempotent call moves to the next server without the caller noticing
+func TestPoolFailoverIdempotent(t *testing.T) {
+	cloud := newCloud(t)
+	first, second := startServer(t, "eu"), startServer(t, "eu")
+	alive(t, first, second)
+	c := newClient(t, cloud, "eu", first, second)
+	id, err := c.Register(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Subscribe(ctx, "news", WithSettings(noWait)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Send(ctx, "news", []string{"hello"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the subscription on the second server", func() bool { return knowsUser(second, id, "news") })
+
+	first.stop()
+	messages, err := c.Receive(ctx, "news", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := payloads(messages); !equal(received, []string{"hello"}) {
+		t.Errorf("expected %q, got %q", []string{"hello"}, received)
+	}
+	if addr := inUse(c); addr != second.addr {
+		t.Errorf("expected %q, got %q", second.addr, addr)
+	}
+}
+
+// a call that is not idempotent is not repeated: the caller learns it may have been executed
+func TestPoolFailoverNotIdempotent(t *testing.T) {
+	cloud := newCloud(t)
+	first, second := startServer(t, "eu"), startServer(t, "eu")
+	alive(t, first, second)
+	c := newClient(t, cloud, "eu", first, second)
+	if _, err := c.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	first.stop()
+	if _, err := c.Register(ctx); err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("expected the call interrupted, got %v", err)
+	}
+	// the next one goes to the second server
+	id, err := c.Register(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !knowsUser(second, id) {
+		t.Errorf("expected the user %s registered on the second server", id)
+	}
+
+	second.stop()
+	if _, err = c.ListTopics(ctx, ""); err == nil {
+		t.Errorf("expected no server able to serve the call")
+	}
+}
@@ -0,0 +1,293 @@
+package clientCli
+
+import (
+	"SDCC-A3-Project/client"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// newFlagSet returns the flags of the command name, its errors are returned and not printed
+func (sh *Shell) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parse parses the flags of the command, then checks the number of the arguments left
+func parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError(fs.Name(), err)
+	}
+	if fs.NArg() < minArgs || (maxArgs >= 0 && fs.NArg() > maxArgs) {
+		return usageError(fs.Name(), errors.New("wrong number of arguments"))
+	}
+	return nil
+}
+
+func usageError(name string, err error) error {
+	return fmt.Errorf("%v\nusage: %s %s", err, name, commands[name].usage)
+}
+
+// headerFlag collects the repeated -header key=value flags
+type headerFlag map[string]string
+
+func (h headerFlag) String() string { return "" }
+
+func (h headerFlag) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return errors.New("header must be key=value")
+	}
+	h[key] = val
+	return nil
+}
+
+func register(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("register")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	id, err := sh.Client.Register(ctx)
+	if err != nil {
+		return err
+	}
+	return sh.emit(map[string]string{"user_id": id}, func(w io.Writer) { fmt.Fprintln(w, "user ID: "+id) })
+}
+
+func subscribe(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("subscribe")
+	filter := fs.String("filter", "", "filter policy, a JSON document")
+	settingsDoc := fs.String("settings", "", "queue configuration of the topic if created, a JSON document")
+	description := fs.String("description", "", "description of the topic if created")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	var opts []client.TopicOption
+	if *filter != "" {
+		opts = append(opts, client.WithFilter(*filter))
+	}
+	if *settingsDoc != "" {
+		var settings utilities.QueueSettings
+		decoder := json.NewDecoder(bytes.NewReader([]byte(*settingsDoc)))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&settings); err != nil {
+			return errors.New("invalid settings: " + err.Error())
+		}
+		opts = append(opts, client.WithSettings(settings))
+	}
+	if *description != "" {
+		opts = append(opts, client.WithDescription(*description))
+	}
+	for _, topic := range fs.Args() {
+		r, err := sh.Client.Subscribe(ctx, topic, opts...)
+		if err != nil {
+			return err
+		}
+		err = sh.emit(r, func(w io.Writer) {
+			fmt.Fprintf(w, "subscribed to %s %s\n", topic, r.QueueURL)
+			for _, m := range r.Retained {
+				fmt.Fprintln(w, "retained message:")
+				PrintMessage(w, m)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unsubscribe(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("unsubscribe")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	for _, topic := range fs.Args() {
+		if err := sh.Client.Unsubscribe(ctx, topic); err != nil {
+			return err
+		}
+		err := sh.emit(map[string]string{"unsubscribed": topic}, func(w io.Writer) { fmt.Fprintln(w, "unsubscribed from "+topic) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func list(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("list")
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+	topics, err := sh.Client.ListTopics(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if topics == nil {
+		topics = []utilities.TopicInfo{}
+	}
+	return sh.emit(topics, func(w io.Writer) { PrintTopics(w, topics) })
+}
+
+// sendOutput is the JSON output of send
+type sendOutput struct {
+	Sent      []string `json:"sent"`                // message ids of the messages sent
+	Failed    []string `json:"failed,omitempty"`    // why the others have not been sent
+	Scheduled []string `json:"scheduled,omitempty"` // ids of the scheduled messages, to cancel them
+}
+
+func send(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("send")
+	contentType := fs.String("content-type", "", "media type of the payloads, default text/plain")
+	correlationID := fs.String("correlation-id", "", "ties together the messages of the same conversation")
+	headers := make(headerFlag)
+	fs.Var(headers, "header", "key=value header, can be repeated")
+	groupID := fs.String("group", "", "message group on a FIFO topic, default the user id")
+	delay := fs.Duration("delay", 0, "time before the delivery")
+	at := fs.String("at", "", "RFC 3339 time of delivery")
+	if err := parse(fs, args, 2, -1); err != nil {
+		return err
+	}
+	opts := []client.SendOption{
+		client.WithContentType(*contentType),
+		client.WithCorrelationID(*correlationID),
+		client.WithGroupID(*groupID),
+	}
+	if len(headers) > 0 {
+		opts = append(opts, client.WithHeaders(headers))
+	}
+	if *at != "" {
+		deliverAt, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return errors.New("invalid -at: " + err.Error())
+		}
+		opts = append(opts, client.WithDeliverAt(deliverAt))
+	} else if *delay > 0 {
+		opts = append(opts, client.WithDelay(*delay))
+	}
+
+	result, err := sh.Client.Send(ctx, fs.Arg(0), fs.Args()[1:], opts...)
+	if err != nil {
+		return err
+	}
+	out := sendOutput{Sent: []string{}, Scheduled: result.ScheduleIDs}
+	failed := make(map[int]bool)
+	for _, f := range result.Failures {
+		failed[f.Index] = true
+		out.Failed = append(out.Failed, fmt.Sprintf("%s: %s %s", result.Messages[f.Index].MessageID, f.Code, f.Message))
+	}
+	if len(result.ScheduleIDs) == 0 {
+		for i, e := range result.Messages {
+			if !failed[i] {
+				out.Sent = append(out.Sent, e.MessageID)
+			}
+		}
+	}
+	return sh.emit(out, func(w io.Writer) {
+		for i, id := range result.ScheduleIDs {
+			fmt.Fprintf(w, "message %s scheduled as %s\n", result.Messages[i].MessageID, id)
+		}
+		for _, failure := range out.Failed {
+			fmt.Fprintln(w, "not sent "+failure)
+		}
+		if len(result.ScheduleIDs) == 0 {
+			fmt.Fprintf(w, "sent %d messages to %s\n", len(out.Sent), fs.Arg(0))
+		}
+	})
+}
+
+func receive(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("receive")
+	count := fs.Int("n", 1, "number of messages to receive")
+	wait := fs.Duration("wait", 0, "how long to keep polling for messages, by default until a poll finds none")
+	visibility := fs.Duration("visibility", utilities.VisibilityTimeOut*time.Second, "how long the messages are hidden to the other subscribers before being deleted")
+	answer := fs.String("answer", "", "response to the requests received")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	var deadline time.Time
+	if *wait > 0 {
+		deadline = time.Now().Add(*wait)
+	}
+	received := 0
+	for received < *count {
+		n, err := sh.receiveOnce(ctx, fs.Arg(0), *count-received, *visibility, *answer)
+		if err != nil {
+			return err
+		}
+		received += n
+		if n > 0 {
+			continue
+		}
+		if deadline.IsZero() || time.Now().After(deadline) {
+			break
+		}
+		if !sleep(ctx, time.Second) {
+			return ctx.Err()
+		}
+	}
+	if received == 0 && !sh.JSON {
+		fmt.Fprintln(sh.Out, "no messages available")
+	}
+	return nil
+}
+
+func tail(ctx context.Context, sh *Shell, args []string) error {
+	fs := sh.newFlagSet("tail")
+	interval := fs.Duration("interval", 2*time.Second, "pause after a poll finding no message")
+	answer := fs.String("answer", "", "response to the requests received")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	for {
+		n, err := sh.receiveOnce(ctx, fs.Arg(0), sqsManagement.MaxBatchSize, utilities.VisibilityTimeOut*time.Second, *answer)
+		if ctx.Err() != nil {
+			// interrupted, the normal way to stop
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 && !sleep(ctx, *interval) {
+			return nil
+		}
+	}
+}
+
+// receiveOnce receives and prints up to max messages, answering the requests with answer if not empty
+func (sh *Shell) receiveOnce(ctx context.Context, topic string, max int, visibility time.Duration, answer string) (int, error) {
+	messages, err := sh.Client.Receive(ctx, topic, max, client.WithVisibilityTimeout(visibility))
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range messages {
+		if err = sh.emit(m, func(w io.Writer) { PrintMessage(w, m) }); err != nil {
+			return 0, err
+		}
+		if answer != "" && m.Envelope != nil && m.Envelope.ReplyTo != "" {
+			if err = sh.Client.Reply(ctx, m.Envelope, answer); err != nil {
+				sh.report(fmt.Errorf("answering request %s: %w", m.Envelope.MessageID, err))
+			}
+		}
+	}
+	return len(messages), nil
+}
+
+// sleep waits for d, it returns false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
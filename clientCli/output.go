@@ -0,0 +1,60 @@
+package clientCli
+
+import (
+	"SDCC-A3-Project/client"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/utilities"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PrintEnvelope writes e in human readable form
+func PrintEnvelope(w io.Writer, e *envelope.Envelope) {
+	fmt.Fprintln(w, "Message ID:     "+e.MessageID)
+	fmt.Fprintln(w, "Topic:          "+e.Topic)
+	fmt.Fprintln(w, "Author:         "+e.Author)
+	fmt.Fprintln(w, "Zone:           "+e.Zone)
+	fmt.Fprintln(w, "Timestamp:      "+e.Timestamp.Format(time.RFC3339Nano))
+	fmt.Fprintln(w, "Content Type:   "+e.ContentType)
+	if e.CorrelationID != "" {
+		fmt.Fprintln(w, "Correlation ID: "+e.CorrelationID)
+	}
+	if e.ReplyTo != "" {
+		fmt.Fprintln(w, "Reply To:       "+e.ReplyTo)
+	}
+	for key, value := range e.Headers {
+		fmt.Fprintln(w, "Header:", key, "=>", value)
+	}
+	fmt.Fprintln(w, "Message Body: "+e.Payload)
+}
+
+// PrintMessage writes m in human readable form, the body as it is when m is not an envelope
+func PrintMessage(w io.Writer, m *client.Message) {
+	if m.Envelope == nil {
+		fmt.Fprintln(w, "Message Body: "+m.Body)
+		return
+	}
+	PrintEnvelope(w, m.Envelope)
+}
+
+// PrintTopics writes one line for each topic, followed by its description
+func PrintTopics(w io.Writer, list []utilities.TopicInfo) {
+	for _, info := range list {
+		fmt.Fprintf(w, "%s [%s, %d subscribers, zone %s] created %s by %s\n", info.Name, info.DeliveryMode,
+			info.Subscribers, info.Zone, info.CreationTime.Format(time.RFC3339), info.Owner)
+		if info.Description != "" {
+			fmt.Fprintln(w, "    "+info.Description)
+		}
+	}
+}
+
+// emit writes v as one line of JSON in JSON mode, otherwise calls human
+func (sh *Shell) emit(v interface{}, human func(w io.Writer)) error {
+	if sh.JSON {
+		return json.NewEncoder(sh.Out).Encode(v)
+	}
+	human(sh.Out)
+	return nil
+}
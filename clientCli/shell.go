@@ -0,0 +1,171 @@
+// Package clientCli implements the subcommands of the client and its interactive mode
+package clientCli
+
+import (
+	"SDCC-A3-Project/client"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Shell runs the commands of a user against one Client, which keeps the user id
+// and the queue urls across commands
+type Shell struct {
+	Client *client.Client
+	Out    io.Writer
+	JSON   bool // output one JSON document per line instead of human readable text
+}
+
+type command struct {
+	usage string // arguments, after the name
+	help  string
+	run   func(ctx context.Context, sh *Shell, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"register":    {"", "get a new user id, used by the following commands", register},
+		"subscribe":   {"[-filter json] [-settings json] [-description text] topic...", "subscribe to topics or patterns, creating the missing topics", subscribe},
+		"unsubscribe": {"topic...", "delete subscriptions", unsubscribe},
+		"list":        {"[pattern]", "list the topics of the catalog", list},
+		"send":        {"[-content-type t] [-correlation-id id] [-header k=v]... [-group g] [-delay d | -at time] topic message...", "send a message for each argument", send},
+		"receive":     {"[-n count] [-wait duration] [-visibility duration] [-answer text] topic", "receive and delete up to count messages", receive},
+		"tail":        {"[-interval duration] [-answer text] topic", "print the messages as they arrive, until interrupted", tail},
+	}
+}
+
+// Commands returns the names of the subcommands, sorted
+func Commands() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Usage writes the subcommands with their arguments
+func Usage(w io.Writer) {
+	for _, name := range Commands() {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %s %s\n        %s\n", name, cmd.usage, cmd.help)
+	}
+}
+
+// Execute runs the subcommand args[0] with the arguments that follow
+func (sh *Shell) Execute(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	cmd, exists := commands[args[0]]
+	if !exists {
+		return errors.New("unknown command " + args[0])
+	}
+	return cmd.run(ctx, sh, args[1:])
+}
+
+// REPL reads commands from in, one for each line, until exit or the end of the input.
+// An interrupt stops the running command only. A failed command doesn't stop the REPL.
+func (sh *Shell) REPL(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for {
+		if !sh.JSON {
+			fmt.Fprint(sh.Out, "> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := splitLine(line)
+		if err != nil {
+			sh.report(err)
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			Usage(sh.Out)
+			fmt.Fprintln(sh.Out, "  id\n        print the user id\n  output human|json\n        change the output format\n  exit")
+			continue
+		case "id":
+			sh.emit(map[string]string{"user_id": sh.Client.ID()}, func(w io.Writer) { fmt.Fprintln(w, sh.Client.ID()) })
+			continue
+		case "output":
+			if len(args) != 2 || (args[1] != "human" && args[1] != "json") {
+				sh.report(errors.New("usage: output human|json"))
+				continue
+			}
+			sh.JSON = args[1] == "json"
+			continue
+		}
+		cmdCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		err = sh.Execute(cmdCtx, args)
+		stop()
+		if err != nil {
+			sh.report(err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (sh *Shell) report(err error) {
+	sh.emit(map[string]string{"error": err.Error()}, func(w io.Writer) { fmt.Fprintln(w, "error:", err) })
+}
+
+// splitLine splits a line in words separated by spaces. Quotes group words, a backslash
+// escapes the next character except within single quotes.
+func splitLine(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,81 @@
+package clientCli
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+	}{
+		{"", nil},
+		{"   \t ", nil},
+		{"  send   news\thello  ", []string{"send", "news", "hello"}},
+		{`send news "hello world"`, []string{"send", "news", "hello world"}},
+		{`send news 'it''s'`, []string{"send", "news", "its"}},
+		{`send news "it's"`, []string{"send", "news", "it's"}},
+		{`send news say" "hi`, []string{"send", "news", "say hi"}},
+		{`send news ""`, []string{"send", "news", ""}},
+		{`send news hello\ world`, []string{"send", "news", "hello world"}},
+		{`send news "a \"quoted\" word"`, []string{"send", "news", `a "quoted" word`}},
+		{`send news 'no \escape'`, []string{"send", "news", `no \escape`}},
+		{`subscribe -filter '{"region": ["EU"]}' news`, []string{"subscribe", "-filter", `{"region": ["EU"]}`, "news"}},
+	}
+
+	for _, test := range tests {
+		args, err := splitLine(test.line)
+		if err != nil {
+			t.Errorf("expected %q to be split, got %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("expected %q, got %q", test.args, args)
+		}
+	}
+}
+
+func TestSplitLineUnterminated(t *testing.T) {
+	for _, line := range []string{`send news "hello`, `send news 'hello`, `send news hello\`} {
+		if args, err := splitLine(line); err == nil {
+			t.Errorf("expected an error splitting %q, got %q", line, args)
+		}
+	}
+}
+
+// a failed line is reported and the REPL goes on, until exit
+func TestREPLGoesOnAfterErrors(t *testing.T) {
+	var out bytes.Buffer
+	sh := &Shell{Out: &out}
+	input := strings.Join([]string{
+		"# a comment",
+		"jump news",
+		`send news "hello`,
+		"output xml",
+		"output json",
+		"jump again",
+		"exit",
+		"jump never",
+	}, "\n")
+
+	if err := sh.REPL(context.Background(), strings.NewReader(input)); err != nil {
+		t.Fatalf("expected the REPL to end at exit, got %v", err)
+	}
+	if !sh.JSON {
+		t.Errorf("expected the output to be switched to JSON")
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []string{
+		"> > error: unknown command jump",
+		"> error: unterminated quote or escape",
+		"> error: usage: output human|json",
+		"> " + `{"error":"unknown command jump"}`,
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,183 @@
+package cronJobs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month and day of week.
+// Every field accepts *, numbers, ranges a-b, lists a,b and steps */n or a-b/n,
+// months and days of week accept also their first three letters (JAN, MON).
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are accepted too.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // bit i set when value i matches
+	// when both are restricted a day matches if either of them does
+	domRestricted, dowRestricted bool
+}
+
+type field struct {
+	min, max int
+	names    []string // names of the values from min on
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	// 7 is sunday as well as 0
+	dowField = field{min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// maxSearch bounds the search of the next run, an expression like "0 0 30 2 *" never matches
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// ParseSchedule parses a cron expression
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		full, exists := descriptors[strings.ToLower(expr)]
+		if !exists {
+			return nil, errors.New("unknown descriptor " + expr)
+		}
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New("a cron expression has 5 fields, got " + strconv.Itoa(len(fields)))
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+	return &s, nil
+}
+
+// parse returns the values matched by a field of the expression
+func (f field) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rangeText, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.New("invalid step in " + part)
+			}
+			rangeText, step = part[:i], n
+		}
+
+		var low, high int
+		if rangeText == "*" {
+			low, high = f.min, f.max
+		} else if i := strings.Index(rangeText, "-"); i >= 0 {
+			var err error
+			if low, err = f.value(rangeText[:i]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(rangeText[i+1:]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, errors.New("empty range " + rangeText)
+			}
+		} else {
+			var err error
+			if low, err = f.value(rangeText); err != nil {
+				return 0, err
+			}
+			high = low
+			if step > 1 {
+				// a/n means from a to the end
+				high = f.max
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name, checking its range
+func (f field) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, errors.New("invalid value " + text)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.New("value " + text + " out of range " + strconv.Itoa(f.min) + "-" + strconv.Itoa(f.max))
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matched by the schedule, in the location of t.
+// It returns the zero time if nothing matches in the next years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
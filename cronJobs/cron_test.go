@@ -0,0 +1,97 @@
+package cronJobs
+
+import (
+	"SDCC-A3-Project/utilities"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"*/15 0-6,22-23 1 JAN-mar mon-FRI",
+		"5/20 * * * 7",
+		" @Daily ",
+	}
+	for _, expr := range valid {
+		if _, err := ParseSchedule(expr); err != nil {
+			t.Errorf("expected %q to be parsed, got %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"@every 5m",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"* * * FOO *",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("expected an error parsing %q", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a monday
+	from := time.Date(2024, time.January, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, time.January, 1, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, time.January, 1, 10, 45, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2024, time.January, 2, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON", time.Date(2024, time.January, 8, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, time.January, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week
+		{"0 12 13 * FRI", time.Date(2024, time.January, 5, 12, 0, 0, 0, time.UTC)},
+		// never
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.expr)
+		if err != nil {
+			t.Fatalf("expected %q to be parsed, got %v", test.expr, err)
+		}
+		if next := s.Next(from); !next.Equal(test.next) {
+			t.Errorf("expected %q to run next at %v, got %v", test.expr, test.next, next)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	run := time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC)
+	job := utilities.CronJob{ID: "job", Topic: "news", Zone: "EU",
+		Template: `{{.JobID}} on {{.Topic}} in {{.Zone}} at {{.Time.Format "15:04"}}`}
+
+	payload, err := Render(job, run)
+	if err != nil {
+		t.Fatalf("expected the template to be rendered, got %v", err)
+	}
+	if expected := "job on news in EU at 11:00"; payload != expected {
+		t.Errorf("expected %q, got %q", expected, payload)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, template := range []string{"{{.Owner}}", "{{.JobID"} {
+		job := utilities.CronJob{ID: "job", Topic: "news", Zone: "EU", Template: template}
+		if payload, err := Render(job, time.Now()); err == nil {
+			t.Errorf("expected an error rendering %q, got %q", template, payload)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cronJobs
+
+import (
+	"SDCC-A3-Project/utilities"
+	"bytes"
+	"context"
+	"log"
+	"text/template"
+	"time"
+)
+
+const (
+	leaseDuration = 2 * time.Minute // how long a server has to publish a run before another one can try
+	leaseTTL      = 24 * time.Hour  // how long the lease of a run is remembered
+)
+
+// TemplateData is what the payload template of a job can refer to, e.g. "report of {{.Time.Format \"2006-01-02\"}}"
+type TemplateData struct {
+	JobID string
+	Topic string
+	Zone  string
+	Time  time.Time // scheduled time of the run, UTC
+}
+
+// ParseTemplate checks the payload template of a job
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("payload").Option("missingkey=error").Parse(text)
+}
+
+// Render returns the payload of the run of the job
+func Render(job utilities.CronJob, run time.Time) (string, error) {
+	t, err := ParseTemplate(job.Template)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = t.Execute(&b, TemplateData{JobID: job.ID, Topic: job.Topic, Zone: job.Zone, Time: run.UTC()})
+	return b.String(), err
+}
+
+// Run looks, every utilities.CronInterval, for the jobs of zone due to run until ctx is done.
+// All the servers of the zone run it: the one getting the lease of a run calls publish,
+// the others skip the run. A run is due from the last one published, so a run whose
+// publication failed is retried once its lease expires. After every server was down only
+// the latest of the runs missed is published.
+func Run(ctx context.Context, st *Store, zone, holder string, publish func(job utilities.CronJob, run time.Time) error) {
+	// This function must be called in a thread/goroutine
+	ticker := time.NewTicker(utilities.CronInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			now = now.UTC()
+			jobs, err := st.Jobs(zone)
+			if err != nil {
+				log.Printf("[WARNING] - cannot read the recurring publications: %v", err)
+				continue
+			}
+			for _, job := range jobs {
+				schedule, err := ParseSchedule(job.Schedule)
+				if err != nil {
+					log.Printf("[WARNING] - job %s has an invalid schedule: %v", job.ID, err)
+					continue
+				}
+				run := dueRun(schedule, job, now)
+				if run.IsZero() {
+					continue
+				}
+				acquired, err := st.Acquire(zone, job.ID, run, holder, now, leaseDuration, leaseTTL)
+				if err != nil {
+					log.Printf("[WARNING] - cannot take the lease of job %s, retrying the run of %v: %v", job.ID, run.Format(time.RFC3339), err)
+					continue
+				}
+				if !acquired {
+					// another server is publishing it, or has just done it
+					continue
+				}
+				if err = publish(job, run); err != nil {
+					log.Printf("[WARNING] - run of %v of job %s failed, retrying when its lease expires: %v", run.Format(time.RFC3339), job.ID, err)
+					continue
+				}
+				if err = st.Complete(zone, job.ID, run); err != nil {
+					// once its lease expires the run is published again
+					log.Printf("[WARNING] - cannot record the run of %v of job %s: %v", run.Format(time.RFC3339), job.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// dueRun returns the latest run of job not after now that follows the last one published,
+// zero if there is none
+func dueRun(schedule *Schedule, job utilities.CronJob, now time.Time) time.Time {
+	last := job.LastRun
+	if last.IsZero() {
+		last = job.CreationTime.UTC()
+	}
+	run := schedule.Next(last)
+	if run.IsZero() || run.After(now) {
+		return time.Time{}
+	}
+	for {
+		next := schedule.Next(run)
+		if next.IsZero() || next.After(now) {
+			return run
+		}
+		run = next
+	}
+}
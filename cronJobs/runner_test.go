@@ -0,0 +1,53 @@
+package cronJobs
+
+import (
+	"SDCC-A3-Project/utilities"
+	"testing"
+	"time"
+)
+
+func TestDueRun(t *testing.T) {
+	schedule, err := ParseSchedule("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.March, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		created, last, now, run time.Time
+	}{
+		// the first run is the one after the creation
+		{at(9, 30), time.Time{}, at(9, 59), time.Time{}},
+		{at(9, 30), time.Time{}, at(10, 0), at(10, 0)},
+		{at(9, 30), time.Time{}, at(10, 20), at(10, 0)},
+		// then the one after the last published
+		{at(9, 30), at(10, 0), at(10, 20), time.Time{}},
+		{at(9, 30), at(10, 0), at(11, 5), at(11, 0)},
+		// after a failed publication the run is still due
+		{at(9, 30), at(10, 0), at(11, 59), at(11, 0)},
+		// of the runs missed only the latest is published
+		{at(9, 30), at(10, 0), at(14, 10), at(14, 0)},
+		{at(9, 30), time.Time{}, at(14, 10), at(14, 0)},
+	}
+
+	for _, test := range tests {
+		job := utilities.CronJob{ID: "j", CreationTime: test.created, LastRun: test.last}
+		if run := dueRun(schedule, job, test.now); !run.Equal(test.run) {
+			t.Errorf("expected the run of %v at %v after %v, got %v", test.run, test.now, test.last, run)
+		}
+	}
+}
+
+// a schedule with no run left has nothing due
+func TestDueRunNever(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := utilities.CronJob{ID: "j", CreationTime: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	if run := dueRun(schedule, job, job.CreationTime.AddDate(5, 0, 0)); !run.IsZero() {
+		t.Errorf("expected no run due on February 30, got %v", run)
+	}
+}
@@ -0,0 +1,335 @@
+package cronJobs
+
+import (
+	"SDCC-A3-Project/utilities"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"strconv"
+	"time"
+)
+
+// the jobs and the leases share a DynamoDB table, so that every server of a zone
+// sees the same jobs and only one of them publishes each run
+const (
+	keyAttribute     = "Key"     // "job/<zone>/<id>" or "lease/<zone>/<id>/<run>"
+	kindAttribute    = "Kind"    // jobKind or leaseKind
+	zoneAttribute    = "Zone"    //
+	jobAttribute     = "Job"     // json of the job
+	lastRunAttribute = "LastRun" // unix time of the last run published, jobs only
+	holderAttribute  = "Holder"  // server owning the lease
+	untilAttribute   = "Until"   // unix time the lease lasts until, unless done
+	doneAttribute    = "Done"    // the run of the lease has been published
+	expiresAttribute = "Expires" // unix time, DynamoDB deletes the item afterwards
+	jobKind          = "job"
+	leaseKind        = "lease"
+	zoneIndex        = "ZoneKind" // index by zone and kind, to read the jobs of a zone without a scan
+)
+
+// Store keeps the jobs of all the zones
+type Store struct {
+	table string
+	svc   *dynamodb.DynamoDB
+}
+
+// OpenStore uses the DynamoDB table, creating it if needed
+func OpenStore(table string) (*Store, error) {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	st := &Store{table: table, svc: dynamodb.New(sess)}
+
+	description, err := st.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err == nil && !hasIndex(description.Table) {
+		// created before the index existed, the jobs are scanned until it is ready
+		_, err = st.svc.UpdateTable(&dynamodb.UpdateTableInput{
+			TableName:            aws.String(table),
+			AttributeDefinitions: zoneIndexAttributes(),
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:  aws.String(zoneIndex),
+					KeySchema:  zoneIndexKeySchema(),
+					Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				},
+			}},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+			// another server is adding it
+			err = nil
+		}
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		_, err = st.svc.CreateTable(&dynamodb.CreateTableInput{
+			TableName:   aws.String(table),
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+			AttributeDefinitions: append(zoneIndexAttributes(), &dynamodb.AttributeDefinition{
+				AttributeName: aws.String(keyAttribute),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			}),
+			KeySchema: []*dynamodb.KeySchemaElement{{
+				AttributeName: aws.String(keyAttribute),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			}},
+			GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{{
+				IndexName:  aws.String(zoneIndex),
+				KeySchema:  zoneIndexKeySchema(),
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			}},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+			// another server is creating it
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = st.svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(table)}); err != nil {
+			return nil, err
+		}
+		// the leases are useless once their run is over
+		_, err = st.svc.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(table),
+			TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+				AttributeName: aws.String(expiresAttribute),
+				Enabled:       aws.Bool(true),
+			},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Put stores the job, replacing the one with the same id
+func (st *Store) Put(job utilities.CronJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = st.svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(st.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			keyAttribute:  {S: aws.String(jobKey(job.Zone, job.ID))},
+			kindAttribute: {S: aws.String(jobKind)},
+			zoneAttribute: {S: aws.String(job.Zone)},
+			jobAttribute:  {S: aws.String(string(b))},
+		},
+	})
+	return err
+}
+
+// Get returns the job id of zone, nil if it does not exist
+func (st *Store) Get(zone, id string) (*utilities.CronJob, error) {
+	result, err := st.svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(st.table),
+		Key:            map[string]*dynamodb.AttributeValue{keyAttribute: {S: aws.String(jobKey(zone, id))}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	return decodeJob(result.Item)
+}
+
+// Delete removes the job id of zone
+func (st *Store) Delete(zone, id string) error {
+	_, err := st.svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(st.table),
+		Key:       map[string]*dynamodb.AttributeValue{keyAttribute: {S: aws.String(jobKey(zone, id))}},
+	})
+	return err
+}
+
+// Jobs returns the jobs of zone, with the time of their last run
+func (st *Store) Jobs(zone string) ([]utilities.CronJob, error) {
+	var jobs []utilities.CronJob
+	var decodeErr error
+	err := st.svc.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(st.table),
+		IndexName:              aws.String(zoneIndex),
+		KeyConditionExpression: aws.String("#zone = :zone AND #kind = :kind"),
+		ExpressionAttributeNames: map[string]*string{
+			"#kind": aws.String(kindAttribute),
+			"#zone": aws.String(zoneAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":kind": {S: aws.String(jobKind)},
+			":zone": {S: aws.String(zone)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			job, err := decodeJob(item)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			jobs = append(jobs, *job)
+		}
+		return true
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == dynamodb.ErrCodeResourceNotFoundException || aerr.Code() == "ValidationException") {
+		// the index is still being built
+		return st.scanJobs(zone)
+	}
+	if err == nil {
+		err = decodeErr
+	}
+	return jobs, err
+}
+
+// scanJobs is Jobs reading the whole table
+func (st *Store) scanJobs(zone string) ([]utilities.CronJob, error) {
+	var jobs []utilities.CronJob
+	var decodeErr error
+	err := st.svc.ScanPages(&dynamodb.ScanInput{
+		TableName:        aws.String(st.table),
+		FilterExpression: aws.String("#kind = :kind AND #zone = :zone"),
+		ExpressionAttributeNames: map[string]*string{
+			"#kind": aws.String(kindAttribute),
+			"#zone": aws.String(zoneAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":kind": {S: aws.String(jobKind)},
+			":zone": {S: aws.String(zone)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			job, err := decodeJob(item)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			jobs = append(jobs, *job)
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return jobs, err
+}
+
+// Acquire takes the lease of the run of the job for holder until now plus duration, it fails if
+// another server holds it or the run has been published. A lease not completed in time, because
+// its holder failed, can be taken again. The lease is kept for ttl, long after every server has
+// given up the run.
+func (st *Store) Acquire(zone, id string, run time.Time, holder string, now time.Time, duration, ttl time.Duration) (bool, error) {
+	_, err := st.svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(st.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			keyAttribute:     {S: aws.String(leaseKey(zone, id, run))},
+			kindAttribute:    {S: aws.String(leaseKind)},
+			zoneAttribute:    {S: aws.String(zone)},
+			holderAttribute:  {S: aws.String(holder)},
+			untilAttribute:   {N: aws.String(strconv.FormatInt(now.Add(duration).Unix(), 10))},
+			expiresAttribute: {N: aws.String(strconv.FormatInt(run.Add(ttl).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR (#until < :now AND attribute_not_exists(#done))"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":   aws.String(keyAttribute),
+			"#until": aws.String(untilAttribute),
+			"#done":  aws.String(doneAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Complete records that the run of the job has been published: its lease cannot be taken
+// anymore and the next runs are computed from it
+func (st *Store) Complete(zone, id string, run time.Time) error {
+	_, err := st.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                aws.String(st.table),
+		Key:                      map[string]*dynamodb.AttributeValue{keyAttribute: {S: aws.String(leaseKey(zone, id, run))}},
+		UpdateExpression:         aws.String("SET #done = :done"),
+		ExpressionAttributeNames: map[string]*string{"#done": aws.String(doneAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":done": {BOOL: aws.Bool(true)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = st.svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(st.table),
+		Key:              map[string]*dynamodb.AttributeValue{keyAttribute: {S: aws.String(jobKey(zone, id))}},
+		UpdateExpression: aws.String("SET #last = :run"),
+		// a deleted job is not created again, a later run is not overwritten
+		ConditionExpression: aws.String("attribute_exists(#key) AND (attribute_not_exists(#last) OR #last < :run)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":  aws.String(keyAttribute),
+			"#last": aws.String(lastRunAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":run": {N: aws.String(strconv.FormatInt(run.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+func jobKey(zone, id string) string {
+	return jobKind + "/" + zone + "/" + id
+}
+
+func leaseKey(zone, id string, run time.Time) string {
+	return leaseKind + "/" + zone + "/" + id + "/" + strconv.FormatInt(run.Unix(), 10)
+}
+
+func decodeJob(item map[string]*dynamodb.AttributeValue) (*utilities.CronJob, error) {
+	value, exists := item[jobAttribute]
+	if !exists || value.S == nil {
+		return nil, errors.New("job without definition")
+	}
+	var job utilities.CronJob
+	if err := json.Unmarshal([]byte(*value.S), &job); err != nil {
+		return nil, err
+	}
+	if last, exists := item[lastRunAttribute]; exists && last.N != nil {
+		if sec, err := strconv.ParseInt(*last.N, 10, 64); err == nil {
+			job.LastRun = time.Unix(sec, 0).UTC()
+		}
+	}
+	return &job, nil
+}
+
+func hasIndex(table *dynamodb.TableDescription) bool {
+	for _, index := range table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == zoneIndex {
+			return true
+		}
+	}
+	return false
+}
+
+func zoneIndexAttributes() []*dynamodb.AttributeDefinition {
+	return []*dynamodb.AttributeDefinition{
+		{AttributeName: aws.String(zoneAttribute), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		{AttributeName: aws.String(kindAttribute), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+	}
+}
+
+func zoneIndexKeySchema() []*dynamodb.KeySchemaElement {
+	return []*dynamodb.KeySchemaElement{
+		{AttributeName: aws.String(zoneAttribute), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		{AttributeName: aws.String(kindAttribute), KeyType: aws.String(dynamodb.KeyTypeRange)},
+	}
+}
@@ -0,0 +1,135 @@
+package envelope
+
+import (
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"strconv"
+	"time"
+)
+
+const (
+	Version            = 1            // version of the envelope written by Encode
+	DefaultContentType = "text/plain" // content type of a payload when not specified
+
+	// message attributes set on every envelope, so that they can be read without decoding the body
+	VersionAttribute     = "EnvelopeVersion"
+	AuthorAttribute      = "Author"
+	ContentTypeAttribute = "ContentType"
+	// set only when the payload is compressed or encrypted
+	ContentEncodingAttribute = "ContentEncoding"
+	EncryptionAttribute      = "Encryption"
+)
+
+// Envelope wraps the payload of a message with the information needed to route and trace it
+type Envelope struct {
+	Version       int               `json:"version"`
+	MessageID     string            `json:"message_id"`               // assigned by the producer, unlike the sqs message id it survives a redrive
+	Topic         string            `json:"topic"`                    // topic the message has been published on
+	Author        string            `json:"author"`                   // user id of the producer
+	Zone          string            `json:"zone"`                     // zone of the producer
+	Timestamp     time.Time         `json:"timestamp"`                // when the message has been created
+	ContentType   string            `json:"content_type"`             // media type of the payload
+	CorrelationID string            `json:"correlation_id,omitempty"` // ties together the messages of the same conversation
+	ReplyTo       string            `json:"reply_to,omitempty"`       // url of the queue waiting for the response of a request
+	Headers       map[string]string `json:"headers,omitempty"`        // application defined metadata
+	Payload       string            `json:"payload"`
+	PayloadRef    string            `json:"payload_ref,omitempty"` // where the payload is stored when too large for a message
+	// transformations applied to the payload, in this order
+	ContentEncoding string      `json:"content_encoding,omitempty"` // compression algorithm
+	Encryption      *Encryption `json:"encryption,omitempty"`
+}
+
+// Encryption tells how to decrypt the payload
+type Encryption struct {
+	Algorithm  string `json:"algorithm"`
+	KeyID      string `json:"key_id"`      // master key protecting the data key
+	WrappedKey string `json:"wrapped_key"` // data key encrypted with the master key, base64 encoded
+}
+
+// New returns an envelope for payload with a fresh message id and the current time
+func New(topic, author, zone, payload string) *Envelope {
+	return &Envelope{
+		Version:     Version,
+		MessageID:   shortuuid.New(),
+		Topic:       topic,
+		Author:      author,
+		Zone:        zone,
+		Timestamp:   time.Now().UTC(),
+		ContentType: DefaultContentType,
+		Payload:     payload,
+	}
+}
+
+// Encode returns the body and the message attributes of the sqs message carrying e
+func (e *Envelope) Encode() (string, map[string]*sqs.MessageAttributeValue, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", nil, err
+	}
+	attributes := map[string]*sqs.MessageAttributeValue{
+		VersionAttribute: {
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(strconv.Itoa(e.Version)),
+		},
+		AuthorAttribute: {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.Author),
+		},
+		ContentTypeAttribute: {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.ContentType),
+		},
+	}
+	if e.ContentEncoding != "" {
+		attributes[ContentEncodingAttribute] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.ContentEncoding),
+		}
+	}
+	if e.Encryption != nil {
+		attributes[EncryptionAttribute] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.Encryption.Algorithm + ":" + e.Encryption.KeyID),
+		}
+	}
+	return string(b), attributes, nil
+}
+
+// Decode returns the envelope carried by msg. A message sent without envelope is returned
+// as an envelope of version 0 holding the body as payload.
+func Decode(msg *sqs.Message) (*Envelope, error) {
+	versionAttr, exists := msg.MessageAttributes[VersionAttribute]
+	if !exists {
+		e := &Envelope{
+			MessageID:   aws.StringValue(msg.MessageId),
+			ContentType: DefaultContentType,
+			Payload:     aws.StringValue(msg.Body),
+		}
+		if author, exists := msg.MessageAttributes[AuthorAttribute]; exists {
+			e.Author = aws.StringValue(author.StringValue)
+		}
+		if sent, exists := msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]; exists {
+			if ms, err := strconv.ParseInt(aws.StringValue(sent), 10, 64); err == nil {
+				e.Timestamp = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+			}
+		}
+		return e, nil
+	}
+
+	version, err := strconv.Atoi(aws.StringValue(versionAttr.StringValue))
+	if err != nil {
+		return nil, errors.New("invalid envelope version: " + aws.StringValue(versionAttr.StringValue))
+	}
+	if version > Version {
+		return nil, errors.New(fmt.Sprintf("envelope version %d is not supported, upgrade to read it", version))
+	}
+	e := new(Envelope)
+	if err = json.Unmarshal([]byte(aws.StringValue(msg.Body)), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,101 @@
+package envelope
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	e := New("news", "abc", "EU", "hello")
+	e.ContentType = "application/json"
+	e.CorrelationID = "c1"
+	e.Headers = map[string]string{"region": "EU"}
+
+	body, attributes, err := e.Encode()
+	if err != nil {
+		t.Fatalf("expected the envelope to be encoded, got %v", err)
+	}
+	got, err := Decode(&sqs.Message{Body: aws.String(body), MessageAttributes: attributes})
+	if err != nil {
+		t.Fatalf("expected the envelope to be decoded, got %v", err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %+v, got %+v", e, got)
+	}
+}
+
+// the attributes let a consumer tell the version, the author and the content type apart
+// without decoding the body
+func TestEncodeAttributes(t *testing.T) {
+	e := New("news", "abc", "EU", "hello")
+	_, attributes, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, value string
+	}{
+		{VersionAttribute, "1"},
+		{AuthorAttribute, "abc"},
+		{ContentTypeAttribute, DefaultContentType},
+	}
+	for _, test := range tests {
+		attribute, exists := attributes[test.name]
+		if !exists {
+			t.Errorf("expected the attribute %s", test.name)
+			continue
+		}
+		if value := aws.StringValue(attribute.StringValue); value != test.value {
+			t.Errorf("expected %s %q, got %q", test.name, test.value, value)
+		}
+	}
+}
+
+// messages sent before the envelope existed are read as a payload with the sqs metadata
+func TestDecodeWithoutEnvelope(t *testing.T) {
+	msg := &sqs.Message{
+		MessageId:         aws.String("sqs-id"),
+		Body:              aws.String("raw"),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{AuthorAttribute: {StringValue: aws.String("abc")}},
+		Attributes:        map[string]*string{sqs.MessageSystemAttributeNameSentTimestamp: aws.String("1704103200000")},
+	}
+	expected := &Envelope{
+		MessageID:   "sqs-id",
+		Author:      "abc",
+		Timestamp:   time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC),
+		ContentType: DefaultContentType,
+		Payload:     "raw",
+	}
+
+	e, err := Decode(msg)
+	if err != nil {
+		t.Fatalf("expected the message to be decoded, got %v", err)
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("expected %+v, got %+v", expected, e)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	version := func(v string) map[string]*sqs.MessageAttributeValue {
+		return map[string]*sqs.MessageAttributeValue{VersionAttribute: {DataType: aws.String("Number"), StringValue: aws.String(v)}}
+	}
+	tests := []struct {
+		body    string
+		version string
+	}{
+		{"{}", "2"},   // written by a newer producer
+		{"{}", "one"}, // not a version
+		{"raw", "1"},  // not an envelope
+	}
+
+	for _, test := range tests {
+		if e, err := Decode(&sqs.Message{Body: aws.String(test.body), MessageAttributes: version(test.version)}); err == nil {
+			t.Errorf("expected an error decoding %q version %q, got %+v", test.body, test.version, e)
+		}
+	}
+}
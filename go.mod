@@ -0,0 +1,13 @@
+module SDCC-A3-Project
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/aws/aws-sdk-go v1.44.0
+	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/jmespath/go-jmespath v0.4.0 // indirect
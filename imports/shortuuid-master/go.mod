@@ -1,5 +0,0 @@
-module github.com/lithammer/shortuuid/v3
-
-require github.com/google/uuid v1.1.1
-
-go 1.13
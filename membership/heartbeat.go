@@ -0,0 +1,25 @@
+package membership
+
+import (
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"time"
+)
+
+// Run announces this server on the master topic every utilities.HeartbeatInterval
+// and drops the peers that stopped doing the same, until ctx is done.
+// This function must be called in a thread/goroutine
+func Run(ctx context.Context, v *View, topicARN *string) {
+	ticker := time.NewTicker(utilities.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		snsManagement.PublishHeartbeat(v.Self(), topicARN)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		v.Reap(utilities.PeerTimeout)
+	}
+}
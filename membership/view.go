@@ -0,0 +1,90 @@
+package membership
+
+import (
+	"SDCC-A3-Project/utilities"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// View is the list of servers this server knows to be alive, itself included
+type View struct {
+	self  utilities.ServerInfo
+	peers map[string]utilities.ServerInfo // server key : last announcement received
+	mtx   sync.RWMutex                    // to guarantee access in mutual exclusion to the map
+}
+
+// Key identifies a server as address:port
+func Key(info utilities.ServerInfo) string {
+	return fmt.Sprintf("%s:%d", info.Address, info.Port)
+}
+
+func NewView(self utilities.ServerInfo) *View {
+	v := new(View)
+	v.self = self
+	v.peers = make(map[string]utilities.ServerInfo)
+	return v
+}
+
+// Self returns the announcement of this server
+func (v *View) Self() utilities.ServerInfo {
+	return v.self
+}
+
+// Update records an heartbeat coming from another server, it tells whether the server has just
+// joined or restarted
+func (v *View) Update(info utilities.ServerInfo) bool {
+	key := Key(info)
+	if key == Key(v.self) {
+		// our own heartbeat delivered back by the master topic
+		return false
+	}
+	// local clock only, so that skewed peers are not considered dead
+	info.LastSeen = time.Now()
+
+	v.mtx.Lock()
+	old, known := v.peers[key]
+	// a restarted server has lost its state like a new one
+	joined := !known || !old.StartTime.Equal(info.StartTime)
+	if joined {
+		log.Printf("[INFO] - new server joined: %s (zone %s)", key, info.Zone)
+	}
+	v.peers[key] = info
+	v.mtx.Unlock()
+	return joined
+}
+
+// Reap removes the peers we haven't heard from for longer than timeout
+func (v *View) Reap(timeout time.Duration) {
+	v.mtx.Lock()
+	for key, info := range v.peers {
+		if time.Since(info.LastSeen) > timeout {
+			log.Printf("[WARNING] - server %s (zone %s) is not responding, removed", key, info.Zone)
+			delete(v.peers, key)
+		}
+	}
+	v.mtx.Unlock()
+}
+
+// Alive returns this server followed by the live peers, sorted by zone and address
+func (v *View) Alive() []utilities.ServerInfo {
+	v.mtx.RLock()
+	list := make([]utilities.ServerInfo, 0, len(v.peers))
+	for _, info := range v.peers {
+		list = append(list, info)
+	}
+	v.mtx.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Zone != list[j].Zone {
+			return list[i].Zone < list[j].Zone
+		}
+		return Key(list[i]) < Key(list[j])
+	})
+
+	self := v.self
+	self.LastSeen = time.Now()
+	return append([]utilities.ServerInfo{self}, list...)
+}
@@ -0,0 +1,99 @@
+package membership
+
+import (
+	"SDCC-A3-Project/utilities"
+	"testing"
+	"time"
+)
+
+var self = utilities.ServerInfo{Zone: "EU", Address: "10.0.0.1", Port: 1234}
+
+func keys(list []utilities.ServerInfo) []string {
+	var keys []string
+	for _, info := range list {
+		keys = append(keys, Key(info))
+	}
+	return keys
+}
+
+func TestAliveAlone(t *testing.T) {
+	v := NewView(self)
+	alive := v.Alive()
+	if len(alive) != 1 || Key(alive[0]) != "10.0.0.1:1234" {
+		t.Errorf("expected only this server, got %v", keys(alive))
+	}
+}
+
+func TestUpdateOwnHeartbeat(t *testing.T) {
+	v := NewView(self)
+	v.Update(self)
+	if alive := v.Alive(); len(alive) != 1 {
+		t.Errorf("expected our own heartbeat to be ignored, got %v", keys(alive))
+	}
+}
+
+// a peer joins when first heard and again when it restarts
+func TestUpdateJoined(t *testing.T) {
+	v := NewView(self)
+	start := time.Now()
+	peer := utilities.ServerInfo{Zone: "EU", Address: "10.0.0.2", Port: 1234, StartTime: start}
+	restarted := peer
+	restarted.StartTime = start.Add(time.Minute)
+
+	tests := []struct {
+		info   utilities.ServerInfo
+		joined bool
+	}{
+		{self, false},
+		{peer, true},
+		{peer, false},
+		{restarted, true},
+		{restarted, false},
+	}
+	for i, test := range tests {
+		if joined := v.Update(test.info); joined != test.joined {
+			t.Errorf("expected heartbeat %d to return %v, got %v", i, test.joined, joined)
+		}
+	}
+}
+
+func TestAliveOrder(t *testing.T) {
+	v := NewView(self)
+	for _, info := range []utilities.ServerInfo{
+		{Zone: "US", Address: "10.0.0.2", Port: 1234},
+		{Zone: "ASIA", Address: "10.0.0.9", Port: 1234},
+		{Zone: "ASIA", Address: "10.0.0.3", Port: 1234},
+		{Zone: "EU", Address: "10.0.0.1", Port: 1235},
+		{Zone: "US", Address: "10.0.0.2", Port: 1234},
+	} {
+		v.Update(info)
+	}
+
+	// this server first, then the peers by zone and address, each once
+	expected := []string{"10.0.0.1:1234", "10.0.0.3:1234", "10.0.0.9:1234", "10.0.0.1:1235", "10.0.0.2:1234"}
+	got := keys(v.Alive())
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %s at %d, got %s", expected[i], i, got[i])
+		}
+	}
+}
+
+func TestReap(t *testing.T) {
+	v := NewView(self)
+	v.Update(utilities.ServerInfo{Zone: "EU", Address: "10.0.0.2", Port: 1234})
+
+	v.Reap(time.Hour)
+	if alive := v.Alive(); len(alive) != 2 {
+		t.Errorf("expected the peer heard just now to be kept, got %v", keys(alive))
+	}
+
+	time.Sleep(time.Millisecond)
+	v.Reap(0)
+	if alive := v.Alive(); len(alive) != 1 || Key(alive[0]) != Key(self) {
+		t.Errorf("expected only this server after the timeout, got %v", keys(alive))
+	}
+}
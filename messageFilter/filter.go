@@ -0,0 +1,201 @@
+package messageFilter
+
+import (
+	"SDCC-A3-Project/envelope"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter selects messages by their attributes. It is written as an SNS filter policy:
+// a JSON object mapping each attribute to the list of accepted values, e.g.
+//
+//	{"region": ["EU"], "priority": [{"numeric": [">=", 3]}], "Author": [{"anything-but": ["abc"]}]}
+//
+// A message matches when every attribute of the policy matches at least one of its values.
+// A value is either a string, compared as is, or one of the objects
+// {"prefix": "..."}, {"anything-but": [...]}, {"numeric": [op, n, ...]} and {"exists": bool}.
+type Filter struct {
+	policy     string
+	conditions map[string][]matcher // attribute : accepted values
+}
+
+type matcher func(value string, present bool) bool
+
+// Parse compiles a filter policy, an empty policy gives a nil filter that matches everything
+func Parse(policy string) (*Filter, error) {
+	if strings.TrimSpace(policy) == "" {
+		return nil, nil
+	}
+	var raw map[string][]json.RawMessage
+	if err := json.Unmarshal([]byte(policy), &raw); err != nil {
+		return nil, errors.New("invalid filter policy: " + err.Error())
+	}
+	f := &Filter{policy: policy, conditions: make(map[string][]matcher)}
+	for attribute, values := range raw {
+		if len(values) == 0 {
+			return nil, errors.New("invalid filter policy: no value for " + attribute)
+		}
+		for _, value := range values {
+			m, err := parseValue(value)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid filter policy for %s: %v", attribute, err))
+			}
+			f.conditions[attribute] = append(f.conditions[attribute], m)
+		}
+	}
+	return f, nil
+}
+
+// String returns the policy the filter has been parsed from
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.policy
+}
+
+// Match tells whether a message with the given attributes passes the filter
+func (f *Filter) Match(attributes map[string]string) bool {
+	if f == nil {
+		return true
+	}
+	for attribute, matchers := range f.conditions {
+		value, present := attributes[attribute]
+		matched := false
+		for _, m := range matchers {
+			if m(value, present) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Attributes returns the attributes of e a filter is evaluated on: the headers together with
+// Author, Zone, Topic, ContentType and CorrelationId
+func Attributes(e *envelope.Envelope) map[string]string {
+	attributes := make(map[string]string, len(e.Headers)+5)
+	for key, value := range e.Headers {
+		attributes[key] = value
+	}
+	attributes["Author"] = e.Author
+	attributes["Zone"] = e.Zone
+	attributes["Topic"] = e.Topic
+	attributes["ContentType"] = e.ContentType
+	if e.CorrelationID != "" {
+		attributes["CorrelationId"] = e.CorrelationID
+	}
+	return attributes
+}
+
+func parseValue(raw json.RawMessage) (matcher, error) {
+	var exact string
+	if err := json.Unmarshal(raw, &exact); err == nil {
+		return func(value string, present bool) bool { return present && value == exact }, nil
+	}
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return numeric([]interface{}{"=", number})
+	}
+
+	var rule map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &rule); err != nil || len(rule) != 1 {
+		return nil, errors.New("a value must be a string, a number or an object with a single rule")
+	}
+	for name, arg := range rule {
+		switch name {
+		case "prefix":
+			var prefix string
+			if err := json.Unmarshal(arg, &prefix); err != nil {
+				return nil, errors.New("prefix wants a string")
+			}
+			return func(value string, present bool) bool { return present && strings.HasPrefix(value, prefix) }, nil
+		case "anything-but":
+			var excluded []string
+			if err := json.Unmarshal(arg, &excluded); err != nil {
+				var single string
+				if err = json.Unmarshal(arg, &single); err != nil {
+					return nil, errors.New("anything-but wants a string or a list of strings")
+				}
+				excluded = []string{single}
+			}
+			return func(value string, present bool) bool {
+				if !present {
+					return false
+				}
+				for _, x := range excluded {
+					if value == x {
+						return false
+					}
+				}
+				return true
+			}, nil
+		case "numeric":
+			var args []interface{}
+			if err := json.Unmarshal(arg, &args); err != nil {
+				return nil, errors.New("numeric wants a list of operators and numbers")
+			}
+			return numeric(args)
+		case "exists":
+			var exists bool
+			if err := json.Unmarshal(arg, &exists); err != nil {
+				return nil, errors.New("exists wants a boolean")
+			}
+			return func(value string, present bool) bool { return present == exists }, nil
+		}
+		return nil, errors.New("unknown rule " + name)
+	}
+	return nil, errors.New("empty rule")
+}
+
+// numeric builds a matcher from pairs of operator and number, e.g. [">", 0, "<=", 5]
+func numeric(args []interface{}) (matcher, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, errors.New("numeric wants pairs of operator and number")
+	}
+	type bound struct {
+		op string
+		n  float64
+	}
+	var bounds []bound
+	for i := 0; i < len(args); i += 2 {
+		op, ok := args[i].(string)
+		n, okN := args[i+1].(float64)
+		if !ok || !okN {
+			return nil, errors.New("numeric wants pairs of operator and number")
+		}
+		switch op {
+		case "=", "<", "<=", ">", ">=":
+		default:
+			return nil, errors.New("unknown numeric operator " + op)
+		}
+		bounds = append(bounds, bound{op, n})
+	}
+	return func(value string, present bool) bool {
+		if !present {
+			return false
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		for _, b := range bounds {
+			switch {
+			case b.op == "=" && v != b.n,
+				b.op == "<" && v >= b.n,
+				b.op == "<=" && v > b.n,
+				b.op == ">" && v <= b.n,
+				b.op == ">=" && v < b.n:
+				return false
+			}
+		}
+		return true
+	}, nil
+}
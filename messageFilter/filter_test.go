@@ -0,0 +1,109 @@
+package messageFilter
+
+import (
+	"SDCC-A3-Project/envelope"
+	"testing"
+)
+
+func TestParseEmpty(t *testing.T) {
+	for _, policy := range []string{"", "  \n"} {
+		f, err := Parse(policy)
+		if err != nil || f != nil {
+			t.Errorf("expected no filter for %q, got %v, %v", policy, f, err)
+		}
+		if !f.Match(nil) {
+			t.Errorf("expected no filter to match everything")
+		}
+	}
+}
+
+func TestParseKeepsPolicy(t *testing.T) {
+	policy := `{"priority": [{"numeric": [">=", 3]}], "Author": [{"anything-but": ["abc"]}]}`
+	f, err := Parse(policy)
+	if err != nil {
+		t.Fatalf("expected the policy to be parsed, got %v", err)
+	}
+	if f.String() != policy {
+		t.Errorf("expected %q, got %q", policy, f.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	policies := []string{
+		`{"region": `,
+		`{"region": "EU"}`,
+		`{"region": []}`,
+		`{"region": [{"suffix": "U"}]}`,
+		`{"region": [{"prefix": "E", "exists": true}]}`,
+		`{"region": [{"prefix": 1}]}`,
+		`{"region": [{"exists": "yes"}]}`,
+		`{"n": [{"numeric": [">", 1, "<"]}]}`,
+		`{"n": [{"numeric": ["!=", 1]}]}`,
+	}
+
+	for _, policy := range policies {
+		if _, err := Parse(policy); err == nil {
+			t.Errorf("expected an error parsing %s", policy)
+		}
+	}
+}
+
+var matchVector = []struct {
+	policy     string
+	attributes map[string]string
+	match      bool
+}{
+	{`{"region": ["EU", "US"]}`, map[string]string{"region": "US"}, true},
+	{`{"region": ["EU", "US"]}`, map[string]string{"region": "ASIA"}, false},
+	{`{"region": ["EU"]}`, map[string]string{}, false},
+	{`{"n": [3]}`, map[string]string{"n": "3.0"}, true},
+	{`{"region": [{"prefix": "eu-"}]}`, map[string]string{"region": "eu-west"}, true},
+	{`{"region": [{"prefix": "eu-"}]}`, map[string]string{"region": "us-east"}, false},
+	{`{"Author": [{"anything-but": ["abc"]}]}`, map[string]string{"Author": "def"}, true},
+	{`{"Author": [{"anything-but": "abc"}]}`, map[string]string{"Author": "abc"}, false},
+	{`{"Author": [{"anything-but": ["abc"]}]}`, map[string]string{}, false},
+	{`{"n": [{"numeric": [">", 0, "<=", 5]}]}`, map[string]string{"n": "5"}, true},
+	{`{"n": [{"numeric": [">", 0, "<=", 5]}]}`, map[string]string{"n": "0"}, false},
+	{`{"n": [{"numeric": [">=", 3]}]}`, map[string]string{"n": "many"}, false},
+	{`{"trace": [{"exists": true}]}`, map[string]string{"trace": ""}, true},
+	{`{"trace": [{"exists": false}]}`, map[string]string{"trace": "1"}, false},
+	{`{"trace": [{"exists": false}]}`, map[string]string{}, true},
+	// every attribute of the policy must match, any of its values
+	{`{"region": ["EU"], "n": [{"numeric": [">=", 3]}]}`, map[string]string{"region": "EU", "n": "2"}, false},
+	{`{"region": ["EU", {"prefix": "us"}]}`, map[string]string{"region": "us-east"}, true},
+}
+
+func TestMatch(t *testing.T) {
+	for _, test := range matchVector {
+		f, err := Parse(test.policy)
+		if err != nil {
+			t.Fatalf("expected %s to be parsed, got %v", test.policy, err)
+		}
+		if match := f.Match(test.attributes); match != test.match {
+			t.Errorf("expected %s on %v to be %v, got %v", test.policy, test.attributes, test.match, match)
+		}
+	}
+}
+
+// the attributes of the envelope cannot be overridden by the headers
+func TestAttributes(t *testing.T) {
+	e := envelope.New("sensors/rome", "abc", "EU", "payload")
+	e.Headers = map[string]string{"region": "EU", "Topic": "overridden"}
+	expected := map[string]string{
+		"region":      "EU",
+		"Author":      "abc",
+		"Zone":        "EU",
+		"Topic":       "sensors/rome",
+		"ContentType": e.ContentType,
+	}
+
+	attributes := Attributes(e)
+	if len(attributes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, attributes)
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("expected %s %q, got %q", key, value, attributes[key])
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package payloadCodec
+
+import "SDCC-A3-Project/envelope"
+
+// Pack compresses and then encrypts the payload of e, as requested.
+// An empty compression or keyID skips the corresponding step.
+func Pack(e *envelope.Envelope, compression string, provider KeyProvider, keyID string) error {
+	if err := Compress(e, compression); err != nil {
+		return err
+	}
+	if keyID == "" {
+		return nil
+	}
+	return Encrypt(e, provider, keyID)
+}
+
+// Unpack restores the payload of an envelope built by Pack, plain envelopes are left untouched
+func Unpack(e *envelope.Envelope, provider KeyProvider) error {
+	if err := decrypt(e, provider); err != nil {
+		return err
+	}
+	return decompress(e)
+}
@@ -0,0 +1,150 @@
+package payloadCodec
+
+import (
+	"SDCC-A3-Project/envelope"
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newKeys writes a master key for each id in a new directory
+func newKeys(t *testing.T, ids ...string) *FileKeyProvider {
+	dir := t.TempDir()
+	for i, id := range ids {
+		key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(i + 1)}, 32))
+		if err := os.WriteFile(filepath.Join(dir, id+".key"), []byte(key+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewFileKeyProvider(dir)
+}
+
+func TestPackUnpack(t *testing.T) {
+	keys := newKeys(t, "k1")
+	tests := []struct {
+		compression, keyID string
+	}{
+		{"", ""},
+		{Gzip, ""},
+		{Zstd, ""},
+		{"", "k1"},
+		{Gzip, "k1"},
+		{Zstd, "k1"},
+	}
+
+	payload := strings.Repeat("hello ", 100)
+	for _, test := range tests {
+		e := envelope.New("news", "abc", "EU", payload)
+		if err := Pack(e, test.compression, keys, test.keyID); err != nil {
+			t.Fatalf("expected %q/%q to pack, got %v", test.compression, test.keyID, err)
+		}
+		if e.ContentEncoding != test.compression || (e.Encryption != nil) != (test.keyID != "") {
+			t.Errorf("expected encoding %q key %q, got %q %+v", test.compression, test.keyID, e.ContentEncoding, e.Encryption)
+		}
+		if err := Unpack(e, keys); err != nil {
+			t.Fatalf("expected %q/%q to unpack, got %v", test.compression, test.keyID, err)
+		}
+		if e.Payload != payload || e.ContentEncoding != "" || e.Encryption != nil {
+			t.Errorf("expected the original payload back from %q/%q, got %q", test.compression, test.keyID, e.Payload)
+		}
+	}
+}
+
+func TestCompressShrinks(t *testing.T) {
+	e := envelope.New("news", "abc", "EU", strings.Repeat("hello ", 1000))
+	if err := Compress(e, Zstd); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Payload) >= 1000 {
+		t.Errorf("expected a repetitive payload to shrink, got %d bytes", len(e.Payload))
+	}
+}
+
+func TestEncryptHidesPayload(t *testing.T) {
+	e := envelope.New("news", "abc", "EU", "secret")
+	if err := Encrypt(e, newKeys(t, "k1"), "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(e.Payload, "secret") {
+		t.Errorf("expected the payload to be encrypted, got %q", e.Payload)
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	keys := newKeys(t, "k1", "k2")
+	tamper := []func(e *envelope.Envelope){
+		func(e *envelope.Envelope) { e.Encryption.KeyID = "k2" },
+		func(e *envelope.Envelope) { e.Encryption.KeyID = "k3" },
+		func(e *envelope.Envelope) { e.Encryption.KeyID = "../k1" },
+		func(e *envelope.Envelope) { e.Encryption.Algorithm = "ROT13" },
+		func(e *envelope.Envelope) { e.MessageID = "other" },
+		func(e *envelope.Envelope) { e.Topic = "alarms" },
+		func(e *envelope.Envelope) { e.ContentType = "application/json" },
+		func(e *envelope.Envelope) { e.ContentEncoding = Zstd },
+		func(e *envelope.Envelope) { e.ContentEncoding = "" },
+		func(e *envelope.Envelope) { e.Payload = base64.StdEncoding.EncodeToString([]byte("x")) },
+	}
+
+	for i, change := range tamper {
+		e := envelope.New("news", "abc", "EU", "secret")
+		if err := Pack(e, Gzip, keys, "k1"); err != nil {
+			t.Fatal(err)
+		}
+		change(e)
+		if err := Unpack(e, keys); err == nil {
+			t.Errorf("expected an error unpacking the tampered envelope %d, got %q", i, e.Payload)
+		}
+	}
+}
+
+// a small message that decompresses to more than MaxPayload is rejected
+func TestDecompressionBomb(t *testing.T) {
+	payload := strings.Repeat("0", MaxPayload+1)
+	for _, algorithm := range []string{Gzip, Zstd} {
+		e := envelope.New("news", "abc", "EU", payload)
+		if err := Compress(e, algorithm); err != nil {
+			t.Fatal(err)
+		}
+		if len(e.Payload) > 1<<20 {
+			t.Fatalf("expected a small %s message, got %d bytes", algorithm, len(e.Payload))
+		}
+		if err := Unpack(e, nil); err == nil {
+			t.Errorf("expected an error decompressing %d bytes of %s", len(payload), algorithm)
+		}
+	}
+}
+
+func TestUnpackWithoutKeys(t *testing.T) {
+	e := envelope.New("news", "abc", "EU", "secret")
+	if err := Pack(e, "", newKeys(t, "k1"), "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unpack(e, nil); err == nil {
+		t.Errorf("expected an error unpacking without a key provider")
+	}
+}
+
+func TestValidCompression(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		valid     bool
+	}{
+		{"", true},
+		{Gzip, true},
+		{Zstd, true},
+		{"lz4", false},
+		{"GZIP", false},
+	}
+
+	for _, test := range tests {
+		if valid := ValidCompression(test.algorithm); valid != test.valid {
+			t.Errorf("expected ValidCompression(%q) %v, got %v", test.algorithm, test.valid, valid)
+		}
+	}
+	if err := Compress(envelope.New("news", "abc", "EU", "x"), "lz4"); err == nil {
+		t.Errorf("expected an error compressing with lz4")
+	}
+}
@@ -0,0 +1,102 @@
+package payloadCodec
+
+import (
+	"SDCC-A3-Project/envelope"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+)
+
+// supported values of envelope.ContentEncoding
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// MaxPayload is the size in bytes a payload can reach once decompressed, a larger one is
+// rejected instead of filling the memory of the consumer
+const MaxPayload = 64 << 20
+
+// ValidCompression tells whether algorithm can be used to compress the payloads, empty means none
+func ValidCompression(algorithm string) bool {
+	return algorithm == "" || algorithm == Gzip || algorithm == Zstd
+}
+
+// Compress replaces the payload of e with its compressed form, base64 encoded
+func Compress(e *envelope.Envelope, algorithm string) error {
+	if algorithm == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	switch algorithm {
+	case Gzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(e.Payload)); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	case Zstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write([]byte(e.Payload)); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	default:
+		return errors.New("unsupported compression: " + algorithm)
+	}
+	e.Payload = base64.StdEncoding.EncodeToString(buf.Bytes())
+	e.ContentEncoding = algorithm
+	return nil
+}
+
+// decompress restores the payload of an envelope built by Compress
+func decompress(e *envelope.Envelope) error {
+	if e.ContentEncoding == "" {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return err
+	}
+	var r io.Reader
+	switch e.ContentEncoding {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return errors.New("unsupported compression: " + e.ContentEncoding)
+	}
+	plain, err := ioutil.ReadAll(io.LimitReader(r, MaxPayload+1))
+	if err != nil {
+		return err
+	}
+	if len(plain) > MaxPayload {
+		return errors.New(fmt.Sprintf("the decompressed payload is larger than %d bytes", MaxPayload))
+	}
+	e.Payload = string(plain)
+	e.ContentEncoding = ""
+	return nil
+}
@@ -0,0 +1,125 @@
+package payloadCodec
+
+import (
+	"SDCC-A3-Project/envelope"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// AESGCM is the only supported value of envelope.Encryption.Algorithm
+const AESGCM = "AES-256-GCM"
+
+// KeyProvider protects the data keys with master keys that never leave the provider
+type KeyProvider interface {
+	// WrapKey encrypts dataKey with the master key keyID
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key encrypted by WrapKey with the master key keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Encrypt replaces the payload of e with its AES-GCM encryption, base64 encoded.
+// Every message is encrypted with a new data key, stored in e wrapped by the master key keyID.
+func Encrypt(e *envelope.Envelope, provider KeyProvider, keyID string) error {
+	if provider == nil {
+		return errors.New("encryption requested but no key provider configured")
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return err
+	}
+	wrapped, err := provider.WrapKey(keyID, dataKey)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(dataKey, []byte(e.Payload), additionalData(e))
+	if err != nil {
+		return err
+	}
+	e.Payload = base64.StdEncoding.EncodeToString(sealed)
+	e.Encryption = &envelope.Encryption{
+		Algorithm:  AESGCM,
+		KeyID:      keyID,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+	}
+	return nil
+}
+
+// decrypt restores the payload of an envelope built by Encrypt
+func decrypt(e *envelope.Envelope, provider KeyProvider) error {
+	if e.Encryption == nil {
+		return nil
+	}
+	if e.Encryption.Algorithm != AESGCM {
+		return errors.New("unsupported encryption: " + e.Encryption.Algorithm)
+	}
+	if provider == nil {
+		return errors.New("encrypted message but no key provider configured")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(e.Encryption.WrappedKey)
+	if err != nil {
+		return err
+	}
+	dataKey, err := provider.UnwrapKey(e.Encryption.KeyID, wrapped)
+	if err != nil {
+		return err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return err
+	}
+	plain, err := open(dataKey, sealed, additionalData(e))
+	if err != nil {
+		return err
+	}
+	e.Payload = string(plain)
+	e.Encryption = nil
+	return nil
+}
+
+// additionalData binds the ciphertext to its message and to how the payload must be read:
+// a payload moved to another message or topic, or relabeled, fails to decrypt
+func additionalData(e *envelope.Envelope) []byte {
+	// the fields are quoted, no two different lists give the same bytes
+	data, _ := json.Marshal([]string{e.MessageID, e.Topic, e.ContentType, e.ContentEncoding})
+	return data
+}
+
+// seal encrypts plain with key, the random nonce is prepended to the result.
+// additional is authenticated but not encrypted, it binds the ciphertext to its message.
+func seal(key, plain, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, additional), nil
+}
+
+// open decrypts the output of seal
+func open(key, sealed, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
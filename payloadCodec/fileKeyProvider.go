@@ -0,0 +1,53 @@
+package payloadCodec
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// FileKeyProvider reads the master keys from a local directory: the key keyID is the file
+// <keyID>.key holding 32 random bytes, base64 encoded (e.g. openssl rand -base64 32)
+type FileKeyProvider struct {
+	dir string
+}
+
+func NewFileKeyProvider(dir string) *FileKeyProvider {
+	return &FileKeyProvider{dir: dir}
+}
+
+func (p *FileKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	master, err := p.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return seal(master, dataKey, []byte(keyID))
+}
+
+func (p *FileKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	master, err := p.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return open(master, wrapped, []byte(keyID))
+}
+
+func (p *FileKeyProvider) masterKey(keyID string) ([]byte, error) {
+	if keyID == "" || strings.ContainsAny(keyID, `/\`) || strings.HasPrefix(keyID, ".") {
+		return nil, errors.New("invalid key id: " + keyID)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(p.dir, keyID+".key"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("the key " + keyID + " must be 32 bytes long")
+	}
+	return key, nil
+}
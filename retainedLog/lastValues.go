@@ -0,0 +1,129 @@
+package retainedLog
+
+import (
+	"SDCC-A3-Project/utilities"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// LastValues keeps the last retained message of each topic in a file.
+// A message with an empty body clears the value, it is kept to discard older updates.
+type LastValues struct {
+	path   string
+	values map[string]utilities.RetainedMessage
+	mtx    sync.Mutex
+}
+
+// OpenLastValues loads the values saved in the file at path, which is created with the first value
+func OpenLastValues(path string) (*LastValues, error) {
+	lv := &LastValues{path: path, values: make(map[string]utilities.RetainedMessage)}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var values []utilities.RetainedMessage
+	if err = json.Unmarshal(b, &values); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	for _, m := range values {
+		lv.values[m.Topic] = m
+	}
+	return lv, nil
+}
+
+// Get returns the last value of the topic
+func (lv *LastValues) Get(topic string) (utilities.RetainedMessage, bool) {
+	lv.mtx.Lock()
+	defer lv.mtx.Unlock()
+	m, exists := lv.values[topic]
+	return m, exists && m.Body != ""
+}
+
+// Topics returns the topics having a value, sorted
+func (lv *LastValues) Topics() []string {
+	lv.mtx.Lock()
+	defer lv.mtx.Unlock()
+	var list []string
+	for topic, m := range lv.values {
+		if m.Body != "" {
+			list = append(list, topic)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Set replaces the value of the topic of m, unless the current one is newer.
+// It returns whether m has been kept and, if so, the value it replaced, if any.
+func (lv *LastValues) Set(m utilities.RetainedMessage) (bool, *utilities.RetainedMessage, error) {
+	lv.mtx.Lock()
+	defer lv.mtx.Unlock()
+	old, exists := lv.values[m.Topic]
+	if exists && old.Time.After(m.Time) {
+		return false, nil, nil
+	}
+	lv.values[m.Topic] = m
+	if err := lv.save(); err != nil {
+		if exists {
+			lv.values[m.Topic] = old
+		} else {
+			delete(lv.values, m.Topic)
+		}
+		return false, nil, err
+	}
+	if !exists {
+		return true, nil, nil
+	}
+	return true, &old, nil
+}
+
+// Delete forgets the topic, it returns the value deleted if any
+func (lv *LastValues) Delete(topic string) (*utilities.RetainedMessage, error) {
+	lv.mtx.Lock()
+	defer lv.mtx.Unlock()
+	old, exists := lv.values[topic]
+	if !exists {
+		return nil, nil
+	}
+	delete(lv.values, topic)
+	return &old, lv.save()
+}
+
+// save rewrites the file, the old content is replaced only once the new one is complete.
+// The caller must hold the lock.
+func (lv *LastValues) save() error {
+	values := make([]utilities.RetainedMessage, 0, len(lv.values))
+	for _, m := range lv.values {
+		values = append(values, m)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Topic < values[j].Topic })
+	b, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(lv.path), filepath.Base(lv.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), lv.path)
+}
@@ -0,0 +1,103 @@
+package retainedLog
+
+import (
+	"SDCC-A3-Project/utilities"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func value(topic, id, body string, minutes int) utilities.RetainedMessage {
+	return utilities.RetainedMessage{Topic: topic, MessageID: id, Body: body, Time: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+// messageID returns the id of m, empty if nil
+func messageID(m *utilities.RetainedMessage) string {
+	if m == nil {
+		return ""
+	}
+	return m.MessageID
+}
+
+func openValues(t *testing.T) (*LastValues, string) {
+	path := filepath.Join(t.TempDir(), "values", "last-values.json")
+	lv, err := OpenLastValues(path)
+	if err != nil {
+		t.Fatalf("expected a missing file to open empty, got %v", err)
+	}
+	return lv, path
+}
+
+// the newest value of each topic wins whatever the order it arrives in, and survives a restart
+func TestSetNewestWins(t *testing.T) {
+	tests := []struct {
+		set      []utilities.RetainedMessage
+		kept     []bool
+		replaced []string
+		last     string
+	}{
+		{[]utilities.RetainedMessage{value("news", "a", "x", 0), value("news", "b", "y", 1)}, []bool{true, true}, []string{"", "a"}, "b"},
+		{[]utilities.RetainedMessage{value("news", "b", "y", 1), value("news", "a", "x", 0)}, []bool{true, false}, []string{"", ""}, "b"},
+		{[]utilities.RetainedMessage{value("news", "a", "x", 0), value("news", "b", "y", 0)}, []bool{true, true}, []string{"", "a"}, "b"},
+	}
+
+	for _, test := range tests {
+		lv, path := openValues(t)
+		for i, m := range test.set {
+			kept, replaced, err := lv.Set(m)
+			if err != nil || kept != test.kept[i] || messageID(replaced) != test.replaced[i] {
+				t.Errorf("expected Set(%s) %v replacing %q, got %v replacing %q, %v", m.MessageID, test.kept[i], test.replaced[i], kept, messageID(replaced), err)
+			}
+		}
+		lv, err := OpenLastValues(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, exists := lv.Get("news"); !exists || m.MessageID != test.last {
+			t.Errorf("expected %s after a restart, got %q, %v", test.last, m.MessageID, exists)
+		}
+	}
+}
+
+// an empty body clears the value, and still discards the older ones
+func TestSetClear(t *testing.T) {
+	lv, _ := openValues(t)
+	lv.Set(value("news", "a", "x", 0))
+	// the cleared value is returned, so that what it refers to can be freed
+	if _, replaced, _ := lv.Set(value("news", "c", "", 2)); messageID(replaced) != "a" {
+		t.Errorf("expected the clear to replace a, got %q", messageID(replaced))
+	}
+
+	if m, exists := lv.Get("news"); exists {
+		t.Errorf("expected no value after clearing, got %s", m.MessageID)
+	}
+	if kept, _, _ := lv.Set(value("news", "b", "y", 1)); kept {
+		t.Errorf("expected a value older than the clear to be discarded")
+	}
+	if topics := lv.Topics(); len(topics) != 0 {
+		t.Errorf("expected no topic with a value, got %v", topics)
+	}
+}
+
+func TestTopicsAndDelete(t *testing.T) {
+	lv, _ := openValues(t)
+	lv.Set(value("sport", "a", "x", 0))
+	lv.Set(value("news", "b", "y", 0))
+
+	if topics := lv.Topics(); !reflect.DeepEqual(topics, []string{"news", "sport"}) {
+		t.Errorf("expected [news sport], got %v", topics)
+	}
+	if deleted, err := lv.Delete("news"); err != nil || messageID(deleted) != "b" {
+		t.Fatalf("expected b to be deleted, got %q, %v", messageID(deleted), err)
+	}
+	if deleted, err := lv.Delete("news"); err != nil || deleted != nil {
+		t.Errorf("expected nothing left to delete, got %q, %v", messageID(deleted), err)
+	}
+	if _, exists := lv.Get("news"); exists {
+		t.Errorf("expected the value of news to be deleted")
+	}
+	if _, exists := lv.Get("sport"); !exists {
+		t.Errorf("expected the value of sport to be kept")
+	}
+}
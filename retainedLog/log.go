@@ -0,0 +1,295 @@
+package retainedLog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SegmentSize   = 16 << 20 // bytes after which a new segment is started
+	segmentSuffix = ".log"
+	maxLine       = 1 << 20 // longest record, a message is at most 256KiB
+	recentIDs     = 10000   // message ids remembered to skip the redeliveries
+)
+
+var ErrOffsetTrimmed = errors.New("offset no longer retained")
+
+// Record is a message kept by the log
+type Record struct {
+	Offset     int64                                 `json:"offset"`
+	Time       time.Time                             `json:"time"`       // when the message has been appended
+	MessageID  string                                `json:"message_id"` // envelope id, or sqs id of a message without envelope
+	Body       string                                `json:"body"`
+	Attributes map[string]*sqs.MessageAttributeValue `json:"attributes,omitempty"`
+}
+
+type segment struct {
+	base    int64 // offset of the first record
+	path    string
+	size    int64
+	modTime time.Time // time of the last record
+}
+
+// Log is an append-only sequence of records of a topic, stored in segment files
+// named after the offset of their first record
+type Log struct {
+	dir      string
+	mtx      sync.Mutex
+	segments []segment // oldest first, the last one is written
+	active   *os.File
+	next     int64 // offset of the next record
+	recent   map[string]bool
+	order    []string // recent, oldest first
+}
+
+// Open opens the log in dir, creating it if needed
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, recent: make(map[string]bool)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		l.segments = append(l.segments, segment{base: base, path: filepath.Join(dir, name), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].base < l.segments[j].base })
+
+	if len(l.segments) == 0 {
+		err = l.roll()
+		return l, err
+	}
+	last := &l.segments[len(l.segments)-1]
+	if last.size, err = repair(last.path); err != nil {
+		return nil, err
+	}
+	l.next = last.base
+	err = scan(last.path, func(r Record) bool {
+		l.next = r.Offset + 1
+		l.remember(r.MessageID)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.active, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append adds a record for the message, unless it has been appended recently.
+// It returns whether the record has been added.
+func (l *Log) Append(messageID, body string, attributes map[string]*sqs.MessageAttributeValue, now time.Time) (bool, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.recent[messageID] {
+		return false, nil
+	}
+	if l.segments[len(l.segments)-1].size >= SegmentSize {
+		if err := l.roll(); err != nil {
+			return false, err
+		}
+	}
+	b, err := json.Marshal(Record{Offset: l.next, Time: now.UTC(), MessageID: messageID, Body: body, Attributes: attributes})
+	if err != nil {
+		return false, err
+	}
+	b = append(b, '\n')
+	if _, err = l.active.Write(b); err != nil {
+		return false, err
+	}
+	current := &l.segments[len(l.segments)-1]
+	current.size += int64(len(b))
+	current.modTime = now
+	l.next++
+	l.remember(messageID)
+	return true, nil
+}
+
+// Read returns up to max records starting from offset, together with the offset following them
+func (l *Log) Read(offset int64, max int) ([]Record, int64, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if offset < l.segments[0].base {
+		return nil, l.segments[0].base, ErrOffsetTrimmed
+	}
+	return l.read(func(r Record) bool { return r.Offset >= offset }, offset, max)
+}
+
+// ReadSince returns up to max records appended at t or later, together with the offset following them
+func (l *Log) ReadSince(t time.Time, max int) ([]Record, int64, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.read(func(r Record) bool { return !r.Time.Before(t) }, l.segments[0].base, max)
+}
+
+// read collects the records accepted by from, starting from the segments that may hold offset.
+// The caller must hold the lock.
+func (l *Log) read(from func(Record) bool, offset int64, max int) ([]Record, int64, error) {
+	var records []Record
+	next := offset
+	for i, seg := range l.segments {
+		if i+1 < len(l.segments) && l.segments[i+1].base <= offset {
+			// all its records come before offset
+			continue
+		}
+		err := scan(seg.path, func(r Record) bool {
+			if !from(r) {
+				return true
+			}
+			records = append(records, r)
+			next = r.Offset + 1
+			return len(records) < max
+		})
+		if err != nil {
+			return nil, offset, err
+		}
+		if len(records) >= max {
+			break
+		}
+	}
+	if len(records) == 0 {
+		// nothing new: the caller can wait for the next record
+		next = l.next
+		if offset > next {
+			next = offset
+		}
+	}
+	return records, next, nil
+}
+
+// Next returns the offset the next record will get
+func (l *Log) Next() int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.next
+}
+
+// Enforce deletes the oldest segments holding only records older than maxAge, or exceeding
+// maxBytes all together; a zero limit is not applied. The segment being written is kept.
+// trimmed, if not nil, is called for every record deleted, e.g. to free what it refers to.
+func (l *Log) Enforce(maxAge time.Duration, maxBytes int64, now time.Time, trimmed func(Record)) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	var total int64
+	for _, seg := range l.segments {
+		total += seg.size
+	}
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+		expired := maxAge > 0 && now.Sub(oldest.modTime) > maxAge
+		oversize := maxBytes > 0 && total > maxBytes
+		if !expired && !oversize {
+			break
+		}
+		if trimmed != nil {
+			err := scan(oldest.path, func(r Record) bool {
+				trimmed(r)
+				return true
+			})
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Remove(oldest.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= oldest.size
+		l.segments = l.segments[1:]
+	}
+	return nil
+}
+
+// Close closes the segment being written
+func (l *Log) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.active.Close()
+}
+
+// roll starts a new segment. The caller must hold the lock.
+func (l *Log) roll() error {
+	path := filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.next, segmentSuffix))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if l.active != nil {
+		l.active.Close()
+	}
+	l.active = f
+	l.segments = append(l.segments, segment{base: l.next, path: path, modTime: time.Now()})
+	return nil
+}
+
+// remember records a message id among the recent ones. The caller must hold the lock.
+func (l *Log) remember(messageID string) {
+	l.recent[messageID] = true
+	l.order = append(l.order, messageID)
+	if len(l.order) > recentIDs {
+		delete(l.recent, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+// repair cuts the partial record a crash may have left at the end of the segment,
+// so that the next one starts on its own line. It returns the new size.
+func repair(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	end := strings.LastIndexByte(string(b), '\n') + 1
+	if end == len(b) {
+		return int64(end), nil
+	}
+	return int64(end), os.Truncate(path, int64(end))
+}
+
+// scan calls fn for every record of the segment until it returns false.
+// A truncated last line, left by a crash, is ignored.
+func scan(path string, fn func(Record) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if !fn(r) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,192 @@
+package retainedLog
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+func ids(records []Record) string {
+	var b strings.Builder
+	for _, r := range records {
+		b.WriteString(r.MessageID)
+	}
+	return b.String()
+}
+
+// appends the messages one minute apart from start
+func appendAll(t *testing.T, l *Log, messageIDs ...string) {
+	for i, id := range messageIDs {
+		if _, err := l.Append(id, "body "+id, nil, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("expected %s to be appended, got %v", id, err)
+		}
+	}
+}
+
+func TestAppendSkipsRedeliveries(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendAll(t, l, "a", "b")
+
+	added, err := l.Append("a", "body a", nil, start)
+	if err != nil || added {
+		t.Errorf("expected the redelivery of a to be skipped, got %v, %v", added, err)
+	}
+	if l.Next() != 2 {
+		t.Errorf("expected next offset 2, got %d", l.Next())
+	}
+}
+
+func TestRead(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendAll(t, l, "a", "b", "c", "d")
+
+	tests := []struct {
+		offset  int64
+		max     int
+		records string
+		next    int64
+	}{
+		{0, 10, "abcd", 4},
+		{0, 2, "ab", 2},
+		{2, 10, "cd", 4},
+		{4, 10, "", 4},
+		{9, 10, "", 9},
+	}
+	for _, test := range tests {
+		records, next, err := l.Read(test.offset, test.max)
+		if err != nil {
+			t.Fatalf("expected to read from %d, got %v", test.offset, err)
+		}
+		if ids(records) != test.records || next != test.next {
+			t.Errorf("expected %q next %d from %d, got %q next %d", test.records, test.next, test.offset, ids(records), next)
+		}
+	}
+}
+
+func TestReadSince(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendAll(t, l, "a", "b", "c", "d")
+
+	records, next, err := l.ReadSince(start.Add(2*time.Minute), 10)
+	if err != nil || ids(records) != "cd" || next != 4 {
+		t.Errorf("expected \"cd\" next 4, got %q next %d, error %v", ids(records), next, err)
+	}
+	records, next, err = l.ReadSince(start.Add(time.Hour), 10)
+	if err != nil || len(records) != 0 || next != 4 {
+		t.Errorf("expected nothing next 4, got %q next %d, error %v", ids(records), next, err)
+	}
+}
+
+// a record cut by a crash is dropped when the log is opened again, the offsets go on
+func TestReopenAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "a", "b")
+	l.Close()
+	f, err := os.OpenFile(l.segments[0].path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"offset":2,"message_id":"c","bo`)
+	f.Close()
+
+	l, err = Open(dir)
+	if err != nil {
+		t.Fatalf("expected the log to open again, got %v", err)
+	}
+	defer l.Close()
+	if added, _ := l.Append("b", "body b", nil, start); added {
+		t.Errorf("expected the redelivery of b to be skipped after reopening")
+	}
+	if _, err = l.Append("c", "body c", nil, start); err != nil {
+		t.Fatal(err)
+	}
+	records, next, err := l.Read(0, 10)
+	if err != nil || ids(records) != "abc" || next != 3 {
+		t.Errorf("expected \"abc\" next 3, got %q next %d, error %v", ids(records), next, err)
+	}
+}
+
+// fills three segments of records as long as allowed
+func fill(t *testing.T, l *Log) {
+	body := strings.Repeat("x", maxLine/2)
+	for i := 0; len(l.segments) < 3 || l.segments[2].base == l.Next(); i++ {
+		if _, err := l.Append(strconv.Itoa(i), body, nil, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range l.segments {
+		l.segments[i].modTime = start
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	tests := []struct {
+		maxAge   time.Duration
+		maxBytes int64
+		deleted  int // out of the 2 segments not being written
+	}{
+		{0, 0, 0},
+		{time.Hour, 0, 0},
+		{time.Minute, 0, 2},
+		{0, maxLine, 2},
+		{0, SegmentSize * 2, 1},
+		{0, SegmentSize * 10, 0},
+	}
+
+	for _, test := range tests {
+		l, err := Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill(t, l)
+		first, total := l.segments[test.deleted].base, l.Next()
+
+		// the records deleted are reported in order
+		var trimmed int64
+		err = l.Enforce(test.maxAge, test.maxBytes, start.Add(10*time.Minute), func(r Record) {
+			if r.Offset != trimmed {
+				t.Errorf("expected the trimmed record %d, got %d", trimmed, r.Offset)
+			}
+			trimmed++
+		})
+		if err != nil {
+			t.Fatalf("expected the limits %v/%d to be enforced, got %v", test.maxAge, test.maxBytes, err)
+		}
+		if trimmed != first {
+			t.Errorf("expected %d records trimmed by %v/%d, got %d", first, test.maxAge, test.maxBytes, trimmed)
+		}
+		if len(l.segments) != 3-test.deleted {
+			t.Errorf("expected %d segments left by %v/%d, got %d", 3-test.deleted, test.maxAge, test.maxBytes, len(l.segments))
+		}
+		records, _, err := l.Read(first, int(total))
+		if err != nil || int64(len(records)) != total-first {
+			t.Errorf("expected %d records from %d, got %d, error %v", total-first, first, len(records), err)
+		}
+		if first > 0 {
+			if _, next, err := l.Read(0, 1); err != ErrOffsetTrimmed || next != first {
+				t.Errorf("expected %v and next %d reading a trimmed offset, got %v next %d", ErrOffsetTrimmed, first, err, next)
+			}
+		}
+		l.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package retainedLog
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Logs are the logs of the topics, each one in its own directory under root
+type Logs struct {
+	root string
+	logs map[string]*Log
+	mtx  sync.Mutex
+}
+
+func NewLogs(root string) *Logs {
+	return &Logs{root: root, logs: make(map[string]*Log)}
+}
+
+// Get returns the log of the topic, opening it the first time
+func (ls *Logs) Get(topic string) (*Log, error) {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	if l, exists := ls.logs[topic]; exists {
+		return l, nil
+	}
+	l, err := Open(ls.dir(topic))
+	if err != nil {
+		return nil, err
+	}
+	ls.logs[topic] = l
+	return l, nil
+}
+
+// Open returns the topics whose log is open
+func (ls *Logs) Open() []string {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	var open []string
+	for topic := range ls.logs {
+		open = append(open, topic)
+	}
+	return open
+}
+
+// Drop deletes the log of the topic with all its records
+func (ls *Logs) Drop(topic string) error {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	if l, exists := ls.logs[topic]; exists {
+		l.Close()
+		delete(ls.logs, topic)
+	}
+	return os.RemoveAll(ls.dir(topic))
+}
+
+// Close closes all the logs
+func (ls *Logs) Close() {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	for topic, l := range ls.logs {
+		l.Close()
+		delete(ls.logs, topic)
+	}
+}
+
+// dir escapes the separators of the topic, the prefix keeps "." and ".." out of the way
+func (ls *Logs) dir(topic string) string {
+	return filepath.Join(ls.root, "topic-"+url.PathEscape(topic))
+}
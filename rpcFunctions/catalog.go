@@ -0,0 +1,102 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// addToCatalog records a new topic, owner is the user whose subscription created it.
+// The caller must hold the write lock.
+func (s *Service) addToCatalog(tag, description, owner string) {
+	if _, exists := s.CatalogMap[tag]; exists {
+		return
+	}
+	s.CatalogMap[tag] = utilities.TopicInfo{
+		Name:         tag,
+		Description:  description,
+		Owner:        owner,
+		CreationTime: time.Now(),
+		Zone:         s.Zone,
+	}
+}
+
+// topicInfo returns the catalog entry of the topic with its current state.
+// The caller must hold the lock.
+func (s *Service) topicInfo(tag string) utilities.TopicInfo {
+	info := s.CatalogMap[tag]
+	info.DeliveryMode = utilities.StandardDelivery
+	if s.topicSettings(tag).Fifo {
+		info.DeliveryMode = utilities.FifoDelivery
+	}
+	info.Subscribers = s.QueueSubscribersMap[tag]
+	info.Publishers = len(s.PublishersMap[tag])
+	return info
+}
+
+// catalog returns, sorted by name, the topics of this server accepted by keep.
+// The caller must hold the lock.
+func (s *Service) catalog(keep func(info utilities.TopicInfo) bool) []utilities.TopicInfo {
+	var l []utilities.TopicInfo
+	for tag := range s.CatalogMap {
+		info := s.topicInfo(tag)
+		if keep(info) {
+			l = append(l, info)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// ListTopics returns the topics of this server; if inArg.Tag is not empty, only the ones matched by it,
+// a topic name or a pattern
+func (s *Service) ListTopics(inArg *utilities.RequestArg, outList *[]utilities.TopicInfo) error {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		return errors.New("invalid user id\n")
+	}
+	*outList = s.catalog(func(info utilities.TopicInfo) bool {
+		return inArg.Tag == "" || topics.Match(inArg.Tag, info.Name)
+	})
+	return nil
+}
+
+// DescribeTopic returns the catalog entry of the topic
+func (s *Service) DescribeTopic(inArg *utilities.RequestArg, outInfo *utilities.TopicInfo) error {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		return errors.New("invalid user id\n")
+	}
+	if _, exists := s.CatalogMap[inArg.Tag]; !exists {
+		return errors.New("unknown topic " + inArg.Tag + "\n")
+	}
+	*outInfo = s.topicInfo(inArg.Tag)
+	return nil
+}
+
+// SearchTopics returns the topics whose name or description contains the query, ignoring case,
+// and that satisfy the other criteria given
+func (s *Service) SearchTopics(inArg *utilities.SearchArg, outList *[]utilities.TopicInfo) error {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		return errors.New("invalid user id\n")
+	}
+	query := strings.ToLower(inArg.Query)
+	*outList = s.catalog(func(info utilities.TopicInfo) bool {
+		if query != "" && !strings.Contains(strings.ToLower(info.Name), query) &&
+			!strings.Contains(strings.ToLower(info.Description), query) {
+			return false
+		}
+		if inArg.Owner != "" && info.Owner != inArg.Owner {
+			return false
+		}
+		return inArg.DeliveryMode == "" || info.DeliveryMode == inArg.DeliveryMode
+	})
+	return nil
+}
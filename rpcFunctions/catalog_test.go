@@ -0,0 +1,116 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/utilities"
+	"strings"
+	"testing"
+)
+
+// names returns the names of the topics, in order
+func names(list []utilities.TopicInfo) string {
+	var l []string
+	for _, info := range list {
+		l = append(l, info.Name)
+	}
+	return strings.Join(l, ",")
+}
+
+// newCatalog returns a server knowing the topics eu/news, eu/sport and us/news, created by the
+// first user returned; the second one subscribed to eu/news too
+func newCatalog(t *testing.T) (*Service, string, string) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	var owner, other string
+	for _, id := range []*string{&owner, &other} {
+		if err := s.GenerateUserId(&utilities.RequestArg{}, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscriptions := []utilities.RequestArg{
+		{ID: owner, Tag: "eu/news", Description: "Daily news of Europe"},
+		{ID: owner, Tag: "eu/sport", Settings: &utilities.QueueSettings{Fifo: true}},
+		{ID: owner, Tag: "us/news"},
+		{ID: other, Tag: "eu/news"},
+	}
+	for i := range subscriptions {
+		var out utilities.SubscriptionOutput
+		if err := s.MakeSubscriptionToTopic(&subscriptions[i], &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, owner, other
+}
+
+func TestListTopics(t *testing.T) {
+	s, owner, _ := newCatalog(t)
+	tests := []struct {
+		pattern string
+		names   string
+	}{
+		{"", "eu/news,eu/sport,us/news"},
+		{"eu/*", "eu/news,eu/sport"},
+		{"*/news", "eu/news,us/news"},
+		{"us/news", "us/news"},
+		{"asia/#", ""},
+	}
+	for _, test := range tests {
+		var list []utilities.TopicInfo
+		if err := s.ListTopics(&utilities.RequestArg{ID: owner, Tag: test.pattern}, &list); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(list); got != test.names {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.names, got)
+		}
+	}
+
+	var list []utilities.TopicInfo
+	if err := s.ListTopics(&utilities.RequestArg{ID: "unknown"}, &list); err == nil {
+		t.Errorf("expected an unknown user refused")
+	}
+}
+
+func TestDescribeTopic(t *testing.T) {
+	s, owner, other := newCatalog(t)
+	var info utilities.TopicInfo
+	if err := s.DescribeTopic(&utilities.RequestArg{ID: other, Tag: "eu/news"}, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Owner != owner || info.Description != "Daily news of Europe" || info.Subscribers != 2 ||
+		info.DeliveryMode != utilities.StandardDelivery || info.Zone != "eu" || info.CreationTime.IsZero() {
+		t.Errorf("unexpected entry of eu/news: %+v", info)
+	}
+	if err := s.DescribeTopic(&utilities.RequestArg{ID: other, Tag: "eu/sport"}, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.DeliveryMode != utilities.FifoDelivery || info.Subscribers != 1 {
+		t.Errorf("unexpected entry of eu/sport: %+v", info)
+	}
+	if err := s.DescribeTopic(&utilities.RequestArg{ID: other, Tag: "asia/news"}, &info); err == nil {
+		t.Errorf("expected an unknown topic refused")
+	}
+}
+
+func TestSearchTopics(t *testing.T) {
+	s, owner, other := newCatalog(t)
+	tests := []struct {
+		query, owner, deliveryMode string
+		names                      string
+	}{
+		{"", "", "", "eu/news,eu/sport,us/news"},
+		{"NEWS", "", "", "eu/news,us/news"},
+		{"daily", "", "", "eu/news"},
+		{"", owner, "", "eu/news,eu/sport,us/news"},
+		{"", other, "", ""},
+		{"", "", utilities.FifoDelivery, "eu/sport"},
+		{"news", "", utilities.FifoDelivery, ""},
+	}
+	for _, test := range tests {
+		var list []utilities.TopicInfo
+		if err := s.SearchTopics(&utilities.SearchArg{ID: other, Query: test.query, Owner: test.owner, DeliveryMode: test.deliveryMode}, &list); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(list); got != test.names {
+			t.Errorf("%q %q %q: expected %q, got %q", test.query, test.owner, test.deliveryMode, test.names, got)
+		}
+	}
+}
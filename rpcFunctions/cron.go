@@ -0,0 +1,212 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/cronJobs"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errCronDisabled is returned by the cron RPCs of a server started without a cron table
+var errCronDisabled = errors.New("recurring publications are disabled on this server\n")
+
+// RegisterCronJob registers a recurring publication on a topic, run by the servers of this zone.
+// The user must be able to send to the topic. The payloads are compressed as the topic requires
+// and, if inArg.KeyID is set, encrypted with that master key, which the servers must hold.
+func (s *Service) RegisterCronJob(inArg *utilities.CronArg, outID *string) error {
+	if s.CronStore == nil {
+		return errCronDisabled
+	}
+	if inArg.KeyID != "" && s.Keys == nil {
+		return errors.New("this server cannot encrypt the payloads, it has no master keys\n")
+	}
+	var url string
+	// checks the subscription or the publisher registration
+	if err := s.GetQueueURL(&utilities.RequestArg{ID: inArg.ID, Tag: inArg.Tag}, &url); err != nil {
+		return err
+	}
+	schedule, err := cronJobs.ParseSchedule(inArg.Schedule)
+	if err != nil {
+		return errors.New("invalid schedule: " + err.Error() + "\n")
+	}
+	if schedule.Next(time.Now().UTC()).IsZero() {
+		return errors.New("the schedule " + inArg.Schedule + " never runs\n")
+	}
+	if _, err = cronJobs.ParseTemplate(inArg.Template); err != nil {
+		return errors.New("invalid template: " + err.Error() + "\n")
+	}
+	// GetQueueURL loaded the settings of a topic created on another server
+	s.RwMtx.RLock()
+	settings := s.topicSettings(inArg.Tag)
+	s.RwMtx.RUnlock()
+
+	job := utilities.CronJob{
+		ID:           shortuuid.New(),
+		Owner:        inArg.ID,
+		Topic:        inArg.Tag,
+		Schedule:     inArg.Schedule,
+		Template:     inArg.Template,
+		ContentType:  inArg.ContentType,
+		Headers:      inArg.Headers,
+		Zone:         s.Zone,
+		Compression:  settings.Compression,
+		KeyID:        inArg.KeyID,
+		CreationTime: time.Now(),
+	}
+	if job.ContentType == "" {
+		job.ContentType = envelope.DefaultContentType
+	}
+	if err = s.CronStore.Put(job); err != nil {
+		log.Printf("[WARNING] - cannot store the job: %v", err)
+		return errors.New("cannot store the job\n")
+	}
+	log.Printf("[INFO] - job %s publishing on %s at %q registered by %s", job.ID, job.Topic, job.Schedule, job.Owner)
+	*outID = job.ID
+	return nil
+}
+
+// DeleteCronJob deletes the job inArg.JobID, only its owner can do it
+func (s *Service) DeleteCronJob(inArg *utilities.CronArg, exitStatus *int) error {
+	if s.CronStore == nil {
+		return errCronDisabled
+	}
+	s.RwMtx.RLock()
+	_, exists := s.UsersIdMap[inArg.ID]
+	s.RwMtx.RUnlock()
+	if !exists {
+		return errors.New("invalid user id\n")
+	}
+	job, err := s.CronStore.Get(s.Zone, inArg.JobID)
+	if err != nil {
+		log.Printf("[WARNING] - cannot read the job %s: %v", inArg.JobID, err)
+		return errors.New("cannot read the job\n")
+	}
+	if job == nil {
+		return errors.New("job " + inArg.JobID + " not found\n")
+	}
+	if job.Owner != inArg.ID {
+		return errors.New("the job belongs to another user\n")
+	}
+	if err = s.CronStore.Delete(s.Zone, inArg.JobID); err != nil {
+		log.Printf("[WARNING] - cannot delete the job %s: %v", inArg.JobID, err)
+		return errors.New("cannot delete the job\n")
+	}
+	*exitStatus = 0
+	return nil
+}
+
+// ListCronJobs returns the jobs of the user, only the ones on inArg.Tag if not empty
+func (s *Service) ListCronJobs(inArg *utilities.RequestArg, outList *[]utilities.CronJob) error {
+	if s.CronStore == nil {
+		return errCronDisabled
+	}
+	s.RwMtx.RLock()
+	_, exists := s.UsersIdMap[inArg.ID]
+	s.RwMtx.RUnlock()
+	if !exists {
+		return errors.New("invalid user id\n")
+	}
+	jobs, err := s.CronStore.Jobs(s.Zone)
+	if err != nil {
+		log.Printf("[WARNING] - cannot read the jobs: %v", err)
+		return errors.New("cannot read the jobs\n")
+	}
+	list := make([]utilities.CronJob, 0)
+	for _, job := range jobs {
+		if job.Owner == inArg.ID && (inArg.Tag == "" || inArg.Tag == job.Topic) {
+			list = append(list, job)
+		}
+	}
+	*outList = list
+	return nil
+}
+
+// RunCronJobs publishes the recurring publications of the zone until ctx is done.
+func RunCronJobs(ctx context.Context, s *Service) {
+	// This function must be called in a thread/goroutine
+	sess := utilities.NewSession()
+	holder := membership.Key(s.Peers.Self())
+	cronJobs.Run(ctx, s.CronStore, s.Zone, holder, func(job utilities.CronJob, run time.Time) error {
+		return s.publishRun(ctx, sess, job, run)
+	})
+}
+
+func (s *Service) publishRun(ctx context.Context, sess *session.Session, job utilities.CronJob, run time.Time) error {
+	url, err := s.topicQueueURL(job.Topic)
+	if err != nil {
+		return err
+	}
+	payload, err := cronJobs.Render(job, run)
+	if err != nil {
+		return err
+	}
+	e := envelope.New(job.Topic, job.Owner, s.Zone, payload)
+	e.ContentType = job.ContentType
+	e.Headers = job.Headers
+	if err = payloadCodec.Pack(e, job.Compression, s.Keys, job.KeyID); err != nil {
+		return err
+	}
+	if err = blobStorage.Offload(ctx, s.BlobStore, e, blobStorage.DefaultThreshold); err != nil {
+		return err
+	}
+
+	var groupID string
+	var deduplicationIDs []string
+	if strings.HasSuffix(url, fifoSuffix) {
+		// a run is published once even if two servers got the lease
+		groupID = job.ID
+		deduplicationIDs = []string{job.ID + "-" + strconv.FormatInt(run.Unix(), 10)}
+	}
+	failures, err := sqsManagement.SendMsgBatch(ctx, sess, &url, []*envelope.Envelope{e}, groupID, deduplicationIDs)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return errors.New(failures[0].Code + " " + failures[0].Message)
+	}
+	log.Printf("[INFO] - job %s published on %s", job.ID, job.Topic)
+	s.retainSent(job.Topic, e)
+	return nil
+}
+
+// topicQueueURL returns the queue of the topic, looking it up if another server of the zone created it
+func (s *Service) topicQueueURL(tag string) (string, error) {
+	url, err := s.lookupTopicQueue(tag)
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		return "", errors.New("topic " + tag + " has no queue")
+	}
+	return url, nil
+}
+
+// lookupTopicQueue is topicQueueURL, but returns an empty url if the topic has no queue
+func (s *Service) lookupTopicQueue(tag string) (string, error) {
+	s.RwMtx.RLock()
+	url, exists := s.URLQueueMap[tag]
+	queueName := s.queueName(tag)
+	s.RwMtx.RUnlock()
+	if exists {
+		return url, nil
+	}
+	sess := utilities.NewSession()
+	names := []string{queueName}
+	if !strings.HasSuffix(queueName, fifoSuffix) {
+		// the topic may have been created as FIFO
+		names = append(names, queueName+fifoSuffix)
+	}
+	url, _, err := lookupQueue(sess, tag, names...)
+	return url, err
+}
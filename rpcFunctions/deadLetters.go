@@ -0,0 +1,108 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+)
+
+// deadLetterQueue returns the url of the dead-letter queue of the topic if the user is subscribed to it.
+// The caller must hold the lock.
+func (s *Service) deadLetterQueue(inArg *utilities.RequestArg) (string, error) {
+	l, exists := s.UsersIdMap[inArg.ID]
+	if !exists {
+		return "", errors.New("invalid user id\n")
+	}
+	if !isSubscribed(l, inArg.Tag) {
+		return "", errors.New("a subscription must be done before")
+	}
+	url, exists := s.DeadLetterURLMap[inArg.Tag]
+	if !exists {
+		return "", errors.New("the topic has no dead-letter queue on this server\n")
+	}
+	return url, nil
+}
+
+// InspectDeadLetters returns how many messages of the topic ended into the dead-letter queue
+// and the body of some of them, without removing them
+func (s *Service) InspectDeadLetters(inArg *utilities.RequestArg, outArg *utilities.DeadLetterOutput) error {
+	s.RwMtx.RLock()
+	url, err := s.deadLetterQueue(inArg)
+	s.RwMtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	sess := utilities.NewSession()
+	outArg.QueueURL = url
+	outArg.Count, err = sqsManagement.CountMessages(sess, &url)
+	if err != nil {
+		return err
+	}
+
+	// a zero visibility timeout leaves the messages available
+	maxMessages, to, waitTime := int64(10), int64(0), int64(0)
+	msgResult, err := sqsManagement.ReceiveMessages(context.Background(), sess, &url, &maxMessages, &to, &waitTime)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgResult.Messages {
+		outArg.Messages = append(outArg.Messages, *msg.Body)
+	}
+	return nil
+}
+
+// RedriveDeadLetters moves the messages of the dead-letter queue back to the topic queue
+func (s *Service) RedriveDeadLetters(inArg *utilities.RequestArg, outMoved *int) error {
+	s.RwMtx.RLock()
+	dlq, err := s.deadLetterQueue(inArg)
+	url := s.URLQueueMap[inArg.Tag]
+	s.RwMtx.RUnlock()
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		return errors.New("the topic has no queue on this server\n")
+	}
+
+	sess := utilities.NewSession()
+	*outMoved = 0
+	maxMessages, to, waitTime := int64(10), int64(utilities.VisibilityTimeOut), int64(0)
+	for {
+		msgResult, err := sqsManagement.ReceiveMessages(context.Background(), sess, &dlq, &maxMessages, &to, &waitTime)
+		if err != nil {
+			return err
+		}
+		if len(msgResult.Messages) == 0 {
+			return nil
+		}
+		for _, msg := range msgResult.Messages {
+			if err = sqsManagement.ForwardMessage(sess, &url, msg); err != nil {
+				return err
+			}
+			// a failure here leaves a copy in the dead-letter queue, better than losing it
+			if err = sqsManagement.DeleteMessage(sess, &dlq, msg.ReceiptHandle); err != nil {
+				return err
+			}
+			*outMoved++
+		}
+	}
+}
+
+// PurgeDeadLetters deletes all the messages in the dead-letter queue of the topic
+func (s *Service) PurgeDeadLetters(inArg *utilities.RequestArg, exitStatus *int) error {
+	s.RwMtx.RLock()
+	url, err := s.deadLetterQueue(inArg)
+	s.RwMtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	sess := utilities.NewSession()
+	if err = sqsManagement.PurgeQueue(sess, &url); err != nil {
+		return err
+	}
+	*exitStatus = 0
+	return nil
+}
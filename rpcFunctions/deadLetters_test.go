@@ -0,0 +1,122 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// undelivered makes the user receive the messages of the topic without deleting them until they
+// are moved to the dead-letter queue, it returns how many times they have been received
+func undelivered(t *testing.T, cloud *awsFake.Cloud, s *Service, id, tag string) int {
+	t.Helper()
+	for i := 0; ; i++ {
+		var out utilities.MessagesOutput
+		if err := s.ReceiveMessages(&utilities.ReceiveArg{ID: id, Tag: tag, MaxMessages: 10, VisibilityTimeout: 30}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Messages) == 0 {
+			return i
+		}
+		cloud.Advance(31 * time.Second)
+	}
+}
+
+// inspect returns what InspectDeadLetters tells the user of the topic
+func inspect(t *testing.T, s *Service, id, tag string) utilities.DeadLetterOutput {
+	t.Helper()
+	var out utilities.DeadLetterOutput
+	if err := s.InspectDeadLetters(&utilities.RequestArg{ID: id, Tag: tag}, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDeadLetters(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	s.MaxReceiveCount = 2
+	var id string
+	if err := s.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var sub utilities.SubscriptionOutput
+	settings := &utilities.QueueSettings{ReceiveWaitTime: utilities.Int64(0)}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "news", Settings: settings}, &sub); err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", id, "eu", "poison")
+	if _, err := sqsManagement.SendMsgBatch(context.Background(), cloud.Session(), &sub.QueueURL, []*envelope.Envelope{e}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if received := undelivered(t, cloud, s, id, "news"); received != s.MaxReceiveCount {
+		t.Errorf("expected the message received %d times, got %d", s.MaxReceiveCount, received)
+	}
+	out := inspect(t, s, id, "news")
+	if out.Count != 1 || len(out.Messages) != 1 || !strings.Contains(out.Messages[0], "poison") {
+		t.Fatalf("expected the message in the dead-letter queue, got %d %q", out.Count, out.Messages)
+	}
+	// inspecting leaves the messages where they are
+	if out = inspect(t, s, id, "news"); out.Count != 1 {
+		t.Errorf("expected the message still in the dead-letter queue, got %d", out.Count)
+	}
+
+	var moved int
+	if err := s.RedriveDeadLetters(&utilities.RequestArg{ID: id, Tag: "news"}, &moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 {
+		t.Errorf("expected 1 message moved, got %d", moved)
+	}
+	if out = inspect(t, s, id, "news"); out.Count != 0 {
+		t.Errorf("expected the dead-letter queue empty, got %d", out.Count)
+	}
+	// back in the topic, until it fails again
+	if received := undelivered(t, cloud, s, id, "news"); received != s.MaxReceiveCount {
+		t.Errorf("expected the message received %d times again, got %d", s.MaxReceiveCount, received)
+	}
+
+	var status int
+	if err := s.PurgeDeadLetters(&utilities.RequestArg{ID: id, Tag: "news"}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if out = inspect(t, s, id, "news"); out.Count != 0 {
+		t.Errorf("expected the dead-letter queue purged, got %d", out.Count)
+	}
+}
+
+func TestDeadLettersErrors(t *testing.T) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	id, _ := subscribe(t, s, "news", "")
+	other, _ := subscribe(t, s, "sport", "")
+
+	tests := []struct {
+		id, tag string
+		err     string
+	}{
+		{"unknown", "news", "invalid user id"},
+		{other, "news", "a subscription must be done before"},
+		{id, "sport", "a subscription must be done before"},
+	}
+	for _, test := range tests {
+		var out utilities.DeadLetterOutput
+		if err := s.InspectDeadLetters(&utilities.RequestArg{ID: test.id, Tag: test.tag}, &out); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("inspect: expected %q, got %v", test.err, err)
+		}
+		var moved int
+		if err := s.RedriveDeadLetters(&utilities.RequestArg{ID: test.id, Tag: test.tag}, &moved); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("redrive: expected %q, got %v", test.err, err)
+		}
+		var status int
+		if err := s.PurgeDeadLetters(&utilities.RequestArg{ID: test.id, Tag: test.tag}, &status); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("purge: expected %q, got %v", test.err, err)
+		}
+	}
+}
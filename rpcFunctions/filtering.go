@@ -0,0 +1,185 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"time"
+)
+
+const (
+	filterRequeueDelay = 2  // seconds a message put back by a filter stays hidden, so that it is not received again at once
+	filterSyncBatch    = 50 // filters sent with a single notification to a server that joins
+)
+
+// ReceiveMessages receives the messages of the topic, or of the topics matched by a pattern,
+// on behalf of a subscriber and returns the ones passing its filter; the receiver deletes them
+// once processed, as usual.
+// A message that doesn't pass is put back in the queue if another subscriber of the zone may want
+// it, deleted otherwise. It goes back as a new message, so that the subscribers not wanting it don't
+// bring it closer to the dead-letter queue; on FIFO topics it loses its place in the group.
+func (s *Service) ReceiveMessages(inArg *utilities.ReceiveArg, outArg *utilities.MessagesOutput) error {
+	var targets []string
+	if topics.IsPattern(inArg.Tag) {
+		s.RwMtx.RLock()
+		l, exists := s.UsersIdMap[inArg.ID]
+		if !exists {
+			s.RwMtx.RUnlock()
+			return errors.New("invalid user id\n")
+		}
+		if !isSubscribed(l, inArg.Tag) {
+			s.RwMtx.RUnlock()
+			return errors.New("a subscription must be done before")
+		}
+		// only the topics with a queue on this server
+		targets = s.matchingTopics(inArg.Tag)
+		s.RwMtx.RUnlock()
+	} else {
+		var url string
+		// checks the subscription and finds the queue if it was made on another server
+		if err := s.GetQueueURL(&utilities.RequestArg{ID: inArg.ID, Tag: inArg.Tag}, &url); err != nil {
+			return err
+		}
+		s.RwMtx.RLock()
+		subscribed := isSubscribed(s.UsersIdMap[inArg.ID], inArg.Tag)
+		s.RwMtx.RUnlock()
+		if !subscribed {
+			// a publisher gets the queue URL but cannot consume the messages of the subscribers
+			return errors.New("a subscription must be done before")
+		}
+		targets = []string{inArg.Tag}
+	}
+
+	sess := utilities.NewSession()
+	for _, tag := range targets {
+		remaining := inArg.MaxMessages - int64(len(outArg.Messages))
+		if remaining <= 0 {
+			break
+		}
+		if err := s.receiveFiltered(sess, inArg.ID, tag, remaining, inArg.VisibilityTimeout, outArg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveFiltered receives up to maxMessages messages of the topic and appends to outArg
+// the ones passing the filter of the user
+func (s *Service) receiveFiltered(sess *session.Session, user, tag string, maxMessages, timeout int64, outArg *utilities.MessagesOutput) error {
+	s.RwMtx.RLock()
+	url := s.URLQueueMap[tag]
+	own, subscription := s.ownFilter(s.UsersIdMap[user], user, tag)
+	others, unfiltered := s.otherFilters(user, subscription, tag)
+	retention := time.Duration(s.topicSettings(tag).WithDefaults().MessageRetentionPeriod) * time.Second
+	s.RwMtx.RUnlock()
+	if url == "" {
+		return nil
+	}
+
+	msgResult, err := sqsManagement.GetMessages(sess, &url, &maxMessages, &timeout)
+	if err != nil {
+		return err
+	}
+	// every message received is logged, even the ones the filter hides
+	s.logDeliveries(tag, msgResult.Messages)
+	s.retainLast(tag, msgResult.Messages)
+
+	for _, msg := range msgResult.Messages {
+		e, err := envelope.Decode(msg)
+		if err != nil {
+			// let the subscriber see it, as without filters
+			outArg.Messages = append(outArg.Messages, msg)
+			outArg.QueueURLs = append(outArg.QueueURLs, url)
+			continue
+		}
+		attributes := messageFilter.Attributes(e)
+		if own.Match(attributes) {
+			outArg.Messages = append(outArg.Messages, msg)
+			outArg.QueueURLs = append(outArg.QueueURLs, url)
+			continue
+		}
+
+		wanted := unfiltered > 0
+		for i := 0; i < len(others) && !wanted; i++ {
+			wanted = others[i].Match(attributes)
+		}
+		// the copies would otherwise outlive the retention period of the queue
+		if wanted && time.Since(e.Timestamp) < retention {
+			if err = sqsManagement.RequeueMessage(sess, &url, msg, filterRequeueDelay); err != nil {
+				// it comes back after the visibility timeout
+				fmt.Println("Got an error putting back a filtered message:")
+				fmt.Println(err)
+				continue
+			}
+		}
+		if err = sqsManagement.DeleteMessage(sess, &url, msg.ReceiptHandle); err != nil {
+			fmt.Println("Got an error deleting a filtered message:")
+			fmt.Println(err)
+		}
+	}
+	return nil
+}
+
+// ApplyFilters records the filters of the subscriptions made on the other servers of the zone
+func (s *Service) ApplyFilters(filters []utilities.SubscriptionFilter) error {
+	s.RwMtx.Lock()
+	defer s.RwMtx.Unlock()
+	var invalid error
+	for _, f := range filters {
+		if f.Zone != s.Zone {
+			// the topics of another zone have other queues
+			continue
+		}
+		if f.Removed {
+			s.removeFilter(f.Tag, f.ID)
+			continue
+		}
+		filter, err := messageFilter.Parse(f.Filter)
+		if err != nil || filter == nil {
+			invalid = errors.New("invalid filter of " + f.ID + " on " + f.Tag)
+			continue
+		}
+		s.addFilter(f.Tag, f.ID, filter)
+	}
+	return invalid
+}
+
+// SyncPeer sends to a server of the zone that has just joined the subscriptions and their filters,
+// which it needs to tell whether a message is wanted, and the last values of the topics
+func (s *Service) SyncPeer(info utilities.ServerInfo) {
+	if info.Zone != s.Zone {
+		return
+	}
+	s.RwMtx.RLock()
+	users := make(map[string][]string, len(s.UsersIdMap))
+	for id, l := range s.UsersIdMap {
+		users[id] = append([]string(nil), l...)
+	}
+	var filters []utilities.SubscriptionFilter
+	for tag, byUser := range s.FiltersMap {
+		for id, filter := range byUser {
+			filters = append(filters, utilities.SubscriptionFilter{Zone: s.Zone, ID: id, Tag: tag, Filter: filter.String()})
+		}
+	}
+	s.RwMtx.RUnlock()
+
+	snsManagement.PublishUserListUpdate(users, &s.TopicARN)
+	for start := 0; start < len(filters); start += filterSyncBatch {
+		end := start + filterSyncBatch
+		if end > len(filters) {
+			end = len(filters)
+		}
+		snsManagement.PublishFilters(filters[start:end], &s.TopicARN)
+	}
+	for _, topic := range s.LastValues.Topics() {
+		if m, exists := s.LastValues.Get(topic); exists && m.Zone == s.Zone {
+			snsManagement.PublishRetained(m, &s.TopicARN)
+		}
+	}
+}
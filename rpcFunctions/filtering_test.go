@@ -0,0 +1,96 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"testing"
+	"time"
+)
+
+// subscribe makes a new user of s subscribe to the topic with the filter, it returns its id
+func subscribe(t *testing.T, s *Service, tag, filter string) (string, string) {
+	t.Helper()
+	var id string
+	if err := s.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var out utilities.SubscriptionOutput
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: tag, Filter: filter}, &out); err != nil {
+		t.Fatal(err)
+	}
+	return id, out.QueueURL
+}
+
+// hasFilter tells whether s knows the filter of the subscription of the user to the topic
+func hasFilter(s *Service, tag, id string) bool {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	return s.FiltersMap[tag][id] != nil
+}
+
+func TestFiltersShared(t *testing.T) {
+	cloud := newCloud(t)
+	first, second := newService(t, "eu", 1234), newService(t, "eu", 1235)
+	other := newService(t, "us", 1234)
+	for _, s := range []*Service{first, second, other} {
+		replicate(t, s)
+	}
+
+	europe, url := subscribe(t, first, "news", `{"region": ["EU"]}`)
+	eventually(t, "the filter on the other server", func() bool { return hasFilter(second, "news", europe) })
+	america, _ := subscribe(t, second, "news", `{"region": ["US"]}`)
+	eventually(t, "the filter on the first server", func() bool { return hasFilter(first, "news", america) })
+
+	e := envelope.New("news", europe, "eu", "hello")
+	e.Headers = map[string]string{"region": "EU"}
+	if _, err := sqsManagement.SendMsgBatch(context.Background(), cloud.Session(), &url, []*envelope.Envelope{e}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// not wanted by its subscriber, the second server puts it back for the first one
+	var out utilities.MessagesOutput
+	if err := second.ReceiveMessages(&utilities.ReceiveArg{ID: america, Tag: "news", MaxMessages: 10, VisibilityTimeout: 30}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Messages) != 0 {
+		t.Errorf("expected no message, got %d", len(out.Messages))
+	}
+	cloud.Advance(filterRequeueDelay * time.Second)
+	out = utilities.MessagesOutput{}
+	if err := first.ReceiveMessages(&utilities.ReceiveArg{ID: europe, Tag: "news", MaxMessages: 10, VisibilityTimeout: 30}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Messages) != 1 {
+		t.Errorf("expected the message put back, got %d messages", len(out.Messages))
+	}
+
+	if hasFilter(other, "news", europe) || hasFilter(other, "news", america) {
+		t.Errorf("expected the filters kept in their zone")
+	}
+}
+
+func TestFiltersSyncedToJoiningServer(t *testing.T) {
+	cloud := newCloud(t)
+	first := newService(t, "eu", 1234)
+	replicate(t, first)
+	id, _ := subscribe(t, first, "news", `{"region": ["EU"]}`)
+	// the new user, its subscription and its filter: the joining server learns them only from the sync
+	eventually(t, "the publications of the subscription", func() bool { return cloud.Calls("Publish") == 3 })
+
+	joining := newService(t, "eu", 1235)
+	replicate(t, joining)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go membership.Run(ctx, joining.Peers, &joining.TopicARN)
+
+	eventually(t, "the filter on the joining server", func() bool { return hasFilter(joining, "news", id) })
+
+	var status int
+	if err := first.DeleteSubscription(&utilities.RequestArg{ID: id, Tag: "news"}, &status); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the removal on the joining server", func() bool { return !hasFilter(joining, "news", id) })
+}
@@ -0,0 +1,177 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// retainedReleaseDelay is how long the payload of a replaced last value is kept
+const retainedReleaseDelay = time.Minute
+
+// retainLast makes the newest of the messages received from the topic its last value, if the
+// topic retains it. The messages of the producers reach the queue directly: a server sees them
+// only when it receives them.
+func (s *Service) retainLast(tag string, messages []*sqs.Message) {
+	s.RwMtx.RLock()
+	retainLast := s.topicSettings(tag).RetainLast
+	s.RwMtx.RUnlock()
+	if !retainLast {
+		return
+	}
+	var newest *sqs.Message
+	var newestTime time.Time
+	for _, msg := range messages {
+		if _, requeued := msg.MessageAttributes[sqsManagement.RequeuedAttribute]; requeued {
+			// considered when it was received the first time
+			continue
+		}
+		sent := time.Now()
+		if ms, err := strconv.ParseInt(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64); err == nil {
+			sent = time.Unix(0, ms*int64(time.Millisecond))
+		}
+		if newest == nil || sent.After(newestTime) {
+			newest, newestTime = msg, sent
+		}
+	}
+	if newest != nil {
+		s.setLastValue(tag, newest, newestTime)
+	}
+}
+
+// retainSent makes e, just sent to the topic by this server, its last value if the topic retains it
+func (s *Service) retainSent(tag string, e *envelope.Envelope) {
+	s.RwMtx.RLock()
+	retainLast := s.topicSettings(tag).RetainLast
+	s.RwMtx.RUnlock()
+	if !retainLast {
+		return
+	}
+	body, attributes, err := e.Encode()
+	if err != nil {
+		return
+	}
+	s.setLastValue(tag, &sqs.Message{MessageId: aws.String(e.MessageID), Body: aws.String(body), MessageAttributes: attributes}, time.Now())
+}
+
+// setLastValue makes msg, entered the topic at sent, the last value of the topic on the servers
+// of the zone, unless a newer one is known. An empty payload clears the value.
+// The subscribers delete the stored payload of a message once read: the value keeps a copy.
+func (s *Service) setLastValue(tag string, msg *sqs.Message, sent time.Time) {
+	m := utilities.RetainedMessage{Topic: tag, Zone: s.Zone, MessageID: aws.StringValue(msg.MessageId), Time: sent.UTC()}
+	e, err := envelope.Decode(msg)
+	if err != nil {
+		return
+	}
+	if e.MessageID != "" {
+		m.MessageID = e.MessageID
+	}
+	if old, exists := s.LastValues.Get(tag); exists && old.MessageID == m.MessageID {
+		// sent by this server and now received, or received again
+		return
+	}
+	if e.Payload != "" || e.PayloadRef != "" {
+		m.Body, m.Attributes = aws.StringValue(msg.Body), msg.MessageAttributes
+	}
+	if e.PayloadRef != "" {
+		ref, err := s.copyPayload(tag, e)
+		if err != nil {
+			log.Printf("[WARNING] - message %s not retained as last value of %s: %v", m.MessageID, tag, err)
+			return
+		}
+		e.PayloadRef = ref
+		if m.Body, m.Attributes, err = e.Encode(); err != nil {
+			s.releaseLastValue(&utilities.RetainedMessage{Topic: tag, PayloadRef: ref})
+			return
+		}
+		m.PayloadRef = ref
+	}
+
+	kept, old, err := s.LastValues.Set(m)
+	if err != nil {
+		log.Printf("[WARNING] - cannot store the last value of %s: %v", tag, err)
+	}
+	if !kept {
+		s.releaseLastValue(&m)
+		return
+	}
+	s.releaseLastValue(old)
+	go func() { snsManagement.PublishRetained(m, &s.TopicARN) }()
+}
+
+// copyPayload stores a copy of the payload of e, it returns its reference
+func (s *Service) copyPayload(tag string, e *envelope.Envelope) (string, error) {
+	if s.BlobStore == nil {
+		return "", errors.New("payload stored at " + e.PayloadRef + " and no blob store configured")
+	}
+	data, err := s.BlobStore.Get(context.Background(), e.PayloadRef)
+	if err != nil {
+		return "", err
+	}
+	// a message can be received by two servers, each one has its own copy
+	return s.BlobStore.Put(context.Background(), "retained/"+tag+"/"+e.MessageID+"-"+shortuuid.New(), data)
+}
+
+// releaseLastValue deletes the copy of the payload of a value no longer retained. The subscribers
+// that have just received it may still be reading it: it goes after retainedReleaseDelay.
+func (s *Service) releaseLastValue(m *utilities.RetainedMessage) {
+	if m == nil || m.PayloadRef == "" || s.BlobStore == nil {
+		return
+	}
+	ref, topic := m.PayloadRef, m.Topic
+	time.AfterFunc(retainedReleaseDelay, func() {
+		// every server of the zone drops the same value, the first delete wins
+		if err := s.BlobStore.Delete(context.Background(), ref); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[WARNING] - payload %s of the last value of %s not deleted: %v", ref, topic, err)
+		}
+	})
+}
+
+// ApplyRetained stores the last value of a topic retained by another server of the zone
+func (s *Service) ApplyRetained(m utilities.RetainedMessage) error {
+	if m.Zone != s.Zone {
+		// the topic of another zone, none of our subscribers receives its messages
+		return nil
+	}
+	kept, old, err := s.LastValues.Set(m)
+	if kept && (old == nil || old.MessageID != m.MessageID) {
+		s.releaseLastValue(old)
+	}
+	return err
+}
+
+// retainedFor returns the last values of the topics subscribed with tag, a topic or a pattern,
+// that pass the filter of the subscription
+func (s *Service) retainedFor(tag string, filter *messageFilter.Filter) []*sqs.Message {
+	var retained []*sqs.Message
+	for _, topic := range s.LastValues.Topics() {
+		if topic != tag && !topics.Match(tag, topic) {
+			continue
+		}
+		m, exists := s.LastValues.Get(topic)
+		if !exists {
+			continue
+		}
+		msg := &sqs.Message{MessageId: aws.String(m.MessageID), Body: aws.String(m.Body), MessageAttributes: m.Attributes}
+		if filter != nil {
+			e, err := envelope.Decode(msg)
+			if err != nil || !filter.Match(messageFilter.Attributes(e)) {
+				continue
+			}
+		}
+		retained = append(retained, msg)
+	}
+	return retained
+}
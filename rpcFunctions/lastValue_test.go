@@ -0,0 +1,74 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"testing"
+)
+
+// retains tells whether s knows the message as last value of the topic
+func retains(s *Service, tag, messageID string) bool {
+	m, exists := s.LastValues.Get(tag)
+	return exists && m.MessageID == messageID
+}
+
+func TestLastValueShared(t *testing.T) {
+	cloud := newCloud(t)
+	servers := []*Service{newService(t, "eu", 1234), newService(t, "eu", 1235), newService(t, "eu", 1236)}
+	other := newService(t, "us", 1234)
+	for _, s := range append(servers, other) {
+		replicate(t, s)
+	}
+
+	var id string
+	if err := servers[0].GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var out utilities.SubscriptionOutput
+	settings := &utilities.QueueSettings{RetainLast: true}
+	if err := servers[0].MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "news", Settings: settings}, &out); err != nil {
+		t.Fatal(err)
+	}
+	e := envelope.New("news", id, "eu", "hello")
+	if _, err := sqsManagement.SendMsgBatch(context.Background(), cloud.Session(), &out.QueueURL, []*envelope.Envelope{e}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	var received utilities.MessagesOutput
+	if err := servers[0].ReceiveMessages(&utilities.ReceiveArg{ID: id, Tag: "news", MaxMessages: 10, VisibilityTimeout: 30}, &received); err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Messages) != 1 {
+		t.Fatalf("expected the message received, got %d messages", len(received.Messages))
+	}
+
+	for _, s := range servers {
+		eventually(t, "the last value on every server of the zone", func() bool { return retains(s, "news", e.MessageID) })
+	}
+
+	joining := newService(t, "eu", 1237)
+	replicate(t, joining)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go membership.Run(ctx, joining.Peers, &joining.TopicARN)
+	eventually(t, "the last value on the joining server", func() bool { return retains(joining, "news", e.MessageID) })
+
+	// a new subscriber of the joining server gets it at once
+	var subscriber string
+	if err := joining.GenerateUserId(&utilities.RequestArg{}, &subscriber); err != nil {
+		t.Fatal(err)
+	}
+	out = utilities.SubscriptionOutput{}
+	if err := joining.MakeSubscriptionToTopic(&utilities.RequestArg{ID: subscriber, Tag: "news"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Retained) != 1 {
+		t.Errorf("expected the last value delivered to the new subscriber, got %d messages", len(out.Retained))
+	}
+
+	if _, exists := other.LastValues.Get("news"); exists {
+		t.Errorf("expected the last value kept in its zone")
+	}
+}
@@ -0,0 +1,184 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// CreateTopic creates the queue of a topic without subscribing to it.
+// The topic follows its EmptyPolicy from the start, having no subscribers yet.
+func (s *Service) CreateTopic(inArg *utilities.RequestArg, outInfo *utilities.TopicInfo) error {
+	s.RwMtx.Lock()
+	/*critical section, nobody can read while i'm writing*/
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		s.RwMtx.Unlock()
+		return errors.New("invalid user id\n")
+	}
+	if err := topics.Validate(inArg.Tag, false); err != nil {
+		s.RwMtx.Unlock()
+		return err
+	}
+	if _, exists := s.URLQueueMap[inArg.Tag]; exists {
+		s.RwMtx.Unlock()
+		return errors.New("topic " + inArg.Tag + " already exists\n")
+	}
+	if _, err := s.createTopic(inArg); err != nil {
+		s.RwMtx.Unlock()
+		return err
+	}
+	// the patterns matching the new topic subscribe to it
+	s.QueueSubscribersMap[inArg.Tag] = s.patternSubscribers(inArg.Tag)
+	if s.isIdle(inArg.Tag) && s.topicSettings(inArg.Tag).EmptyPolicy == utilities.GraceTopic {
+		s.EmptySinceMap[inArg.Tag] = time.Now()
+	}
+	*outInfo = s.topicInfo(inArg.Tag)
+	s.RwMtx.Unlock()
+	log.Printf("[INFO] - topic %s created by %s", inArg.Tag, inArg.ID)
+	return nil
+}
+
+// DeleteTopic deletes a topic with its unread messages whatever its EmptyPolicy,
+// cancelling the subscriptions and the publisher registrations to it.
+// Only the owner of the topic can delete it, a topic whose owner is unknown cannot be deleted.
+func (s *Service) DeleteTopic(inArg *utilities.RequestArg, exitStatus *int) error {
+	s.RwMtx.Lock()
+	/*critical section, nobody can read while i'm writing*/
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		s.RwMtx.Unlock()
+		return errors.New("invalid user id\n")
+	}
+	if _, exists := s.URLQueueMap[inArg.Tag]; !exists {
+		s.RwMtx.Unlock()
+		return errors.New("topic " + inArg.Tag + " not found\n")
+	}
+	if owner := s.CatalogMap[inArg.Tag].Owner; owner == "" {
+		// the catalog of this server doesn't know who created the topic
+		s.RwMtx.Unlock()
+		return errors.New("the owner of topic " + inArg.Tag + " is unknown, it cannot be deleted\n")
+	} else if owner != inArg.ID {
+		s.RwMtx.Unlock()
+		return errors.New("only the owner can delete topic " + inArg.Tag + "\n")
+	}
+	// the subscriptions through a pattern are kept, they match the topic if it is created again
+	for user, l := range s.UsersIdMap {
+		for i := 0; i < len(l); i++ {
+			if l[i] == inArg.Tag {
+				s.UsersIdMap[user] = append(l[:i], l[i+1:]...)
+				break
+			}
+		}
+	}
+	s.deleteTopic(inArg.Tag)
+	*exitStatus = 0
+	s.RwMtx.Unlock()
+	log.Printf("[INFO] - topic %s deleted by %s", inArg.Tag, inArg.ID)
+	go func() { snsManagement.PublishUserListUpdate(s.UsersIdMap, &s.TopicARN) }()
+	return nil
+}
+
+// createTopic creates the queues of a new topic with the settings and description of inArg,
+// inArg.ID becomes its owner. The caller must hold the write lock.
+func (s *Service) createTopic(inArg *utilities.RequestArg) (string, error) {
+	if inArg.Settings != nil {
+		// the settings are used only by who creates the topic
+		settings := inArg.Settings.WithDefaults()
+		if err := settings.Validate(); err != nil {
+			return "", err
+		}
+		s.SettingsMap[inArg.Tag] = settings
+	}
+	//follows dynamic creation of the queue
+	url, err := s.initQueue(inArg.Tag)
+	if err != nil {
+		delete(s.SettingsMap, inArg.Tag)
+		return "", err
+	}
+	s.URLQueueMap[inArg.Tag] = url
+	s.addToCatalog(inArg.Tag, inArg.Description, inArg.ID)
+	return url, nil
+}
+
+// isIdle tells whether the topic has neither subscribers nor publishers.
+// The caller must hold the lock.
+func (s *Service) isIdle(tag string) bool {
+	return s.QueueSubscribersMap[tag] <= 0 && len(s.PublishersMap[tag]) == 0
+}
+
+// topicEmptied applies the EmptyPolicy of a topic whose last subscriber or publisher has left.
+// The caller must hold the write lock.
+func (s *Service) topicEmptied(tag string) {
+	switch s.topicSettings(tag).EmptyPolicy {
+	case utilities.DeleteTopic:
+		s.deleteTopic(tag)
+	case utilities.GraceTopic:
+		s.EmptySinceMap[tag] = time.Now()
+	}
+}
+
+// deleteTopic deletes the queues of the topic and forgets about it.
+// The caller must hold the write lock.
+func (s *Service) deleteTopic(tag string) {
+	delete(s.QueueSubscribersMap, tag)
+	url := s.URLQueueMap[tag]
+	//deleting sqs-queue
+	deleteQueue(&url)
+	delete(s.URLQueueMap, tag)
+	if dlq, exists := s.DeadLetterURLMap[tag]; exists {
+		deleteQueue(&dlq)
+		delete(s.DeadLetterURLMap, tag)
+	}
+	delete(s.SettingsMap, tag)
+	delete(s.FiltersMap, tag)
+	delete(s.QueueNamesMap, topics.QueueName(tag, s.Zone))
+	delete(s.CatalogMap, tag)
+	delete(s.EmptySinceMap, tag)
+	delete(s.PublishersMap, tag)
+	if err := s.DeliveryLogs.Drop(tag); err != nil {
+		log.Printf("[WARNING] - cannot delete the delivery log of %s: %v", tag, err)
+	}
+	old, err := s.LastValues.Delete(tag)
+	if err != nil {
+		log.Printf("[WARNING] - cannot delete the last value of %s: %v", tag, err)
+	}
+	s.releaseLastValue(old)
+}
+
+// ReapTopics deletes, every ReaperInterval, the topics still empty at the end of their grace period
+// and the abandoned reply queues, and trims the retained logs. It returns when ctx is done.
+func ReapTopics(ctx context.Context, s *Service) {
+	// This function must be called in a thread/goroutine
+	ticker := time.NewTicker(utilities.ReaperInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reapTopics(time.Now())
+			s.reapReplyQueues(time.Now())
+			s.enforceRetention(time.Now())
+		}
+	}
+}
+
+func (s *Service) reapTopics(now time.Time) {
+	s.RwMtx.Lock()
+	defer s.RwMtx.Unlock()
+	for tag, since := range s.EmptySinceMap {
+		if !s.isIdle(tag) {
+			// somebody subscribed or registered as publisher in the meantime
+			delete(s.EmptySinceMap, tag)
+			continue
+		}
+		grace := time.Duration(s.topicSettings(tag).GracePeriod) * time.Second
+		if now.Sub(since) >= grace {
+			log.Printf("[INFO] - topic %s empty since %v, deleting it", tag, since.Format(time.RFC3339))
+			s.deleteTopic(tag)
+		}
+	}
+}
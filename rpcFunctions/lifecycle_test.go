@@ -0,0 +1,146 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/utilities"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hasQueues tells whether the queue of the topic and its dead-letter queue exist
+func hasQueues(cloud *awsFake.Cloud, name string) bool {
+	var found int
+	for _, queue := range cloud.Queues() {
+		if queue == name || queue == name+deadLetterSuffix {
+			found++
+		}
+	}
+	return found == 2
+}
+
+// hasTopic tells whether s knows the topic
+func hasTopic(s *Service, tag string) bool {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	_, exists := s.URLQueueMap[tag]
+	return exists
+}
+
+// newUser registers a new user on s
+func newUser(t *testing.T, s *Service) string {
+	t.Helper()
+	var id string
+	if err := s.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCreateTopic(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	owner := newUser(t, s)
+
+	var info utilities.TopicInfo
+	if err := s.CreateTopic(&utilities.RequestArg{ID: owner, Tag: "news", Description: "the news"}, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "news" || info.Owner != owner || info.Description != "the news" || info.Subscribers != 0 {
+		t.Errorf("unexpected entry of the topic: %+v", info)
+	}
+	if !hasQueues(cloud, "news_eu") {
+		t.Errorf("expected the queues of the topic, got %v", cloud.Queues())
+	}
+
+	tests := []struct {
+		id, tag string
+		err     string
+	}{
+		{"unknown", "sport", "invalid user id"},
+		{owner, "news", "already exists"},
+		{owner, "eu/*", ""},
+	}
+	for _, test := range tests {
+		err := s.CreateTopic(&utilities.RequestArg{ID: test.id, Tag: test.tag}, &info)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected %q, got %v", test.tag, test.err, err)
+		}
+	}
+}
+
+func TestDeleteTopic(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	owner, other := newUser(t, s), newUser(t, s)
+	var out utilities.SubscriptionOutput
+	keep := &utilities.QueueSettings{EmptyPolicy: utilities.KeepTopic}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: owner, Tag: "news", Settings: keep}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: other, Tag: "news"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var status int
+	if err := s.DeleteTopic(&utilities.RequestArg{ID: other, Tag: "news"}, &status); err == nil || !strings.Contains(err.Error(), "only the owner") {
+		t.Errorf("expected only the owner allowed, got %v", err)
+	}
+	if err := s.DeleteTopic(&utilities.RequestArg{ID: owner, Tag: "news"}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if hasTopic(s, "news") || hasQueues(cloud, "news_eu") {
+		t.Errorf("expected the topic deleted, got the queues %v", cloud.Queues())
+	}
+	// the subscriptions are cancelled
+	s.RwMtx.RLock()
+	for _, id := range []string{owner, other} {
+		if l := s.UsersIdMap[id]; len(l) != 0 {
+			t.Errorf("expected no subscription of %s, got %v", id, l)
+		}
+	}
+	s.RwMtx.RUnlock()
+	if err := s.DeleteTopic(&utilities.RequestArg{ID: owner, Tag: "news"}, &status); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the topic not found, got %v", err)
+	}
+}
+
+var emptyPolicyTests = []struct {
+	policy      string
+	resubscribe bool          // somebody subscribes again during the grace period
+	reapedAfter time.Duration // when the reaper runs after the last subscriber left
+	deleted     bool
+}{
+	{utilities.DeleteTopic, false, 0, true},
+	{utilities.KeepTopic, false, 24 * time.Hour, false},
+	{utilities.GraceTopic, false, 30 * time.Second, false},
+	{utilities.GraceTopic, false, 61 * time.Second, true},
+	{utilities.GraceTopic, true, 61 * time.Second, false},
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	for _, test := range emptyPolicyTests {
+		newCloud(t)
+		s := newService(t, "eu", 1234)
+		id := newUser(t, s)
+		var out utilities.SubscriptionOutput
+		settings := &utilities.QueueSettings{EmptyPolicy: test.policy, GracePeriod: 60}
+		if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "news", Settings: settings}, &out); err != nil {
+			t.Fatal(err)
+		}
+		var status int
+		if err := s.DeleteSubscription(&utilities.RequestArg{ID: id, Tag: "news"}, &status); err != nil {
+			t.Fatal(err)
+		}
+		left := time.Now()
+		if test.resubscribe {
+			if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: newUser(t, s), Tag: "news"}, &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.reapTopics(left.Add(test.reapedAfter))
+		if deleted := !hasTopic(s, "news"); deleted != test.deleted {
+			t.Errorf("%s reaped after %v: expected deleted %v, got %v", test.policy, test.reapedAfter, test.deleted, deleted)
+		}
+	}
+}
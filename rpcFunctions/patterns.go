@@ -0,0 +1,103 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/topics"
+	"sort"
+)
+
+// isSubscribed tells whether the subscriptions l include the topic, directly or through a pattern
+func isSubscribed(l []string, tag string) bool {
+	for i := 0; i < len(l); i++ {
+		if l[i] == tag || topics.Match(l[i], tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownFilter returns the filter of the subscription of the user the topic is received with:
+// the direct one if any, otherwise the first pattern matching it.
+// The caller must hold the lock.
+func (s *Service) ownFilter(l []string, user, tag string) (*messageFilter.Filter, string) {
+	for i := 0; i < len(l); i++ {
+		if l[i] == tag {
+			return s.FiltersMap[tag][user], tag
+		}
+	}
+	for i := 0; i < len(l); i++ {
+		if topics.Match(l[i], tag) {
+			return s.FiltersMap[l[i]][user], l[i]
+		}
+	}
+	return nil, tag
+}
+
+// otherFilters returns the filters of the subscriptions to the topic, direct or through a pattern,
+// except the subscription of user made with subscription, and how many of them have no filter.
+// The subscriptions and their filters are replicated: the ones made on the other servers of the
+// zone are counted too.
+// The caller must hold the lock.
+func (s *Service) otherFilters(user, subscription, topic string) ([]*messageFilter.Filter, int) {
+	var others []*messageFilter.Filter
+	unfiltered := 0
+	for u, l := range s.UsersIdMap {
+		for _, tag := range l {
+			if tag != topic && !(topics.IsPattern(tag) && topics.Match(tag, topic)) {
+				continue
+			}
+			if u == user && tag == subscription {
+				continue
+			}
+			if filter := s.FiltersMap[tag][u]; filter != nil {
+				others = append(others, filter)
+			} else {
+				unfiltered++
+			}
+		}
+	}
+	return others, unfiltered
+}
+
+// addFilter records the filter of the subscription of user to tag, a topic or a pattern.
+// The caller must hold the write lock.
+func (s *Service) addFilter(tag, user string, filter *messageFilter.Filter) {
+	if s.FiltersMap[tag] == nil {
+		s.FiltersMap[tag] = make(map[string]*messageFilter.Filter)
+	}
+	s.FiltersMap[tag][user] = filter
+}
+
+// removeFilter forgets the filter of the subscription of user to tag.
+// The caller must hold the write lock.
+func (s *Service) removeFilter(tag, user string) {
+	delete(s.FiltersMap[tag], user)
+	if len(s.FiltersMap[tag]) == 0 {
+		delete(s.FiltersMap, tag)
+	}
+}
+
+// patternSubscribers returns how many pattern subscriptions match the topic.
+// The caller must hold the lock.
+func (s *Service) patternSubscribers(topic string) int {
+	n := 0
+	for pattern, subscribers := range s.PatternsMap {
+		if topics.Match(pattern, topic) {
+			n += subscribers
+		}
+	}
+	return n
+}
+
+// matchingTopics returns, sorted, the topics having a queue on this server matched by pattern.
+// The caller must hold the lock.
+func (s *Service) matchingTopics(pattern string) []string {
+	var l []string
+	for tag := range s.URLQueueMap {
+		if topics.Match(pattern, tag) {
+			l = append(l, tag)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
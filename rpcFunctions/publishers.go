@@ -0,0 +1,84 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"errors"
+	"log"
+)
+
+// RegisterPublisher lets a user send to a topic without subscribing to it, the topic is
+// created if needed. The registration keeps the queue alive like a subscription does
+// but it is known only to this server.
+func (s *Service) RegisterPublisher(inArg *utilities.RequestArg, outArg *utilities.SubscriptionOutput) error {
+	s.RwMtx.Lock()
+	/*critical section, nobody can read while i'm writing*/
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		s.RwMtx.Unlock()
+		return errors.New("invalid user id\n")
+	}
+	if err := topics.Validate(inArg.Tag, false); err != nil {
+		s.RwMtx.Unlock()
+		return err
+	}
+	if s.isPublisher(inArg.ID, inArg.Tag) {
+		s.RwMtx.Unlock()
+		return errors.New("publisher already registered\n")
+	}
+
+	url, exists := s.URLQueueMap[inArg.Tag]
+	if !exists {
+		var err error
+		if url, err = s.createTopic(inArg); err != nil {
+			s.RwMtx.Unlock()
+			return err
+		}
+		// the patterns matching the new topic subscribe to it
+		s.QueueSubscribersMap[inArg.Tag] = s.patternSubscribers(inArg.Tag)
+	}
+	s.PublishersMap[inArg.Tag] = append(s.PublishersMap[inArg.Tag], inArg.ID)
+	outArg.QueueURL = url
+	outArg.Settings = s.topicSettings(inArg.Tag)
+	s.RwMtx.Unlock()
+	log.Printf("[INFO] - %s registered as publisher of %s", inArg.ID, inArg.Tag)
+	return nil
+}
+
+// UnregisterPublisher cancels a publisher registration, the topic follows its EmptyPolicy
+// when nobody else is using it.
+func (s *Service) UnregisterPublisher(inArg *utilities.RequestArg, exitStatus *int) error {
+	s.RwMtx.Lock()
+	/*critical section, nobody can read while i'm writing*/
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		s.RwMtx.Unlock()
+		return errors.New("invalid user id\n")
+	}
+	l := s.PublishersMap[inArg.Tag]
+	for i := 0; i < len(l); i++ {
+		if l[i] == inArg.ID {
+			s.PublishersMap[inArg.Tag] = append(l[:i], l[i+1:]...)
+			if len(s.PublishersMap[inArg.Tag]) == 0 {
+				delete(s.PublishersMap, inArg.Tag)
+			}
+			if s.isIdle(inArg.Tag) {
+				//no more producers,  no more subscribers are still interested in this queue
+				s.topicEmptied(inArg.Tag)
+			}
+			break
+		}
+	}
+	*exitStatus = 0
+	s.RwMtx.Unlock()
+	return nil
+}
+
+// isPublisher tells whether user is registered as publisher of the topic.
+// The caller must hold the lock.
+func (s *Service) isPublisher(user, tag string) bool {
+	for _, publisher := range s.PublishersMap[tag] {
+		if publisher == user {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/utilities"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPublisher(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	publisher := newUser(t, s)
+
+	var out utilities.SubscriptionOutput
+	if err := s.RegisterPublisher(&utilities.RequestArg{ID: publisher, Tag: "news"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if queueName(out.QueueURL) != "news_eu" || !hasQueues(cloud, "news_eu") {
+		t.Errorf("expected the topic created, got %q and the queues %v", out.QueueURL, cloud.Queues())
+	}
+	if err := s.RegisterPublisher(&utilities.RequestArg{ID: publisher, Tag: "news"}, &out); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("expected the publisher already registered, got %v", err)
+	}
+
+	// it can send, not receive
+	var url string
+	if err := s.GetQueueURL(&utilities.RequestArg{ID: publisher, Tag: "news"}, &url); err != nil || url != out.QueueURL {
+		t.Errorf("expected %q, got %q %v", out.QueueURL, url, err)
+	}
+	var received utilities.MessagesOutput
+	if err := s.ReceiveMessages(&utilities.ReceiveArg{ID: publisher, Tag: "news", MaxMessages: 10}, &received); err == nil {
+		t.Errorf("expected a publisher refused to receive")
+	}
+	var info utilities.TopicInfo
+	if err := s.DescribeTopic(&utilities.RequestArg{ID: publisher, Tag: "news"}, &info); err != nil || info.Publishers != 1 || info.Subscribers != 0 {
+		t.Errorf("expected 1 publisher and no subscriber, got %+v %v", info, err)
+	}
+
+	tests := []struct {
+		id, tag string
+		err     string
+	}{
+		{"unknown", "sport", "invalid user id"},
+		{publisher, "eu/*", ""},
+	}
+	for _, test := range tests {
+		if err := s.RegisterPublisher(&utilities.RequestArg{ID: test.id, Tag: test.tag}, &out); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected %q, got %v", test.tag, test.err, err)
+		}
+	}
+}
+
+// a publisher registration keeps the topic alive like a subscription
+func TestUnregisterPublisher(t *testing.T) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	publisher, subscriber := newUser(t, s), newUser(t, s)
+	var out utilities.SubscriptionOutput
+	if err := s.RegisterPublisher(&utilities.RequestArg{ID: publisher, Tag: "news"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: subscriber, Tag: "news"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var status int
+	if err := s.DeleteSubscription(&utilities.RequestArg{ID: subscriber, Tag: "news"}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if !hasTopic(s, "news") {
+		t.Fatalf("expected the topic kept by its publisher")
+	}
+	if err := s.UnregisterPublisher(&utilities.RequestArg{ID: publisher, Tag: "news"}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if hasTopic(s, "news") {
+		t.Errorf("expected the topic deleted with its last publisher")
+	}
+	var url string
+	if err := s.GetQueueURL(&utilities.RequestArg{ID: publisher, Tag: "news"}, &url); err == nil {
+		t.Errorf("expected the registration cancelled")
+	}
+}
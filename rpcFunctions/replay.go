@@ -0,0 +1,133 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/retainedLog"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Replay returns the messages of the delivery log of the topic on this server, from inArg.Offset
+// or from inArg.Since. The messages are not consumed: nothing has to be deleted afterwards.
+// It is not a log of the topic: every server logs only the messages it has handed out to the
+// subscribers, numbered by its own offsets. The other servers have other messages under the same offsets.
+func (s *Service) Replay(inArg *utilities.ReplayArg, outArg *utilities.ReplayOutput) error {
+	s.RwMtx.RLock()
+	l, exists := s.UsersIdMap[inArg.ID]
+	subscribed := exists && isSubscribed(l, inArg.Tag)
+	retained := s.topicSettings(inArg.Tag).Retain
+	s.RwMtx.RUnlock()
+	if !exists {
+		return errors.New("invalid user id\n")
+	}
+	if !subscribed {
+		return errors.New("a subscription must be done before")
+	}
+	if !retained {
+		return errors.New("topic " + inArg.Tag + " does not retain its messages\n")
+	}
+
+	max := int(inArg.MaxMessages)
+	if max <= 0 || max > utilities.MaxReplay {
+		max = utilities.MaxReplay
+	}
+	topicLog, err := s.DeliveryLogs.Get(inArg.Tag)
+	if err != nil {
+		log.Printf("[WARNING] - cannot open the delivery log of %s: %v", inArg.Tag, err)
+		return errors.New("cannot read the delivery log of the topic\n")
+	}
+	var records []retainedLog.Record
+	if inArg.Since.IsZero() {
+		records, outArg.NextOffset, err = topicLog.Read(inArg.Offset, max)
+	} else {
+		records, outArg.NextOffset, err = topicLog.ReadSince(inArg.Since, max)
+	}
+	if err == retainedLog.ErrOffsetTrimmed {
+		return errors.New("offset " + strconv.FormatInt(inArg.Offset, 10) + " no longer retained, the oldest is " + strconv.FormatInt(outArg.NextOffset, 10) + "\n")
+	}
+	if err != nil {
+		log.Printf("[WARNING] - cannot read the delivery log of %s: %v", inArg.Tag, err)
+		return errors.New("cannot read the delivery log of the topic\n")
+	}
+	for _, r := range records {
+		outArg.Messages = append(outArg.Messages, &sqs.Message{
+			MessageId:         aws.String(r.MessageID),
+			Body:              aws.String(r.Body),
+			MessageAttributes: r.Attributes,
+		})
+		outArg.Offsets = append(outArg.Offsets, r.Offset)
+	}
+	return nil
+}
+
+// logDeliveries appends to the delivery log of the topic, if it has one, the messages this server
+// received from its queue. A message received again, because released by a filter or not
+// deleted in time, is logged once.
+func (s *Service) logDeliveries(tag string, messages []*sqs.Message) {
+	s.RwMtx.RLock()
+	retained := s.topicSettings(tag).Retain
+	s.RwMtx.RUnlock()
+	if !retained || len(messages) == 0 {
+		return
+	}
+	topicLog, err := s.DeliveryLogs.Get(tag)
+	if err != nil {
+		log.Printf("[WARNING] - cannot open the delivery log of %s: %v", tag, err)
+		return
+	}
+	now := time.Now()
+	for _, msg := range messages {
+		if _, requeued := msg.MessageAttributes[sqsManagement.RequeuedAttribute]; requeued {
+			// logged when it was received the first time
+			continue
+		}
+		id := *msg.MessageId
+		if e, err := envelope.Decode(msg); err == nil && e.MessageID != "" {
+			// unlike the sqs id it survives a redrive
+			id = e.MessageID
+		}
+		if _, err = topicLog.Append(id, *msg.Body, msg.MessageAttributes, now); err != nil {
+			log.Printf("[WARNING] - message %s not kept in the delivery log of %s: %v", id, tag, err)
+		}
+	}
+}
+
+// enforceRetention trims the open delivery logs according to the retention of their topic. The subscribers
+// don't delete the stored payloads of these topics, they go with the records trimmed.
+func (s *Service) enforceRetention(now time.Time) {
+	for _, tag := range s.DeliveryLogs.Open() {
+		s.RwMtx.RLock()
+		settings := s.topicSettings(tag)
+		s.RwMtx.RUnlock()
+		topicLog, err := s.DeliveryLogs.Get(tag)
+		if err != nil {
+			continue
+		}
+		err = topicLog.Enforce(time.Duration(settings.RetentionTime)*time.Second, settings.RetentionBytes, now, func(r retainedLog.Record) {
+			s.releaseRecord(tag, r)
+		})
+		if err != nil {
+			log.Printf("[WARNING] - cannot trim the delivery log of %s: %v", tag, err)
+		}
+	}
+}
+
+// releaseRecord deletes the stored payload of a record trimmed from the delivery log of the topic.
+// The last value of the topic has a copy of its own.
+func (s *Service) releaseRecord(tag string, r retainedLog.Record) {
+	e, err := envelope.Decode(&sqs.Message{MessageId: aws.String(r.MessageID), Body: aws.String(r.Body), MessageAttributes: r.Attributes})
+	if err != nil || e.PayloadRef == "" {
+		return
+	}
+	if err = blobStorage.Release(context.Background(), s.BlobStore, e); err != nil {
+		log.Printf("[WARNING] - payload %s of %s not deleted: %v", e.PayloadRef, tag, err)
+	}
+}
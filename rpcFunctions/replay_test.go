@@ -0,0 +1,166 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/retainedLog"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delivered makes a new user of s subscribe to the topic logging its deliveries, and receive
+// the payloads sent to it; it returns its id
+func delivered(t *testing.T, s *Service, tag string, settings *utilities.QueueSettings, payloads ...string) string {
+	t.Helper()
+	var id string
+	if err := s.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var out utilities.SubscriptionOutput
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: tag, Settings: settings}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var envelopes []*envelope.Envelope
+	for _, payload := range payloads {
+		envelopes = append(envelopes, envelope.New(tag, id, s.Zone, payload))
+	}
+	if len(envelopes) == 0 {
+		return id
+	}
+	if _, err := sqsManagement.SendMsgBatch(context.Background(), utilities.NewSession(), &out.QueueURL, envelopes, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	var received utilities.MessagesOutput
+	if err := s.ReceiveMessages(&utilities.ReceiveArg{ID: id, Tag: tag, MaxMessages: 10, VisibilityTimeout: 30}, &received); err != nil {
+		t.Fatal(err)
+	}
+	if len(received.Messages) != len(payloads) {
+		t.Fatalf("expected %d messages received, got %d", len(payloads), len(received.Messages))
+	}
+	return id
+}
+
+// replayed returns the payloads of the messages of the page
+func replayed(t *testing.T, out *utilities.ReplayOutput) []string {
+	t.Helper()
+	var list []string
+	for _, msg := range out.Messages {
+		e, err := envelope.Decode(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, e.Payload)
+	}
+	return list
+}
+
+var replayTests = []struct {
+	offset     int64
+	max        int64
+	payloads   []string
+	nextOffset int64
+}{
+	{0, 0, []string{"a", "b", "c"}, 3},
+	{1, 0, []string{"b", "c"}, 3},
+	{0, 2, []string{"a", "b"}, 2},
+	{3, 0, nil, 3},
+}
+
+func TestReplay(t *testing.T) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	id := delivered(t, s, "news", &utilities.QueueSettings{Retain: true}, "a", "b", "c")
+
+	for _, test := range replayTests {
+		var out utilities.ReplayOutput
+		if err := s.Replay(&utilities.ReplayArg{ID: id, Tag: "news", Offset: test.offset, MaxMessages: test.max}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if got := replayed(t, &out); strings.Join(got, ",") != strings.Join(test.payloads, ",") {
+			t.Errorf("offset %d max %d: expected %q, got %q", test.offset, test.max, test.payloads, got)
+		}
+		for i, offset := range out.Offsets {
+			if offset != test.offset+int64(i) {
+				t.Errorf("offset %d max %d: expected the offset %d, got %d", test.offset, test.max, test.offset+int64(i), offset)
+			}
+		}
+		if out.NextOffset != test.nextOffset {
+			t.Errorf("offset %d max %d: expected the next offset %d, got %d", test.offset, test.max, test.nextOffset, out.NextOffset)
+		}
+	}
+
+	// the messages are not consumed, the log has them from the time they were received
+	var out utilities.ReplayOutput
+	if err := s.Replay(&utilities.ReplayArg{ID: id, Tag: "news", Since: time.Now().Add(-time.Minute)}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := replayed(t, &out); len(got) != 3 {
+		t.Errorf("expected the 3 messages since a minute ago, got %q", got)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	id := delivered(t, s, "plain", nil)
+	delivered(t, s, "news", &utilities.QueueSettings{Retain: true})
+
+	tests := []struct {
+		id, tag string
+		err     string
+	}{
+		{"unknown", "news", "invalid user id"},
+		{id, "news", "a subscription must be done before"},
+		{id, "plain", "does not retain its messages"},
+	}
+	for _, test := range tests {
+		var out utilities.ReplayOutput
+		err := s.Replay(&utilities.ReplayArg{ID: test.id, Tag: test.tag}, &out)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected %q, got %v", test.err, err)
+		}
+	}
+}
+
+// the records trimmed by the retention of the topic can no longer be replayed
+func TestReplayTrimmed(t *testing.T) {
+	newCloud(t)
+	s := newService(t, "eu", 1234)
+	id := delivered(t, s, "news", &utilities.QueueSettings{Retain: true, RetentionBytes: 1})
+
+	// enough records for two segments besides the one being written
+	topicLog, err := s.DeliveryLogs.Get("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("x", 512<<10)
+	var i int64
+	for ; i*int64(len(body)) < 2*retainedLog.SegmentSize+int64(len(body)); i++ {
+		if _, err = topicLog.Append(strconv.FormatInt(i, 10), body, nil, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.enforceRetention(time.Now())
+	var out utilities.ReplayOutput
+	err = s.Replay(&utilities.ReplayArg{ID: id, Tag: "news", MaxMessages: 1}, &out)
+	if err == nil || !strings.Contains(err.Error(), "offset 0 no longer retained") {
+		t.Fatalf("expected the offset 0 trimmed, got %v", err)
+	}
+	oldest := out.NextOffset
+	if oldest <= 0 || oldest >= i {
+		t.Fatalf("expected the oldest offset between 1 and %d, got %d", i-1, oldest)
+	}
+
+	out = utilities.ReplayOutput{}
+	if err = s.Replay(&utilities.ReplayArg{ID: id, Tag: "news", Offset: oldest, MaxMessages: 1}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Offsets) != 1 || out.Offsets[0] != oldest {
+		t.Errorf("expected the record %d, got %v", oldest, out.Offsets)
+	}
+}
@@ -0,0 +1,189 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"log"
+	"strconv"
+	"time"
+)
+
+type UpdateInfo struct {
+	ID     string   `json:"ID"`
+	Topics []string `json:"Topics"`
+}
+
+// notification is the envelope SNS wraps around the messages published on the master topic
+type notification struct {
+	Type      string `json:"Type"`
+	MessageId string `json:"MessageId"`
+	Subject   string `json:"Subject"`
+	Message   string `json:"Message"`
+}
+
+const (
+	replicationBatch      = 10 // messages received with a single call
+	replicationWaitTime   = 20 // seconds of long polling
+	replicationVisibility = 30 // seconds a received message is hidden to the other consumers
+	replicationAttempts   = 5  // deliveries of a message failing to apply before discarding it
+	minBackoff            = time.Second
+	maxBackoff            = time.Minute
+)
+
+// errUndecodable marks the messages that would fail the same way at every delivery
+var errUndecodable = errors.New("undecodable message")
+
+// LookForMessages consumes the notifications coming from the other servers until ctx is done.
+// A message is deleted once it has been applied, or right away if it cannot be decoded.
+// A message failing to apply is left on the queue and applied again when it becomes visible,
+// up to replicationAttempts deliveries.
+func LookForMessages(ctx context.Context, s *Service) {
+	// This function must be called in a thread/goroutine
+	sess := utilities.NewSession()
+	maxMessages := int64(replicationBatch)
+	waitTime := int64(replicationWaitTime)
+	to := int64(replicationVisibility)
+	backoff := minBackoff
+	for {
+		msgResult, err := sqsManagement.ReceiveMessages(ctx, sess, &s.QueueURL, &maxMessages, &to, &waitTime)
+		if ctx.Err() != nil {
+			log.Println("[INFO] - replication stopped")
+			return
+		}
+		if err != nil {
+			log.Printf("[WARNING] - error receiving replication messages, retrying in %v: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				log.Println("[INFO] - replication stopped")
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = minBackoff
+
+		var handles []*string
+		var applied []*sqs.Message
+		for _, msg := range msgResult.Messages {
+			if err = s.applyMessage(msg); err != nil {
+				if !errors.Is(err, errUndecodable) && receiveCount(msg) < replicationAttempts {
+					// it becomes visible again after the visibility timeout
+					log.Printf("[WARNING] - replication message %s not applied, retrying later: %v", *msg.MessageId, err)
+					continue
+				}
+				log.Printf("[WARNING] - discarding replication message %s: %v\n%s", *msg.MessageId, err, *msg.Body)
+			}
+			handles = append(handles, msg.ReceiptHandle)
+			applied = append(applied, msg)
+		}
+		if len(handles) == 0 {
+			continue
+		}
+		//otherwise the messages return visible after the visibility timeout and are applied again
+		// not ctx: the messages have been applied, stopping now would apply them again
+		failures, err := sqsManagement.DeleteMsgBatch(context.Background(), sess, &s.QueueURL, handles)
+		if err != nil {
+			fmt.Println("Got an error deleting the messages:")
+			fmt.Println(err)
+		}
+		for _, f := range failures {
+			log.Printf("[WARNING] - replication message %s not deleted: %s %s", *applied[f.Index].MessageId, f.Code, f.Message)
+		}
+	}
+}
+
+// receiveCount is how many times msg has been received, counting this time
+func receiveCount(msg *sqs.Message) int {
+	n, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil {
+		// unknown: do not retry forever
+		return replicationAttempts
+	}
+	return n
+}
+
+// applyMessage decodes a notification and merges it into the state of the server.
+// Applying twice the same notification has no further effect.
+func (s *Service) applyMessage(msg *sqs.Message) error {
+	var n notification
+	if err := json.Unmarshal([]byte(*msg.Body), &n); err != nil {
+		return fmt.Errorf("%w: %v", errUndecodable, err)
+	}
+
+	switch n.Subject {
+	case snsManagement.HeartbeatSubject:
+		var info utilities.ServerInfo
+		if err := json.Unmarshal([]byte(n.Message), &info); err != nil {
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+		if s.Peers.Update(info) {
+			// it knows nothing of what happened before it joined
+			go s.SyncPeer(info)
+		}
+	case snsManagement.RetainedSubject:
+		var m utilities.RetainedMessage
+		if err := json.Unmarshal([]byte(n.Message), &m); err != nil {
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+		return s.ApplyRetained(m)
+	case snsManagement.FiltersSubject:
+		var filters []utilities.SubscriptionFilter
+		if err := json.Unmarshal([]byte(n.Message), &filters); err != nil {
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+		if err := s.ApplyFilters(filters); err != nil {
+			// the valid ones have been applied, the others would fail again
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+	case snsManagement.UserListSubject, "":
+		var updates []UpdateInfo
+		if err := json.Unmarshal([]byte(n.Message), &updates); err != nil {
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+		s.mergeUpdates(updates)
+	default:
+		return fmt.Errorf("%w: unknown subject %s", errUndecodable, n.Subject)
+	}
+	return nil
+}
+
+func (s *Service) mergeUpdates(updates []UpdateInfo) {
+	s.RwMtx.Lock()
+	for i := 0; i < len(updates); i++ {
+		// per ogni id dall'esterno vedo la lista corrispondente se esiste
+		if myList, ok := s.UsersIdMap[updates[i].ID]; ok {
+			//per ogni topic nella lista updates
+			for j := 0; j < len(updates[i].Topics); j++ {
+				//vedo se ne sono gi a conoscenza
+				if !Contains(myList, updates[i].Topics[j]) {
+					myList = append(myList, updates[i].Topics[j])
+				}
+			}
+			s.UsersIdMap[updates[i].ID] = myList
+		} else {
+			s.UsersIdMap[updates[i].ID] = updates[i].Topics
+		}
+	}
+	s.RwMtx.Unlock()
+}
+
+// Contains tells whether a contains x.
+func Contains(a []string, x string) bool {
+	for _, n := range a {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/retainedLog"
+	"SDCC-A3-Project/scheduler"
+	"SDCC-A3-Project/snsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCloud makes the sessions of the package talk with an account held in memory
+func newCloud(t *testing.T) *awsFake.Cloud {
+	cloud := awsFake.New()
+	old := utilities.NewSession
+	utilities.NewSession = cloud.Session
+	t.Cleanup(func() { utilities.NewSession = old })
+	return cloud
+}
+
+// newService returns a server of the zone listening on the master topic, like initServer does
+func newService(t *testing.T, zone string, port int) *Service {
+	dir := t.TempDir()
+	s := new(Service)
+	s.URLQueueMap = make(map[string]string)
+	s.UsersIdMap = make(map[string][]string)
+	s.QueueSubscribersMap = make(map[string]int)
+	s.DeadLetterURLMap = make(map[string]string)
+	s.SettingsMap = make(map[string]utilities.QueueSettings)
+	s.FiltersMap = make(map[string]map[string]*messageFilter.Filter)
+	s.PatternsMap = make(map[string]int)
+	s.QueueNamesMap = make(map[string]string)
+	s.CatalogMap = make(map[string]utilities.TopicInfo)
+	s.EmptySinceMap = make(map[string]time.Time)
+	s.PublishersMap = make(map[string][]string)
+	s.MaxReceiveCount = utilities.MaxReceiveCount
+	s.Zone = zone
+	var err error
+	if s.Scheduler, err = scheduler.Open(filepath.Join(dir, "scheduled.json")); err != nil {
+		t.Fatal(err)
+	}
+	s.DeliveryLogs = retainedLog.NewLogs(filepath.Join(dir, "logs"))
+	t.Cleanup(func() { s.DeliveryLogs.Close() })
+	if s.LastValues, err = retainedLog.OpenLastValues(filepath.Join(dir, "values", "last-values.json")); err != nil {
+		t.Fatal(err)
+	}
+	self := utilities.ServerInfo{Zone: zone, Address: "127.0.0.1", Port: port, StartTime: time.Now()}
+	snsManagement.SnsToSqsConfig(&s.QueueURL, &s.TopicARN, &s.SubscriptionARN, self)
+	s.Peers = membership.NewView(self)
+	return s
+}
+
+// replicate runs the replication loop of s until the end of the test
+func replicate(t *testing.T, s *Service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		LookForMessages(ctx, s)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// eventually fails the test if cond does not become true within a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func queueName(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+func TestLookForMessagesApplies(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	peer := utilities.ServerInfo{Zone: "eu", Address: "127.0.0.2", Port: 1234, StartTime: time.Now()}
+	snsManagement.PublishHeartbeat(peer, &s.TopicARN)
+	snsManagement.PublishUserListUpdate(map[string][]string{"user": {"news"}}, &s.TopicARN)
+	replicate(t, s)
+
+	eventually(t, "the heartbeat", func() bool { return len(s.Peers.Alive()) == 2 })
+	eventually(t, "the users", func() bool {
+		s.RwMtx.RLock()
+		defer s.RwMtx.RUnlock()
+		return Contains(s.UsersIdMap["user"], "news")
+	})
+	eventually(t, "the deletion", func() bool { return cloud.Messages(queueName(s.QueueURL)) == 0 })
+}
+
+func TestLookForMessagesDiscardsUndecodable(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	bodies := []string{
+		"not json",
+		`{"Type":"Notification","Subject":"HEARTBEAT","Message":"not json"}`,
+		`{"Type":"Notification","Subject":"UNKNOWN","Message":"{}"}`,
+		`{"Type":"Notification","Subject":"FILTERS","Message":"[{\"zone\":\"eu\",\"id\":\"user\",\"tag\":\"news\",\"filter\":\"not a filter\"}]"}`,
+	}
+	svc := sqs.New(cloud.Session())
+	for _, body := range bodies {
+		if _, err := svc.SendMessage(&sqs.SendMessageInput{QueueUrl: &s.QueueURL, MessageBody: aws.String(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replicate(t, s)
+
+	eventually(t, "the deletion", func() bool { return cloud.Messages(queueName(s.QueueURL)) == 0 })
+	if calls := cloud.Calls("ReceiveMessage"); calls > 2 {
+		t.Errorf("expected the messages discarded at the first delivery, got %d receives", calls)
+	}
+}
+
+func TestLookForMessagesRetries(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	values := t.TempDir()
+	var err error
+	if s.LastValues, err = retainedLog.OpenLastValues(filepath.Join(values, "last-values.json")); err != nil {
+		t.Fatal(err)
+	}
+	// the value cannot be stored
+	os.RemoveAll(values)
+	m := utilities.RetainedMessage{Topic: "news", Zone: "eu", MessageID: "1", Time: time.Now(), Body: "{}"}
+	snsManagement.PublishRetained(m, &s.TopicARN)
+	replicate(t, s)
+
+	// the next long poll has started: the first delivery has been handled
+	eventually(t, "the first delivery", func() bool { return cloud.Calls("ReceiveMessage") >= 2 })
+	if n := cloud.Messages(queueName(s.QueueURL)); n != 1 {
+		t.Fatalf("expected the message left on the queue, got %d messages", n)
+	}
+
+	if err = os.MkdirAll(values, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cloud.Advance(replicationVisibility * time.Second)
+	eventually(t, "the deletion", func() bool { return cloud.Messages(queueName(s.QueueURL)) == 0 })
+	if _, exists := s.LastValues.Get("news"); !exists {
+		t.Errorf("expected the value stored at the second delivery")
+	}
+}
+
+func TestLookForMessagesGivesUp(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	values := t.TempDir()
+	var err error
+	if s.LastValues, err = retainedLog.OpenLastValues(filepath.Join(values, "last-values.json")); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(values)
+	m := utilities.RetainedMessage{Topic: "news", Zone: "eu", MessageID: "1", Time: time.Now(), Body: "{}"}
+	snsManagement.PublishRetained(m, &s.TopicARN)
+	replicate(t, s)
+
+	for i := 1; i <= replicationAttempts; i++ {
+		eventually(t, "the delivery", func() bool { return cloud.Calls("ReceiveMessage") > i })
+		cloud.Advance(replicationVisibility * time.Second)
+	}
+	eventually(t, "the deletion", func() bool { return cloud.Messages(queueName(s.QueueURL)) == 0 })
+	if calls := cloud.Calls("DeleteMessageBatch"); calls != 1 {
+		t.Errorf("expected the message deleted once, got %d deletions", calls)
+	}
+}
+
+func TestPeersSeeEachOther(t *testing.T) {
+	cloud := newCloud(t)
+	servers := []*Service{newService(t, "eu", 1234), newService(t, "eu", 1235)}
+	if servers[0].QueueURL == servers[1].QueueURL {
+		t.Fatalf("expected a queue per server, got %s twice", servers[0].QueueURL)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, s := range servers {
+		s := s
+		replicate(t, s)
+		go membership.Run(ctx, s.Peers, &s.TopicARN)
+	}
+
+	for _, s := range servers {
+		eventually(t, "the heartbeat of the peer", func() bool { return len(s.Peers.Alive()) == 2 })
+	}
+
+	snsManagement.LeaveMasterTopic(&servers[1].QueueURL, &servers[1].SubscriptionARN)
+	for _, name := range cloud.Queues() {
+		if name == queueName(servers[1].QueueURL) {
+			t.Errorf("expected the queue %s deleted", name)
+		}
+	}
+	if subscriptions := cloud.Subscriptions(servers[0].TopicARN); len(subscriptions) != 1 {
+		t.Errorf("expected only the queue of the other server subscribed, got %v", subscriptions)
+	}
+}
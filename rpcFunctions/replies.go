@@ -0,0 +1,155 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
+	"SDCC-A3-Project/utilities"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"strconv"
+	"time"
+)
+
+// a reply queue keeps its owner and the end of its lease in its tags, so that any server of
+// the zone, even after a restart, can renew it or delete it once abandoned
+const (
+	replyOwnerTag   = "reply-owner"
+	replyExpiresTag = "reply-expires" // unix time
+)
+
+// CreateReplyQueue creates a temporary queue receiving the responses to the requests of the user.
+// It is deleted inArg.TTL seconds after its creation or its last renewal.
+func (s *Service) CreateReplyQueue(inArg *utilities.ReplyQueueArg, outArg *utilities.ReplyQueueOutput) error {
+	ttl, err := s.checkReplyQueueArg(inArg)
+	if err != nil {
+		return err
+	}
+	sess := utilities.NewSession()
+	name := s.replyQueuePrefix() + shortuuid.New()
+	expires := time.Now().Add(ttl)
+	// tagged at creation, an untagged queue would never be cleaned up
+	result, _, err := sqsManagement.CreateQueue(sess, &name, nil, map[string]string{
+		replyOwnerTag:   inArg.ID,
+		replyExpiresTag: strconv.FormatInt(expires.Unix(), 10),
+	})
+	if err != nil {
+		fmt.Println("Got an error creating the reply queue:")
+		fmt.Println(err)
+		return errors.New("cannot create the reply queue\n")
+	}
+	outArg.Name = name
+	outArg.QueueURL = *result.QueueUrl
+	outArg.Expires = expires
+	return nil
+}
+
+// RenewReplyQueue extends the lease of the reply queue of the user by inArg.TTL seconds from now.
+func (s *Service) RenewReplyQueue(inArg *utilities.ReplyQueueArg, outArg *utilities.ReplyQueueOutput) error {
+	ttl, err := s.checkReplyQueueArg(inArg)
+	if err != nil {
+		return err
+	}
+	sess := utilities.NewSession()
+	url, err := s.ownReplyQueue(sess, inArg)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(ttl)
+	err = sqsManagement.TagQueue(sess, &url, map[string]string{replyExpiresTag: strconv.FormatInt(expires.Unix(), 10)})
+	if err != nil {
+		fmt.Println("Got an error tagging the reply queue:")
+		fmt.Println(err)
+		return errors.New("cannot renew the reply queue\n")
+	}
+	outArg.Name = inArg.Name
+	outArg.QueueURL = url
+	outArg.Expires = expires
+	return nil
+}
+
+// DeleteReplyQueue deletes the reply queue of the user together with the responses not yet read.
+func (s *Service) DeleteReplyQueue(inArg *utilities.ReplyQueueArg, exitStatus *int) error {
+	if _, err := s.checkReplyQueueArg(inArg); err != nil {
+		return err
+	}
+	sess := utilities.NewSession()
+	url, err := s.ownReplyQueue(sess, inArg)
+	if err != nil {
+		return err
+	}
+	deleteQueue(&url)
+	*exitStatus = 0
+	return nil
+}
+
+// checkReplyQueueArg validates the user and returns the lease asked for
+func (s *Service) checkReplyQueueArg(inArg *utilities.ReplyQueueArg) (time.Duration, error) {
+	s.RwMtx.RLock()
+	_, exists := s.UsersIdMap[inArg.ID]
+	s.RwMtx.RUnlock()
+	if !exists {
+		return 0, errors.New("invalid user id\n")
+	}
+	ttl := inArg.TTL
+	if ttl == 0 {
+		ttl = utilities.ReplyQueueTTL
+	}
+	if ttl < 0 || ttl > utilities.MaxReplyQueueTTL {
+		return 0, errors.New("the ttl of a reply queue must be between 1 and " + strconv.Itoa(utilities.MaxReplyQueueTTL) + " seconds\n")
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+// ownReplyQueue returns the url of the reply queue inArg.Name, checking that it belongs to the user
+func (s *Service) ownReplyQueue(sess *session.Session, inArg *utilities.ReplyQueueArg) (string, error) {
+	if len(inArg.Name) <= len(s.replyQueuePrefix()) || inArg.Name[:len(s.replyQueuePrefix())] != s.replyQueuePrefix() {
+		return "", errors.New(inArg.Name + " is not a reply queue of zone " + s.Zone + "\n")
+	}
+	result, err := sqsManagement.GetQueueURL(&inArg.Name)
+	if err != nil {
+		return "", errors.New("reply queue " + inArg.Name + " not found\n")
+	}
+	tags, err := sqsManagement.QueueTags(sess, result.QueueUrl)
+	if err != nil {
+		fmt.Println("Got an error reading the tags of the reply queue:")
+		fmt.Println(err)
+		return "", errors.New("cannot read the reply queue\n")
+	}
+	if tags[replyOwnerTag] != inArg.ID {
+		return "", errors.New("the reply queue belongs to another user\n")
+	}
+	return *result.QueueUrl, nil
+}
+
+func (s *Service) replyQueuePrefix() string {
+	return topics.ReplyQueuePrefix(s.Zone)
+}
+
+// reapReplyQueues deletes the reply queues of the zone whose lease is over
+func (s *Service) reapReplyQueues(now time.Time) {
+	sess := utilities.NewSession()
+	urls, err := sqsManagement.ListQueues(sess, s.replyQueuePrefix())
+	if err != nil {
+		log.Printf("[WARNING] - cannot list the reply queues: %v", err)
+		return
+	}
+	for i := range urls {
+		tags, err := sqsManagement.QueueTags(sess, &urls[i])
+		if err != nil {
+			// deleted in the meantime
+			continue
+		}
+		expires, err := strconv.ParseInt(tags[replyExpiresTag], 10, 64)
+		if err != nil {
+			// not a reply queue, it just shares the prefix
+			continue
+		}
+		if now.Unix() >= expires {
+			log.Printf("[INFO] - reply queue of %s abandoned, deleting %s", tags[replyOwnerTag], urls[i])
+			deleteQueue(&urls[i])
+		}
+	}
+}
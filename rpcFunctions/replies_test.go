@@ -0,0 +1,88 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/utilities"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hasQueue tells whether the queue exists
+func hasQueue(cloud *awsFake.Cloud, name string) bool {
+	for _, queue := range cloud.Queues() {
+		if queue == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReplyQueueLease(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	owner, other := newUser(t, s), newUser(t, s)
+
+	var queue utilities.ReplyQueueOutput
+	if err := s.CreateReplyQueue(&utilities.ReplyQueueArg{ID: owner, TTL: 60}, &queue); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(queue.Name, s.replyQueuePrefix()) || !hasQueue(cloud, queue.Name) {
+		t.Fatalf("expected a reply queue of the zone, got %q and the queues %v", queue.Name, cloud.Queues())
+	}
+
+	// still leased
+	s.reapReplyQueues(queue.Expires.Add(-time.Second))
+	if !hasQueue(cloud, queue.Name) {
+		t.Fatalf("expected the reply queue kept until its lease is over")
+	}
+	var renewed utilities.ReplyQueueOutput
+	if err := s.RenewReplyQueue(&utilities.ReplyQueueArg{ID: owner, Name: queue.Name, TTL: 600}, &renewed); err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.Expires.After(queue.Expires) {
+		t.Errorf("expected the lease extended after %v, got %v", queue.Expires, renewed.Expires)
+	}
+	s.reapReplyQueues(queue.Expires.Add(time.Second))
+	if !hasQueue(cloud, queue.Name) {
+		t.Fatalf("expected the renewed reply queue kept")
+	}
+	s.reapReplyQueues(renewed.Expires)
+	if hasQueue(cloud, queue.Name) {
+		t.Errorf("expected the abandoned reply queue deleted")
+	}
+
+	tests := []struct {
+		id, name string
+		ttl      int64
+		err      string
+	}{
+		{"unknown", queue.Name, 0, "invalid user id"},
+		{owner, queue.Name, -1, "the ttl of a reply queue"},
+		{owner, queue.Name, utilities.MaxReplyQueueTTL + 1, "the ttl of a reply queue"},
+		{owner, "news_eu", 0, "is not a reply queue"},
+		{owner, queue.Name, 0, "not found"},
+	}
+	for _, test := range tests {
+		var out utilities.ReplyQueueOutput
+		err := s.RenewReplyQueue(&utilities.ReplyQueueArg{ID: test.id, Name: test.name, TTL: test.ttl}, &out)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s %s %d: expected %q, got %v", test.id, test.name, test.ttl, test.err, err)
+		}
+	}
+
+	// only its owner can use or delete a reply queue
+	if err := s.CreateReplyQueue(&utilities.ReplyQueueArg{ID: owner}, &queue); err != nil {
+		t.Fatal(err)
+	}
+	var status int
+	if err := s.DeleteReplyQueue(&utilities.ReplyQueueArg{ID: other, Name: queue.Name}, &status); err == nil || !strings.Contains(err.Error(), "another user") {
+		t.Errorf("expected the reply queue of another user refused, got %v", err)
+	}
+	if err := s.DeleteReplyQueue(&utilities.ReplyQueueArg{ID: owner, Name: queue.Name}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if hasQueue(cloud, queue.Name) {
+		t.Errorf("expected the reply queue deleted")
+	}
+}
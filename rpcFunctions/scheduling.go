@@ -0,0 +1,106 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"SDCC-A3-Project/scheduler"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ScheduleMessage keeps a message on this server until inArg.DeliverAt, or for inArg.Delay seconds,
+// and then sends it to the topic. The user must be able to send to the topic.
+func (s *Service) ScheduleMessage(inArg *utilities.ScheduleArg, outID *string) error {
+	var url string
+	// checks the subscription or the publisher registration
+	if err := s.GetQueueURL(&utilities.RequestArg{ID: inArg.ID, Tag: inArg.Tag}, &url); err != nil {
+		return err
+	}
+	if inArg.Delay < 0 || inArg.Delay > utilities.MaxScheduleDelay {
+		return errors.New("the delay must be between 0 and " + strconv.Itoa(utilities.MaxScheduleDelay) + " seconds\n")
+	}
+	due := inArg.DeliverAt
+	if due.IsZero() {
+		due = time.Now().Add(time.Duration(inArg.Delay) * time.Second)
+	} else if time.Until(due) > utilities.MaxScheduleDelay*time.Second {
+		return errors.New("the delivery time is more than " + strconv.Itoa(utilities.MaxScheduleDelay) + " seconds away\n")
+	}
+
+	entry := scheduler.Entry{
+		ID:              shortuuid.New(),
+		Owner:           inArg.ID,
+		Topic:           inArg.Tag,
+		DueTime:         due.UTC(),
+		Message:         inArg.Message,
+		GroupID:         inArg.GroupID,
+		DeduplicationID: inArg.DeduplicationID,
+	}
+	if err := s.Scheduler.Add(entry); err != nil {
+		log.Printf("[WARNING] - cannot store the scheduled message: %v", err)
+		return errors.New("cannot store the scheduled message\n")
+	}
+	log.Printf("[INFO] - message %s on %s scheduled for %v", entry.ID, entry.Topic, entry.DueTime.Format(time.RFC3339))
+	*outID = entry.ID
+	return nil
+}
+
+// CancelScheduledMessage cancels the message inArg.ScheduleID, if it has not been sent yet.
+func (s *Service) CancelScheduledMessage(inArg *utilities.ScheduleArg, exitStatus *int) error {
+	s.RwMtx.RLock()
+	_, exists := s.UsersIdMap[inArg.ID]
+	s.RwMtx.RUnlock()
+	if !exists {
+		return errors.New("invalid user id\n")
+	}
+	if err := s.Scheduler.Cancel(inArg.ScheduleID, inArg.ID); err != nil {
+		return errors.New(err.Error() + "\n")
+	}
+	*exitStatus = 0
+	return nil
+}
+
+// ReleaseScheduled sends the scheduled messages to their topic when due, until ctx is done.
+func ReleaseScheduled(ctx context.Context, s *Service) {
+	// This function must be called in a thread/goroutine
+	sess := utilities.NewSession()
+	scheduler.Run(ctx, s.Scheduler, utilities.SchedulerInterval, func(e scheduler.Entry) error {
+		return s.releaseScheduled(ctx, sess, e)
+	})
+}
+
+func (s *Service) releaseScheduled(ctx context.Context, sess *session.Session, e scheduler.Entry) error {
+	// after a restart the topic is known only to sqs
+	url, err := s.lookupTopicQueue(e.Topic)
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		// nobody would ever receive it
+		log.Printf("[WARNING] - topic %s deleted, discarding the scheduled message %s", e.Topic, e.ID)
+		return nil
+	}
+	var deduplicationIDs []string
+	if e.DeduplicationID != "" {
+		deduplicationIDs = []string{e.DeduplicationID}
+	}
+	failures, err := sqsManagement.SendMsgBatch(ctx, sess, &url, []*envelope.Envelope{&e.Message}, e.GroupID, deduplicationIDs)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		if failures[0].SenderFault {
+			// it would fail the same way at every attempt
+			log.Printf("[WARNING] - discarding the scheduled message %s: %s %s", e.ID, failures[0].Code, failures[0].Message)
+			return nil
+		}
+		return errors.New(failures[0].Code + " " + failures[0].Message)
+	}
+	s.retainSent(e.Topic, &e.Message)
+	return nil
+}
@@ -1,25 +1,52 @@
 package rpcFunctions
 
 import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/cronJobs"
 	"SDCC-A3-Project/imports/shortuuid-master"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/retainedLog"
+	"SDCC-A3-Project/scheduler"
 	"SDCC-A3-Project/snsManagement"
 
 	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
 	"SDCC-A3-Project/utilities"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Service struct {
-	UsersIdMap          map[string][]string // topicString:List of topic
-	QueueSubscribersMap map[string]int      // topic : number of subscribers
-	URLQueueMap         map[string]string   // topic:URL of the queue
-	RwMtx               sync.RWMutex        // to guarantee access in mutual exclusion to the maps
+	UsersIdMap          map[string][]string                         // topicString:List of topic
+	QueueSubscribersMap map[string]int                              // topic : number of subscribers
+	URLQueueMap         map[string]string                           // topic:URL of the queue
+	DeadLetterURLMap    map[string]string                           // topic:URL of the dead-letter queue
+	SettingsMap         map[string]utilities.QueueSettings          // topic:configuration of the queue
+	FiltersMap          map[string]map[string]*messageFilter.Filter // topic or pattern:user:filter of the subscription, only filtered ones, of the whole zone
+	PatternsMap         map[string]int                              // pattern : number of subscribers, they count as subscribers of every topic matched
+	QueueNamesMap       map[string]string                           // queue name (without suffixes) : topic, to detect collisions
+	CatalogMap          map[string]utilities.TopicInfo              // topic : description of the topic
+	EmptySinceMap       map[string]time.Time                        // topic : when its last subscriber left, only topics in their grace period
+	PublishersMap       map[string][]string                         // topic : users registered as publishers, they keep the queue alive
+	MaxReceiveCount     int                                         // deliveries of a message before moving it to the dead-letter queue
+	RwMtx               sync.RWMutex                                // to guarantee access in mutual exclusion to the maps
 	Zone                string
-	TopicARN            string // sns arn notification endpoint
-	QueueURL            string // sns queue reception
+	TopicARN            string                   // sns arn notification endpoint
+	QueueURL            string                   // sns queue reception, of this server only
+	SubscriptionARN     string                   // subscription of QueueURL to the master topic
+	Peers               *membership.View         // servers known to be alive
+	Scheduler           *scheduler.Scheduler     // messages waiting for their delivery time
+	CronStore           *cronJobs.Store          // recurring publications shared by the servers, nil if disabled
+	DeliveryLogs        *retainedLog.Logs        // messages this server delivered on the topics retaining them, with its own offsets
+	LastValues          *retainedLog.LastValues  // last message of the topics retaining it
+	BlobStore           blobStorage.Store        // large payloads of the messages retained, nil if none
+	Keys                payloadCodec.KeyProvider // master keys encrypting the recurring publications, nil if none
 }
 
 type RPCServer interface {
@@ -27,54 +54,119 @@ type RPCServer interface {
 	DeleteSubscription(inArg *utilities.RequestArg, exitStatus *int) error
 	GenerateUserId(inArg *utilities.RequestArg, outId *string) error
 	GetQueueURL(inArg *utilities.RequestArg, outURL *string) error
+	ListServers(inArg *utilities.RequestArg, outList *[]utilities.ServerInfo) error
+	GetTopicSettings(inArg *utilities.RequestArg, outSettings *utilities.QueueSettings) error
+	CreateTopic(inArg *utilities.RequestArg, outInfo *utilities.TopicInfo) error
+	DeleteTopic(inArg *utilities.RequestArg, exitStatus *int) error
+	RegisterPublisher(inArg *utilities.RequestArg, outArg *utilities.SubscriptionOutput) error
+	UnregisterPublisher(inArg *utilities.RequestArg, exitStatus *int) error
+	ScheduleMessage(inArg *utilities.ScheduleArg, outID *string) error
+	CancelScheduledMessage(inArg *utilities.ScheduleArg, exitStatus *int) error
+	RegisterCronJob(inArg *utilities.CronArg, outID *string) error
+	DeleteCronJob(inArg *utilities.CronArg, exitStatus *int) error
+	ListCronJobs(inArg *utilities.RequestArg, outList *[]utilities.CronJob) error
+	CreateReplyQueue(inArg *utilities.ReplyQueueArg, outArg *utilities.ReplyQueueOutput) error
+	RenewReplyQueue(inArg *utilities.ReplyQueueArg, outArg *utilities.ReplyQueueOutput) error
+	DeleteReplyQueue(inArg *utilities.ReplyQueueArg, exitStatus *int) error
+	Replay(inArg *utilities.ReplayArg, outArg *utilities.ReplayOutput) error
+	ReceiveMessages(inArg *utilities.ReceiveArg, outArg *utilities.MessagesOutput) error
+	ListTopics(inArg *utilities.RequestArg, outList *[]utilities.TopicInfo) error
+	DescribeTopic(inArg *utilities.RequestArg, outInfo *utilities.TopicInfo) error
+	SearchTopics(inArg *utilities.SearchArg, outList *[]utilities.TopicInfo) error
+	InspectDeadLetters(inArg *utilities.RequestArg, outArg *utilities.DeadLetterOutput) error
+	RedriveDeadLetters(inArg *utilities.RequestArg, outMoved *int) error
+	PurgeDeadLetters(inArg *utilities.RequestArg, exitStatus *int) error
 }
 
+const (
+	deadLetterSuffix = "_DLQ"        // appended to the name of a topic queue to name its dead-letter queue
+	fifoSuffix       = ".fifo"       // the name of a FIFO queue must end with it
+	topicTag         = "topic"       // queue tag holding the name of the topic, queue names are not reversible
+	compressionTag   = "compression" // queue tag holding the compression of the topic, the queue has no attribute for it
+)
+
 func (s *Service) GetQueueURL(inArg *utilities.RequestArg, outURL *string) error {
-	s.RwMtx.RLock()
-	/*start critical read section*/
+	// the queue may have to be looked up and stored, so we need to write
+	s.RwMtx.Lock()
+	/*critical section, nobody can read while i'm writing*/
 	var l []string
 	// check if the user is valid or not
 	if val, exists := s.UsersIdMap[inArg.ID]; exists {
 		l = val
 	} else {
-		s.RwMtx.RUnlock()
+		s.RwMtx.Unlock()
 		return errors.New("invalid user id\n")
 	}
-
-	isSubscriber := false
-	for i := 0; i < len(l); i++ {
-		if l[i] == inArg.Tag {
-			// the subscription already exists
-			isSubscriber = true
-			break
-		}
+	if topics.IsPattern(inArg.Tag) {
+		s.RwMtx.Unlock()
+		return errors.New("a pattern has no queue, receive its messages with ReceiveMessages\n")
+	}
+	if err := topics.Validate(inArg.Tag, false); err != nil {
+		s.RwMtx.Unlock()
+		return err
 	}
-	if isSubscriber {
+
+	if s.isPublisher(inArg.ID, inArg.Tag) {
+		// a publisher registration always refers to a queue of this server
+		*outURL = s.URLQueueMap[inArg.Tag]
+	} else if isSubscribed(l, inArg.Tag) {
 		*outURL = s.URLQueueMap[inArg.Tag]
 		if *outURL == "" {
-			var queueName string
-			queueName = inArg.Tag + "_" + s.Zone
+			// the subscription has been done on another server
 			// we haven't a valid reference to the queue
-			result, err := sqsManagement.GetQueueURL(&queueName)
+			if other, exists := s.QueueNamesMap[topics.QueueName(inArg.Tag, s.Zone)]; exists && other != inArg.Tag {
+				s.RwMtx.Unlock()
+				return errors.New("topic " + inArg.Tag + " collides with topic " + other + ", choose another name\n")
+			}
+			sess := utilities.NewSession()
+			// the topic may have been created as FIFO
+			url, queueName, err := lookupQueue(sess, inArg.Tag, s.queueName(inArg.Tag), s.queueName(inArg.Tag)+fifoSuffix)
 			if err != nil {
-				fmt.Println("Got an error getting the queue URL:")
-				fmt.Println(err)
+				s.RwMtx.Unlock()
+				return err
 			}
-			if *result.QueueUrl == "" {
+			if strings.HasSuffix(queueName, fifoSuffix) {
+				settings := s.topicSettings(inArg.Tag)
+				settings.Fifo = true
+				s.SettingsMap[inArg.Tag] = settings
+			}
+			if url == "" {
 				//queue must be created
-				*outURL = s.initQueue(inArg.Tag)
+				*outURL, err = s.initQueue(inArg.Tag)
+				if err != nil {
+					s.RwMtx.Unlock()
+					return err
+				}
+				s.addToCatalog(inArg.Tag, "", "")
 			} else {
-				s.URLQueueMap[inArg.Tag] = *result.QueueUrl
-				*outURL = *result.QueueUrl
+				*outURL = url
+				// the producers of this server need the settings of who created the topic
+				if settings, err := queueSettings(sess, url, s.topicSettings(inArg.Tag)); err == nil {
+					s.SettingsMap[inArg.Tag] = settings
+				} else {
+					fmt.Println("Got an error reading the settings of the queue:")
+					fmt.Println(err)
+				}
+				s.QueueNamesMap[topics.QueueName(inArg.Tag, s.Zone)] = inArg.Tag
+				s.addToCatalog(inArg.Tag, "", "")
+				dlqName := s.deadLetterName(inArg.Tag)
+				if dlq, err := sqsManagement.GetQueueURL(&dlqName); err == nil {
+					s.DeadLetterURLMap[inArg.Tag] = *dlq.QueueUrl
+				}
+			}
+			s.URLQueueMap[inArg.Tag] = *outURL
+			if _, exists := s.QueueSubscribersMap[inArg.Tag]; !exists {
+				// the patterns matching the new topic subscribe to it
+				s.QueueSubscribersMap[inArg.Tag] = s.patternSubscribers(inArg.Tag)
 			}
 		}
 
 	} else {
-		s.RwMtx.RUnlock()
-		return errors.New("a subscription must be done before")
+		s.RwMtx.Unlock()
+		return errors.New("a subscription or a publisher registration must be done before\n")
 	}
 
-	s.RwMtx.RUnlock()
+	s.RwMtx.Unlock()
 	return nil
 }
 
@@ -91,22 +183,31 @@ func (s *Service) DeleteSubscription(inArg *utilities.RequestArg, exitStatus *in
 	}
 
 	//remove the element corresponding to the tag
+	found := false
 	for i := 0; i < len(l); i++ {
 		if l[i] == inArg.Tag {
 			// the subscription exists
 			s.UsersIdMap[inArg.ID] = append(l[:i], l[i+1:]...)
-			s.QueueSubscribersMap[inArg.Tag]--
+			if s.FiltersMap[inArg.Tag][inArg.ID] != nil {
+				s.removeFilter(inArg.Tag, inArg.ID)
+				removed := utilities.SubscriptionFilter{Zone: s.Zone, ID: inArg.ID, Tag: inArg.Tag, Removed: true}
+				go func() { snsManagement.PublishFilters([]utilities.SubscriptionFilter{removed}, &s.TopicARN) }()
+			}
+			found = true
 			break
 		}
 	}
 
-	if s.QueueSubscribersMap[inArg.Tag] == 0 {
-		//no more producers,  no more subscribers are still interested and so we can cancel this queue
-		delete(s.QueueSubscribersMap, inArg.Tag)
-		url := s.URLQueueMap[inArg.Tag]
-		//deleting sqs-queue
-		deleteQueue(&url)
-		delete(s.URLQueueMap, inArg.Tag)
+	if found && topics.IsPattern(inArg.Tag) {
+		s.PatternsMap[inArg.Tag]--
+		if s.PatternsMap[inArg.Tag] <= 0 {
+			delete(s.PatternsMap, inArg.Tag)
+		}
+		for _, tag := range s.matchingTopics(inArg.Tag) {
+			s.releaseTopic(tag)
+		}
+	} else if found {
+		s.releaseTopic(inArg.Tag)
 	}
 	*exitStatus = 0
 	s.RwMtx.Unlock()
@@ -114,6 +215,19 @@ func (s *Service) DeleteSubscription(inArg *utilities.RequestArg, exitStatus *in
 	return nil
 }
 
+// releaseTopic removes a subscriber from the topic, the last one leaving with no publishers
+// triggers its EmptyPolicy. The caller must hold the write lock.
+func (s *Service) releaseTopic(tag string) {
+	s.QueueSubscribersMap[tag]--
+	if s.QueueSubscribersMap[tag] <= 0 {
+		s.QueueSubscribersMap[tag] = 0
+		if s.isIdle(tag) {
+			//no more producers,  no more subscribers are still interested in this queue
+			s.topicEmptied(tag)
+		}
+	}
+}
+
 func (s *Service) MakeSubscriptionToTopic(inArg *utilities.RequestArg, outArg *utilities.SubscriptionOutput) error {
 	s.RwMtx.Lock()
 	/*critical section, nobody can read while i'm writing*/
@@ -134,21 +248,52 @@ func (s *Service) MakeSubscriptionToTopic(inArg *utilities.RequestArg, outArg *u
 			return errors.New("subscription already exists\n")
 		}
 	}
+
+	if err := topics.Validate(inArg.Tag, true); err != nil {
+		s.RwMtx.Unlock()
+		return err
+	}
+	filter, err := messageFilter.Parse(inArg.Filter)
+	if err != nil {
+		s.RwMtx.Unlock()
+		return err
+	}
+
+	val, exists := s.URLQueueMap[inArg.Tag]
+	if !exists && !topics.IsPattern(inArg.Tag) {
+		//the queue doesn't exists
+		if val, err = s.createTopic(inArg); err != nil {
+			s.RwMtx.Unlock()
+			return err
+		}
+	}
 	//insert the tag into the list associated with the user
 	s.UsersIdMap[inArg.ID] = append(l, inArg.Tag)
+	if filter != nil {
+		s.addFilter(inArg.Tag, inArg.ID, filter)
+		// the other servers must keep the messages it wants
+		added := utilities.SubscriptionFilter{Zone: s.Zone, ID: inArg.ID, Tag: inArg.Tag, Filter: filter.String()}
+		go func() { snsManagement.PublishFilters([]utilities.SubscriptionFilter{added}, &s.TopicARN) }()
+	}
 
-	if val, exists := s.URLQueueMap[inArg.Tag]; exists {
+	if topics.IsPattern(inArg.Tag) {
+		// no queue of its own, the subscription counts for every topic matched
+		s.PatternsMap[inArg.Tag]++
+		for _, tag := range s.matchingTopics(inArg.Tag) {
+			s.QueueSubscribersMap[tag]++
+		}
+	} else if exists {
 		outArg.QueueURL = val
 		//increase the number of subscribers
 		s.QueueSubscribersMap[inArg.Tag]++
 	} else {
-		//the queue doesn't exists
-		//follows dynamic creation of the queue
-		s.URLQueueMap[inArg.Tag] = s.initQueue(inArg.Tag + "_" + s.Zone)
-		outArg.QueueURL = s.URLQueueMap[inArg.Tag]
-		// this is a new queue with only a subscriber
-		s.QueueSubscribersMap[inArg.Tag] = 1
+		outArg.QueueURL = val
+		// this is a new queue with only a subscriber, plus the patterns matching it
+		s.QueueSubscribersMap[inArg.Tag] = 1 + s.patternSubscribers(inArg.Tag)
 	}
+	outArg.Settings = s.topicSettings(inArg.Tag)
+	// the new subscriber learns the current value without waiting for the next publication
+	outArg.Retained = s.retainedFor(inArg.Tag, filter)
 	s.RwMtx.Unlock()
 	//need to send my list updated to other servers
 	go func() { snsManagement.PublishUserListUpdate(s.UsersIdMap, &s.TopicARN) }()
@@ -179,27 +324,182 @@ func (s *Service) GenerateUserId(inArgs *utilities.RequestArg, outId *string) er
 	return nil
 }
 
-func (s *Service) initQueue(tag string) string {
+// GetTopicSettings returns the configuration of the topic, producers need it to encode the payloads
+func (s *Service) GetTopicSettings(inArg *utilities.RequestArg, outSettings *utilities.QueueSettings) error {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	if _, exists := s.UsersIdMap[inArg.ID]; !exists {
+		return errors.New("invalid user id\n")
+	}
+	*outSettings = s.topicSettings(inArg.Tag)
+	return nil
+}
+
+// ListServers returns the servers currently alive, the one answering first.
+// If inArg.Tag is a zone name only the servers of that zone are returned.
+func (s *Service) ListServers(inArg *utilities.RequestArg, outList *[]utilities.ServerInfo) error {
+	all := s.Peers.Alive()
+	if inArg.Tag == "" {
+		*outList = all
+		return nil
+	}
+	var l []utilities.ServerInfo
+	for i := 0; i < len(all); i++ {
+		if all[i].Zone == inArg.Tag {
+			l = append(l, all[i])
+		}
+	}
+	*outList = l
+	return nil
+}
+
+// queueName returns the name of the sqs queue of the topic in the zone of the server.
+// The caller must hold the lock.
+func (s *Service) queueName(tag string) string {
+	name := topics.QueueName(tag, s.Zone)
+	if s.topicSettings(tag).Fifo {
+		return name + fifoSuffix
+	}
+	return name
+}
+
+// deadLetterName returns the name of the dead-letter queue of the topic in the zone of the server.
+// The caller must hold the lock.
+func (s *Service) deadLetterName(tag string) string {
+	name := topics.QueueName(tag, s.Zone) + deadLetterSuffix
+	if s.topicSettings(tag).Fifo {
+		return name + fifoSuffix
+	}
+	return name
+}
+
+// topicSettings returns the configuration of the queue of the topic.
+// The caller must hold the lock.
+func (s *Service) topicSettings(tag string) utilities.QueueSettings {
+	if settings, exists := s.SettingsMap[tag]; exists {
+		return settings
+	}
+	return utilities.DefaultQueueSettings()
+}
+
+// initQueue creates the queue of the topic together with its dead-letter queue.
+// It fails if the queue name is already used by another topic.
+// The caller must hold the write lock.
+func (s *Service) initQueue(tag string) (string, error) {
+	baseName := topics.QueueName(tag, s.Zone)
+	if other, exists := s.QueueNamesMap[baseName]; exists && other != tag {
+		return "", errors.New("topic " + tag + " collides with topic " + other + ", choose another name\n")
+	}
+
 	// Create a session that gets credential values from ~/.aws/credentials
 	// and the default region from ~/.aws/config
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	result, err := sqsManagement.CreateQueue(sess, &tag)
+	sess := utilities.NewSession()
+	queueName := s.queueName(tag)
+	// the queue may have been created by another server, maybe for another topic:
+	// CreateQueue must not touch it then
+	if _, _, err := lookupQueue(sess, tag, queueName); err != nil {
+		return "", err
+	}
+	settings := s.topicSettings(tag)
+	queueTags := map[string]string{topicTag: tag}
+	if settings.Compression != "" {
+		queueTags[compressionTag] = settings.Compression
+	}
+	result, existing, err := sqsManagement.CreateQueue(sess, &queueName, &settings, queueTags)
 	if err != nil {
 		fmt.Println("Got an error creating the queue:")
 		fmt.Println(err)
-		return ""
+		return "", errors.New("cannot create the queue of topic " + tag + ": " + err.Error())
+	}
+	if existing != nil {
+		// created elsewhere with another configuration, which is the one in use
+		settings = sqsManagement.WithAttributes(settings, existing)
+		s.SettingsMap[tag] = settings
+	}
+	// another server may have created it in the meantime
+	tags, err := sqsManagement.QueueTags(sess, result.QueueUrl)
+	if err != nil {
+		return "", err
 	}
+	if other, exists := tags[topicTag]; exists && other != tag {
+		return "", errors.New("topic " + tag + " collides with topic " + other + ", choose another name\n")
+	} else if !exists {
+		if err = sqsManagement.TagQueue(sess, result.QueueUrl, queueTags); err != nil {
+			return "", err
+		}
+	} else if tags[compressionTag] != settings.Compression {
+		// created elsewhere, the producers must use its compression
+		settings.Compression = tags[compressionTag]
+		s.SettingsMap[tag] = settings
+	}
+	s.QueueNamesMap[baseName] = tag
+
+	dlqName := s.deadLetterName(tag)
+	dlq, err := sqsManagement.CreateDeadLetterQueue(sess, &dlqName, settings.Fifo)
+	if err != nil {
+		// the topic works anyway, unprocessable messages will expire
+		fmt.Println("Got an error creating the dead-letter queue:")
+		fmt.Println(err)
+		return *result.QueueUrl, nil
+	}
+	dlqARN, err := sqsManagement.GetQueueARN(sess, dlq.QueueUrl)
+	if err == nil {
+		err = sqsManagement.SetRedrivePolicy(sess, result.QueueUrl, dlqARN, s.MaxReceiveCount)
+	}
+	if err != nil {
+		fmt.Println("Got an error setting the redrive policy:")
+		fmt.Println(err)
+	}
+	s.DeadLetterURLMap[tag] = *dlq.QueueUrl
+
+	return *result.QueueUrl, nil
 
-	return *result.QueueUrl
+}
 
+// lookupQueue returns the url and the name of the first existing queue among names, empty if
+// none exists. Sanitizing is not injective: it fails if the queue is tagged with another topic.
+func lookupQueue(sess *session.Session, tag string, names ...string) (string, string, error) {
+	for i := range names {
+		result, err := sqsManagement.GetQueueURL(&names[i])
+		if sqsManagement.IsQueueMissing(err) {
+			continue
+		}
+		if err != nil {
+			fmt.Println("Got an error getting the queue URL:")
+			fmt.Println(err)
+			return "", "", errors.New("cannot look up the queue of topic " + tag + "\n")
+		}
+		tags, err := sqsManagement.QueueTags(sess, result.QueueUrl)
+		if err != nil {
+			fmt.Println("Got an error reading the tags of the queue:")
+			fmt.Println(err)
+			return "", "", errors.New("cannot read the queue of topic " + tag + "\n")
+		}
+		if other, exists := tags[topicTag]; exists && other != tag {
+			return "", "", errors.New("topic " + tag + " collides with topic " + other + ", choose another name\n")
+		}
+		return *result.QueueUrl, names[i], nil
+	}
+	return "", "", nil
+}
+
+// queueSettings returns qs with the configuration of the queue, as chosen by who created the topic
+func queueSettings(sess *session.Session, url string, qs utilities.QueueSettings) (utilities.QueueSettings, error) {
+	attributes, err := sqsManagement.QueueAttributes(sess, &url)
+	if err != nil {
+		return qs, err
+	}
+	tags, err := sqsManagement.QueueTags(sess, &url)
+	if err != nil {
+		return qs, err
+	}
+	qs = sqsManagement.WithAttributes(qs, attributes)
+	qs.Compression = tags[compressionTag]
+	return qs, nil
 }
 
 func deleteQueue(url *string) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	sess := utilities.NewSession()
 
 	err := sqsManagement.DeleteQueue(sess, url)
 	if err != nil {
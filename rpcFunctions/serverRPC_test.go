@@ -0,0 +1,144 @@
+package rpcFunctions
+
+import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"testing"
+	"time"
+)
+
+// knows tells whether s knows the subscription of the user to the topic
+func knows(s *Service, id, tag string) bool {
+	s.RwMtx.RLock()
+	defer s.RwMtx.RUnlock()
+	return isSubscribed(s.UsersIdMap[id], tag)
+}
+
+// the servers that did not create the topic give its producers the compression of the topic
+func TestSettingsOfRemoteTopic(t *testing.T) {
+	newCloud(t)
+	first, second := newService(t, "eu", 1234), newService(t, "eu", 1235)
+	replicate(t, first)
+	replicate(t, second)
+
+	var id string
+	if err := first.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var out utilities.SubscriptionOutput
+	settings := &utilities.QueueSettings{Compression: "gzip"}
+	if err := first.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "orders", Settings: settings}, &out); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the subscription on the other server", func() bool { return knows(second, id, "orders") })
+
+	// the subscription made on the first server, as RegisterCronJob and the producers do
+	var url string
+	if err := second.GetQueueURL(&utilities.RequestArg{ID: id, Tag: "orders"}, &url); err != nil {
+		t.Fatal(err)
+	}
+	if url != out.QueueURL {
+		t.Errorf("expected %q, got %q", out.QueueURL, url)
+	}
+	var got utilities.QueueSettings
+	if err := second.GetTopicSettings(&utilities.RequestArg{ID: id, Tag: "orders"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Compression != "gzip" {
+		t.Errorf("expected %q, got %q", "gzip", got.Compression)
+	}
+
+	// a new subscription on a server that never heard of the topic
+	third := newService(t, "eu", 1236)
+	replicate(t, third)
+	var other string
+	if err := third.GenerateUserId(&utilities.RequestArg{}, &other); err != nil {
+		t.Fatal(err)
+	}
+	out = utilities.SubscriptionOutput{}
+	if err := third.MakeSubscriptionToTopic(&utilities.RequestArg{ID: other, Tag: "orders"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	got = utilities.QueueSettings{}
+	if err := third.GetTopicSettings(&utilities.RequestArg{ID: other, Tag: "orders"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Compression != "gzip" {
+		t.Errorf("expected %q, got %q", "gzip", got.Compression)
+	}
+}
+
+// receiveOne returns the payload of the message the user receives, without deleting it, empty if none
+func receiveOne(t *testing.T, s *Service, id, tag string) string {
+	t.Helper()
+	var out utilities.MessagesOutput
+	if err := s.ReceiveMessages(&utilities.ReceiveArg{ID: id, Tag: tag, MaxMessages: 1, VisibilityTimeout: 30}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Messages) == 0 {
+		return ""
+	}
+	e, err := envelope.Decode(out.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.Payload
+}
+
+func TestFifoTopic(t *testing.T) {
+	cloud := newCloud(t)
+	s := newService(t, "eu", 1234)
+	var id string
+	if err := s.GenerateUserId(&utilities.RequestArg{}, &id); err != nil {
+		t.Fatal(err)
+	}
+	var out utilities.SubscriptionOutput
+	settings := &utilities.QueueSettings{Fifo: true, ReceiveWaitTime: utilities.Int64(0)}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "orders", Settings: settings}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Settings.Fifo || queueName(out.QueueURL) != "orders_eu.fifo" {
+		t.Fatalf("expected the FIFO queue orders_eu.fifo, got %s fifo %v", queueName(out.QueueURL), out.Settings.Fifo)
+	}
+	// the dead-letter queue of a FIFO queue must be FIFO too
+	if dlq := queueName(s.DeadLetterURLMap["orders"]); dlq != "orders_eu_DLQ.fifo" {
+		t.Errorf("expected the dead-letter queue orders_eu_DLQ.fifo, got %q", dlq)
+	}
+
+	groups := []struct {
+		id       string
+		payloads []string
+	}{
+		{"a", []string{"a1", "a2"}},
+		{"b", []string{"b1"}},
+	}
+	for _, group := range groups {
+		var envelopes []*envelope.Envelope
+		for _, payload := range group.payloads {
+			envelopes = append(envelopes, envelope.New("orders", id, "eu", payload))
+		}
+		if _, err := sqsManagement.SendMsgBatch(context.Background(), cloud.Session(), &out.QueueURL, envelopes, group.id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a2 waits until a1 is deleted or visible again, the other group goes on
+	for _, expected := range []string{"a1", "b1", ""} {
+		if payload := receiveOne(t, s, id, "orders"); payload != expected {
+			t.Errorf("expected %q, got %q", expected, payload)
+		}
+	}
+	cloud.Advance(31 * time.Second)
+	for _, expected := range []string{"a1", "b1"} {
+		if payload := receiveOne(t, s, id, "orders"); payload != expected {
+			t.Errorf("expected %q received again, got %q", expected, payload)
+		}
+	}
+
+	// content based deduplication needs a FIFO topic
+	settings = &utilities.QueueSettings{ContentBasedDeduplication: true}
+	if err := s.MakeSubscriptionToTopic(&utilities.RequestArg{ID: id, Tag: "news", Settings: settings}, &out); err == nil {
+		t.Errorf("expected content based deduplication refused on a standard topic")
+	}
+}
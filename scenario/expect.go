@@ -0,0 +1,80 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/client"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// check compares the messages received by a step with what it expects
+func (r *runner) check(exp *Expect, messages []*client.Message, topic string) error {
+	data := r.data(topic)
+	var failures []string
+	if exp.Count != nil && len(messages) != *exp.Count {
+		failures = append(failures, fmt.Sprintf("received %d messages, expected %d", len(messages), *exp.Count))
+	}
+	for i, want := range exp.Payloads {
+		data.Index = i
+		want, err := expand(want, data)
+		if err != nil {
+			return err
+		}
+		if i >= len(messages) {
+			failures = append(failures, fmt.Sprintf("message %d missing, expected %q", i, want))
+		} else if got := payloadOf(messages[i]); got != want {
+			failures = append(failures, fmt.Sprintf("message %d is %q, expected %q", i, got, want))
+		}
+	}
+	var match *regexp.Regexp
+	if exp.Match != "" {
+		match = regexp.MustCompile(exp.Match) // already validated
+	}
+	author, err := expand(exp.Author, data)
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string)
+	for key, value := range exp.Headers {
+		if headers[key], err = expand(value, data); err != nil {
+			return err
+		}
+	}
+
+	for i, m := range messages {
+		if match != nil && !match.MatchString(payloadOf(m)) {
+			failures = append(failures, fmt.Sprintf("message %d %q does not match %s", i, payloadOf(m), exp.Match))
+		}
+		e := m.Envelope
+		if e == nil {
+			if exp.ContentType != "" || author != "" || len(headers) > 0 {
+				failures = append(failures, fmt.Sprintf("message %d is not an envelope", i))
+			}
+			continue
+		}
+		if exp.ContentType != "" && e.ContentType != exp.ContentType {
+			failures = append(failures, fmt.Sprintf("message %d has content type %s, expected %s", i, e.ContentType, exp.ContentType))
+		}
+		if author != "" && e.Author != author {
+			failures = append(failures, fmt.Sprintf("message %d is by %s, expected %s", i, e.Author, author))
+		}
+		for key, value := range headers {
+			if got, exists := e.Headers[key]; !exists || got != value {
+				failures = append(failures, fmt.Sprintf("message %d has header %s=%q, expected %q", i, key, got, value))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New("assertion failed: " + strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// payloadOf returns the payload of m, the whole body when m is not an envelope
+func payloadOf(m *client.Message) string {
+	if m.Envelope == nil {
+		return m.Body
+	}
+	return m.Envelope.Payload
+}
@@ -0,0 +1,434 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formats of the scenario files, chosen by the extension of their name
+const (
+	JSON = "json"
+	YAML = "yaml"
+	TOML = "toml"
+)
+
+// File is a script read from a file, it remembers the line of its fields
+type File struct {
+	Name   string
+	Script Script
+	lines  map[string]int // path of a field in lower case, e.g. steps[2].expect.match, to its line
+}
+
+// Policy is a filter policy, a JSON document. YAML and TOML files write it as a mapping.
+type Policy []byte
+
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	*p = append((*p)[:0], b...)
+	return nil
+}
+
+func (p *Policy) UnmarshalYAML(node *yaml.Node) error {
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	return p.set(v)
+}
+
+func (p *Policy) UnmarshalTOML(v interface{}) error {
+	return p.set(v)
+}
+
+func (p *Policy) set(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.New("invalid filter policy: " + err.Error())
+	}
+	*p = b
+	return nil
+}
+
+// Format returns the format of the file name, by its extension
+func Format(name string) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", errors.New(name + ": unknown format, the extension must be .json, .yaml, .yml or .toml")
+}
+
+// Load reads the script in the file name. Unknown fields are errors, located like the
+// other mistakes in the file.
+func Load(name string) (*File, error) {
+	format, err := Format(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data, format)
+}
+
+// Parse decodes data, a script in format read from the file name
+func Parse(name string, data []byte, format string) (*File, error) {
+	f := &File{Name: name, lines: make(map[string]int)}
+	var err error
+	switch format {
+	case JSON:
+		err = f.decodeJSON(data)
+	case YAML:
+		err = f.decodeYAML(data)
+	case TOML:
+		err = f.decodeTOML(data)
+	default:
+		return nil, errors.New("unknown format " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Validate checks the script without contacting the server, each problem tells its line
+func (f *File) Validate() error {
+	err := f.Script.Validate()
+	problems, ok := err.(Problems)
+	if !ok {
+		return err
+	}
+	for i := range problems {
+		problems[i].File = f.Name
+		problems[i].Line = f.line(problems[i].Path)
+	}
+	return problems
+}
+
+// line returns the line of the field at path or, if unknown, of the innermost field containing it
+func (f *File) line(path string) int {
+	path = strings.ToLower(path)
+	for path != "" {
+		if line, exists := f.lines[path]; exists {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+var indexes = regexp.MustCompile(`\[[0-9]+\]`)
+
+// keyLine returns the first line of the field key, a path without indexes
+func (f *File) keyLine(key string) int {
+	key = strings.ToLower(key)
+	first := 0
+	for path, line := range f.lines {
+		if indexes.ReplaceAllString(path, "") == key && (first == 0 || line < first) {
+			first = line
+		}
+	}
+	return first
+}
+
+func (f *File) record(path string, line int) {
+	path = strings.ToLower(path)
+	if _, exists := f.lines[path]; !exists {
+		f.lines[path] = line
+	}
+}
+
+// problem returns the error of a file that cannot be decoded
+func (f *File) problem(line int, message string) error {
+	return Problems{{File: f.Name, Line: line, Message: message}}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonIndex matches the indexes of the fields named by encoding/json, e.g. steps.0.count
+var jsonIndex = regexp.MustCompile(`\.([0-9]+)`)
+
+func (f *File) decodeJSON(data []byte) error {
+	f.walkJSON(json.NewDecoder(bytes.NewReader(data)), data, "")
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&f.Script)
+	if err == io.EOF {
+		return f.problem(0, "the file is empty")
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		if _, err = decoder.Token(); err != io.EOF {
+			return f.problem(lineAt(data, decoder.InputOffset()), "data after the end of the script")
+		}
+		return nil
+	case errors.As(err, &syntaxErr):
+		return f.problem(lineAt(data, syntaxErr.Offset), syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		path := jsonIndex.ReplaceAllString(typeErr.Field, "[$1]")
+		return Problems{{File: f.Name, Line: lineAt(data, typeErr.Offset), Path: path, Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return f.problem(f.fieldLine(field), "unknown field "+field)
+	}
+	return f.problem(0, err.Error())
+}
+
+// fieldLine returns the first line of a field named field, at any depth
+func (f *File) fieldLine(field string) int {
+	field = strings.ToLower(field)
+	first := 0
+	for path, line := range f.lines {
+		if (path == field || strings.HasSuffix(path, "."+field)) && (first == 0 || line < first) {
+			first = line
+		}
+	}
+	return first
+}
+
+// walkJSON records the line of the value read next and of the fields within it
+func (f *File) walkJSON(decoder *json.Decoder, data []byte, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		f.record(path, lineAt(data, decoder.InputOffset()))
+	}
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			f.record(join(path, name), lineAt(data, decoder.InputOffset()))
+			if err = f.walkJSON(decoder, data, join(path, name)); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			if err = f.walkJSON(decoder, data, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	}
+	return err
+}
+
+// lineAt returns the line of the byte at offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+var (
+	yamlLine         = regexp.MustCompile(`^(?:yaml: )?line ([0-9]+): (.*)$`)
+	yamlUnknownField = regexp.MustCompile(`^field (.*) not found in type .*$`)
+)
+
+func (f *File) decodeYAML(data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err == nil {
+		f.walkYAML(&root, "")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&f.Script)
+	if err == nil {
+		return nil
+	}
+	if err == io.EOF {
+		return f.problem(0, "the file is empty")
+	}
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	var problems Problems
+	for _, message := range messages {
+		problem := Problem{File: f.Name, Message: message}
+		if m := yamlLine.FindStringSubmatch(message); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Message = yamlUnknownField.ReplaceAllString(m[2], "unknown field $1")
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+func (f *File) walkYAML(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			f.walkYAML(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := join(path, node.Content[i].Value)
+			f.record(key, node.Content[i].Line)
+			f.walkYAML(node.Content[i+1], key)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			item := fmt.Sprintf("%s[%d]", path, i)
+			f.record(item, child.Line)
+			f.walkYAML(child, item)
+		}
+	}
+}
+
+var tomlLine = regexp.MustCompile(`^toml: line [0-9]+(?: \(last key ".*"\))?: ((?s).*)$`)
+
+func (f *File) decodeTOML(data []byte) error {
+	f.scanTOML(data)
+
+	metadata, err := toml.Decode(string(data), &f.Script)
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		problem := Problem{File: f.Name, Line: parseErr.Position.Line, Path: parseErr.LastKey, Message: parseErr.Error()}
+		if m := tomlLine.FindStringSubmatch(problem.Message); m != nil {
+			problem.Message = m[1]
+		}
+		return Problems{problem}
+	}
+	if err != nil {
+		return f.problem(0, err.Error())
+	}
+	var problems Problems
+	for _, key := range metadata.Undecoded() {
+		if _, isPolicy := f.Script.TopicFilters[keyTopic(key)]; isPolicy && len(key) > 2 && key[0] == "topic_filters" {
+			// the content of a filter policy is checked when validating it
+			continue
+		}
+		problems = append(problems, Problem{File: f.Name, Line: f.keyLine(key.String()), Message: "unknown field " + key.String()})
+	}
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+		return problems
+	}
+	return nil
+}
+
+func keyTopic(key toml.Key) string {
+	if len(key) < 2 {
+		return ""
+	}
+	return key[1]
+}
+
+// scanTOML records the line of the tables and keys. It follows the headers and the assignments
+// at the start of a line, a value spanning several lines counts as one field.
+func (f *File) scanTOML(data []byte) {
+	tables := make(map[string]int) // entries of each array of tables
+	resolve := func(parts []string) string {
+		path := ""
+		for _, part := range parts {
+			path = join(path, part)
+			if n, isArray := tables[path]; isArray {
+				path = fmt.Sprintf("%s[%d]", path, n-1)
+			}
+		}
+		return path
+	}
+	table := ""
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[["):
+			end := strings.Index(line, "]]")
+			if end < 0 {
+				continue
+			}
+			parts := keyParts(line[2:end])
+			if len(parts) == 0 {
+				continue
+			}
+			array := join(resolve(parts[:len(parts)-1]), parts[len(parts)-1])
+			f.record(array, n+1)
+			table = fmt.Sprintf("%s[%d]", array, tables[array])
+			tables[array]++
+			f.record(table, n+1)
+		case strings.HasPrefix(line, "["):
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			table = resolve(keyParts(line[1:end]))
+			f.record(table, n+1)
+		default:
+			if eq := strings.Index(line, "="); eq > 0 {
+				parts := keyParts(line[:eq])
+				if len(parts) > 0 {
+					f.record(join(table, strings.Join(parts, ".")), n+1)
+				}
+			}
+		}
+	}
+}
+
+// keyParts splits a dotted TOML key, the quoted parts may contain dots
+func keyParts(key string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	for _, r := range key {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				part.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	if p := strings.TrimSpace(part.String()); p != "" {
+		parts = append(parts, p)
+	}
+	return parts
+}
@@ -0,0 +1,149 @@
+package scenario
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name, format string
+	}{
+		{"a.json", JSON},
+		{"dir/a.YAML", YAML},
+		{"a.yml", YAML},
+		{"a.toml", TOML},
+		{"a.txt", ""},
+		{"json", ""},
+	}
+
+	for _, test := range tests {
+		format, err := Format(test.name)
+		if format != test.format || (err != nil) != (test.format == "") {
+			t.Errorf("expected %q for %s, got %q, %v", test.format, test.name, format, err)
+		}
+	}
+}
+
+// the same script in every format: the second step has no messages, on the line given
+var scripts = []struct {
+	format string
+	data   string
+	line   int
+}{
+	{JSON, `{
+  "topic_filters": {"news": {"region": ["EU"]}},
+  "steps": [
+    {"action": "SUBSCRIBE", "topic": "news"},
+    {
+      "action": "SEND", "topic": "news"
+    }
+  ]
+}`, 5},
+	{YAML, `topic_filters:
+  news:
+    region: [EU]
+steps:
+  - action: SUBSCRIBE
+    topic: news
+  - action: SEND
+    topic: news
+`, 7},
+	{TOML, `[topic_filters.news]
+region = ["EU"]
+
+[[steps]]
+action = "SUBSCRIBE"
+topic = "news"
+[[steps]]
+action = "SEND"
+topic = "news"
+`, 7},
+}
+
+func TestProblemLines(t *testing.T) {
+	for _, script := range scripts {
+		f, err := Parse("script."+script.format, []byte(script.data), script.format)
+		if err != nil {
+			t.Fatalf("expected the %s script to be parsed, got %v", script.format, err)
+		}
+		if policy := string(f.Script.TopicFilters["news"]); !strings.Contains(policy, `"region"`) {
+			t.Errorf("expected the %s filter policy as JSON, got %s", script.format, policy)
+		}
+
+		expected := fmt.Sprintf("script.%s:%d: steps[1].messages: ", script.format, script.line)
+		err = f.Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected %q..., got %v", expected, err)
+		}
+	}
+}
+
+func TestParseErrorLines(t *testing.T) {
+	tests := []struct {
+		format, data string
+		line         int
+		text         string
+	}{
+		{JSON, "", 0, "empty"},
+		{JSON, "{\n\"steps\": [\n{\"action\": }\n]}", 3, ""},
+		{JSON, "{\n\"steps\": [\n{\"number\": \"two\"}\n]}", 3, "expected int"},
+		{JSON, "{\n\"steps\": [\n{\"actoin\": \"GET\"}\n]}", 3, "unknown field actoin"},
+		{JSON, "{}\n{}", 2, "after the end"},
+		{YAML, "", 0, "empty"},
+		{YAML, "steps:\n  - action: GET\n    actoin: GET\n", 3, "unknown field actoin"},
+		{YAML, "steps:\n  - action: GET\n    number: two\n", 3, ""},
+		{TOML, "[[steps]]\naction = GET\n", 2, ""},
+		{TOML, "[[steps]]\naction = \"GET\"\n\n[[steps]]\nactoin = \"GET\"\n", 5, "unknown field steps.actoin"},
+		{"xml", "<steps/>", 0, "unknown format"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse("script", []byte(test.data), test.format)
+		if err == nil {
+			t.Errorf("expected an error parsing %q as %s", test.data, test.format)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.text) {
+			t.Errorf("expected %q in the error, got %v", test.text, err)
+		}
+		if test.line == 0 {
+			continue
+		}
+		if problems, ok := err.(Problems); !ok || problems[0].Line != test.line {
+			t.Errorf("expected the error at line %d, got %v", test.line, err)
+		}
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	tests := []struct {
+		problem Problem
+		text    string
+	}{
+		{Problem{Message: "m"}, "m"},
+		{Problem{Path: "steps[0]", Message: "m"}, "steps[0]: m"},
+		{Problem{File: "a.yaml", Path: "steps[0]", Message: "m"}, "a.yaml: steps[0]: m"},
+		{Problem{File: "a.yaml", Line: 3, Path: "steps[0]", Message: "m"}, "a.yaml:3: steps[0]: m"},
+	}
+
+	for _, test := range tests {
+		if text := test.problem.String(); text != test.text {
+			t.Errorf("expected %q, got %q", test.text, text)
+		}
+	}
+}
+
+// the examples shipped with the client are valid
+func TestLoadExamples(t *testing.T) {
+	for _, name := range []string{"scenario.json", "scenario.yaml", "scenario.toml", "actions.json"} {
+		f, err := Load("../jsons/" + name)
+		if err != nil {
+			t.Fatalf("expected %s to load, got %v", name, err)
+		}
+		if err = f.Validate(); err != nil {
+			t.Errorf("expected %s to be valid, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/client"
+	"SDCC-A3-Project/clientCli"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// runner executes the steps of a script on behalf of the user of a Client
+type runner struct {
+	client    *client.Client
+	script    *Script
+	out       io.Writer
+	vars      map[string]string
+	seq       int // messages sent so far
+	iteration int // of the innermost loop
+}
+
+// Run validates the script and executes it, registering the user if the script has no id.
+// It stops at the first step failing, assertions included.
+func Run(ctx context.Context, c *client.Client, sc *Script, out io.Writer) error {
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+	r := &runner{client: c, script: sc, out: out, vars: make(map[string]string)}
+	if c.ID() == "" {
+		//blocking call.. we cannot do anything without a user id
+		id, err := c.Register(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "user ID: "+id)
+	}
+	if err := r.initVars(); err != nil {
+		return err
+	}
+	return r.run(ctx, sc.Plan())
+}
+
+// initVars expands the variables of the script once, in alphabetical order: a variable
+// can refer to the ones before it
+func (r *runner) initVars() error {
+	names := make([]string, 0, len(r.script.Variables))
+	for name := range r.script.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := expand(r.script.Variables[name], r.data(""))
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		r.vars[name] = value
+	}
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, steps []Step) error {
+	for i := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.step(ctx, &steps[i]); err != nil {
+			name := steps[i].Action
+			if steps[i].Topic != "" {
+				name += " " + steps[i].Topic
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// data returns what the templates of a step refer to
+func (r *runner) data(topic string) TemplateData {
+	return TemplateData{User: r.client.ID(), Zone: r.client.Zone(), Topic: topic, Seq: r.seq,
+		Iteration: r.iteration, Time: time.Now().UTC(), Vars: r.vars}
+}
+
+func (r *runner) step(ctx context.Context, s *Step) error {
+	topic, err := expand(s.Topic, r.data(s.Topic))
+	if err != nil {
+		return err
+	}
+	switch s.Action {
+	case Subscribe:
+		return r.subscribe(ctx, topic)
+	case Unsubscribe:
+		if err = r.client.Unsubscribe(ctx, topic); err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, "unsubscribed "+topic)
+	case Publish:
+		reg, err := r.client.RegisterPublisher(ctx, topic, r.topicOptions(topic)...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, reg.QueueURL)
+	case Unpublish:
+		if err = r.client.UnregisterPublisher(ctx, topic); err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, "unregistered from "+topic)
+	case List:
+		// the topic, if any, is a name or pattern selecting what to list
+		list, err := r.client.ListTopics(ctx, topic)
+		if err != nil {
+			return err
+		}
+		clientCli.PrintTopics(r.out, list)
+	case Create:
+		// the topic is created with the settings and description we would give subscribing
+		info, err := r.client.CreateTopic(ctx, topic, r.topicOptions(topic)...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "created %s (%s)\n", info.Name, info.DeliveryMode)
+	case Delete:
+		if err = r.client.DeleteTopic(ctx, topic); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "deleted %s\n", topic)
+	case Replay:
+		return r.replay(ctx, s, topic)
+	case Cron:
+		// the template is expanded by the servers at every run
+		id, err := r.client.RegisterCronJob(ctx, topic, s.Schedule, s.Template,
+			client.WithContentType(s.ContentType), client.WithHeaders(s.Headers))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "job %s publishes on %s at %q\n", id, topic, s.Schedule)
+	case DeleteCron:
+		for _, id := range s.JobIDs {
+			if err = r.client.DeleteCronJob(ctx, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(r.out, "deleted job %s\n", id)
+		}
+	case ListCron:
+		jobs, err := r.client.ListCronJobs(ctx, topic)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			fmt.Fprintf(r.out, "%s\t%s\t%q\t%s\n", job.ID, job.Topic, job.Schedule, job.Template)
+		}
+	case Cancel:
+		for _, id := range s.ScheduleIDs {
+			if err = r.client.CancelScheduled(ctx, topic, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(r.out, "cancelled %s\n", id)
+		}
+	case Send:
+		return r.send(ctx, s, topic)
+	case Request:
+		return r.request(ctx, s, topic)
+	case Get, Reply:
+		return r.get(ctx, s, topic)
+	case Loop:
+		saved := r.iteration
+		defer func() { r.iteration = saved }()
+		for i := 0; i < s.Count; i++ {
+			r.iteration = i
+			if err = r.run(ctx, s.Steps); err != nil {
+				return fmt.Errorf("iteration %d: %w", i, err)
+			}
+		}
+	case Wait:
+		d, err := time.ParseDuration(s.Duration)
+		if err != nil {
+			return err
+		}
+		return sleep(ctx, d)
+	case Set:
+		// all the values see the variables as they were before the step
+		values := make(map[string]string)
+		for name, value := range s.Variables {
+			if values[name], err = expand(value, r.data(topic)); err != nil {
+				return fmt.Errorf("variable %s: %w", name, err)
+			}
+		}
+		for name, value := range values {
+			r.vars[name] = value
+		}
+	case Assert:
+		result, err := expand(s.Condition, r.data(topic))
+		if err != nil {
+			return err
+		}
+		if result != "true" {
+			return errors.New("assertion failed: " + s.Condition + " is " + result)
+		}
+		fmt.Fprintln(r.out, "assertion passed: "+s.Condition)
+	}
+	return nil
+}
+
+// topicOptions returns the settings and description given to topic, if we create it
+func (r *runner) topicOptions(topic string) []client.TopicOption {
+	var opts []client.TopicOption
+	if settings, exists := r.script.TopicSettings[topic]; exists {
+		opts = append(opts, client.WithSettings(settings))
+	}
+	if description, exists := r.script.TopicDescriptions[topic]; exists {
+		opts = append(opts, client.WithDescription(description))
+	}
+	return opts
+}
+
+func (r *runner) subscribe(ctx context.Context, topic string) error {
+	opts := r.topicOptions(topic)
+	if filter, exists := r.script.TopicFilters[topic]; exists {
+		opts = append(opts, client.WithFilter(string(filter)))
+	}
+	reg, err := r.client.Subscribe(ctx, topic, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, reg.QueueURL)
+	for _, m := range reg.Retained {
+		fmt.Fprintln(r.out, "retained message:")
+		clientCli.PrintMessage(r.out, m)
+	}
+	return nil
+}
+
+// sendOptions returns the options of the messages sent by the step
+func (r *runner) sendOptions(s *Step, topic string) ([]client.SendOption, error) {
+	data := r.data(topic)
+	correlationID, err := expand(s.CorrelationID, data)
+	if err != nil {
+		return nil, err
+	}
+	var headers map[string]string
+	if len(s.Headers) > 0 {
+		headers = make(map[string]string)
+		for key, value := range s.Headers {
+			if headers[key], err = expand(value, data); err != nil {
+				return nil, fmt.Errorf("header %s: %w", key, err)
+			}
+		}
+	}
+	opts := []client.SendOption{
+		client.WithContentType(s.ContentType),
+		client.WithCorrelationID(correlationID),
+		client.WithHeaders(headers),
+		client.WithGroupID(s.GroupID),
+		client.WithDeduplicationIDs(s.DeduplicationIDs...),
+	}
+	if s.DeliverAt != "" {
+		deliverAt, err := time.Parse(time.RFC3339, s.DeliverAt)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithDeliverAt(deliverAt))
+	} else if s.Delay != "" {
+		delay, err := time.ParseDuration(s.Delay)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithDelay(delay))
+	}
+	return opts, nil
+}
+
+// payloads expands the messages of the step, each one is a new message of the script
+func (r *runner) payloads(s *Step, topic string) ([]string, error) {
+	payloads := make([]string, len(s.Message))
+	for j, message := range s.Message {
+		r.seq++
+		data := r.data(topic)
+		data.Index = j
+		var err error
+		if payloads[j], err = expand(message, data); err != nil {
+			return nil, fmt.Errorf("message %d: %w", j, err)
+		}
+	}
+	return payloads, nil
+}
+
+func (r *runner) send(ctx context.Context, s *Step, topic string) error {
+	opts, err := r.sendOptions(s, topic)
+	if err != nil {
+		return err
+	}
+	payloads, err := r.payloads(s, topic)
+	if err != nil {
+		return err
+	}
+	result, err := r.client.Send(ctx, topic, payloads, opts...)
+	if err != nil {
+		return err
+	}
+	for j, id := range result.ScheduleIDs {
+		fmt.Fprintf(r.out, "message %s scheduled as %s\n", result.Messages[j].MessageID, id)
+	}
+	if len(result.ScheduleIDs) > 0 {
+		return nil
+	}
+	for _, f := range result.Failures {
+		fmt.Fprintf(r.out, "message %q not sent: %s %s\n", payloads[f.Index], f.Code, f.Message)
+	}
+	fmt.Fprintf(r.out, "Sent %d messages to queue\n", len(result.Messages)-len(result.Failures))
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("%d messages not sent", len(result.Failures))
+	}
+	return nil
+}
+
+// request sends each message of the step as a request and prints the responses
+func (r *runner) request(ctx context.Context, s *Step, topic string) error {
+	timeout := client.DefaultRequestTimeout
+	if s.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(s.Timeout); err != nil {
+			return err
+		}
+	}
+	opts, err := r.sendOptions(s, topic)
+	if err != nil {
+		return err
+	}
+	payloads, err := r.payloads(s, topic)
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		requestCtx, cancel := context.WithTimeout(ctx, timeout)
+		response, err := r.client.Request(requestCtx, topic, payload, opts...)
+		cancel()
+		if err == context.DeadlineExceeded {
+			err = errors.New("no response within " + timeout.String())
+		}
+		if err != nil {
+			return fmt.Errorf("request %q: %w", payload, err)
+		}
+		clientCli.PrintEnvelope(r.out, response)
+		if s.Save != "" {
+			r.vars[s.Save] = response.Payload
+		}
+	}
+	return nil
+}
+
+// get receives s.Number messages, answering the requests among them with the message of a REPLY.
+// It gives up after utilities.Attempts polls finding nothing, or after the timeout of the step.
+func (r *runner) get(ctx context.Context, s *Step, topic string) error {
+	var answer string
+	if s.Action == Reply {
+		var err error
+		if answer, err = expand(s.Message[0], r.data(topic)); err != nil {
+			return err
+		}
+	}
+	var deadline time.Time
+	if s.Timeout != "" {
+		timeout, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return err
+		}
+		deadline = time.Now().Add(timeout)
+	}
+
+	var received []*client.Message
+	attempts := utilities.Attempts
+	for len(received) < s.Number {
+		messages, err := r.client.Receive(ctx, topic, s.Number-len(received))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Fprintln(r.out, "Got an error receiving messages:")
+			fmt.Fprintln(r.out, err)
+		}
+		for _, m := range messages {
+			clientCli.PrintMessage(r.out, m)
+			if answer != "" && m.Envelope != nil && m.Envelope.ReplyTo != "" {
+				if err = r.client.Reply(ctx, m.Envelope, answer); err != nil {
+					fmt.Fprintln(r.out, "Got an error answering request "+m.Envelope.MessageID+":")
+					fmt.Fprintln(r.out, err)
+				}
+			}
+		}
+		received = append(received, messages...)
+		if len(messages) > 0 {
+			attempts = utilities.Attempts
+			continue
+		}
+		fmt.Fprintln(r.out, "no messages available")
+		pause := time.Second * 10
+		if !deadline.IsZero() {
+			if time.Now().After(deadline) {
+				break
+			}
+			pause = time.Second
+		} else if attempts--; attempts == 0 {
+			break
+		}
+		if err = sleep(ctx, pause); err != nil {
+			return err
+		}
+	}
+
+	if s.Save != "" && len(received) > 0 {
+		r.vars[s.Save] = payloadOf(received[len(received)-1])
+	}
+	if s.Expect != nil {
+		if err := r.check(s.Expect, received, topic); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "%d messages as expected\n", len(received))
+	}
+	return nil
+}
+
+// replay prints up to s.Number messages retained by the server, all of them if zero
+func (r *runner) replay(ctx context.Context, s *Step, topic string) error {
+	var since time.Time
+	if s.Since != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s.Since); err != nil {
+			return err
+		}
+	}
+	offset := s.Offset
+	printed := 0
+	for s.Number == 0 || printed < s.Number {
+		max := utilities.MaxReplay
+		if s.Number != 0 && s.Number-printed < utilities.MaxReplay {
+			max = s.Number - printed
+		}
+		page, err := r.client.Replay(ctx, topic, offset, since, max)
+		if err != nil {
+			return err
+		}
+		if page.NextOffset == offset && since.IsZero() {
+			break
+		}
+		for i, m := range page.Messages {
+			fmt.Fprintf(r.out, "Offset:         %d\n", page.Offsets[i])
+			clientCli.PrintMessage(r.out, m)
+		}
+		printed += len(page.Messages)
+		// the next page goes on from the offset reached
+		offset = page.NextOffset
+		since = time.Time{}
+	}
+	fmt.Fprintf(r.out, "replayed %d messages\n", printed)
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/client"
+	"SDCC-A3-Project/envelope"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// newRunner returns a runner for the steps that need no server
+func newRunner(vars map[string]string) (*runner, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &runner{client: &client.Client{}, script: &Script{}, out: &out, vars: vars}, &out
+}
+
+func TestLoopSetAssert(t *testing.T) {
+	r, out := newRunner(map[string]string{"trace": ""})
+	steps := []Step{
+		{Action: Loop, Count: 3, Steps: []Step{
+			{Action: Set, Variables: map[string]string{"trace": "{{.Vars.trace}}{{.Iteration}}"}},
+		}},
+		{Action: Assert, Condition: `{{eq .Vars.trace "012"}}`},
+	}
+
+	if err := r.run(context.Background(), steps); err != nil {
+		t.Fatalf("expected the steps to succeed, got %v", err)
+	}
+	if !strings.Contains(out.String(), "assertion passed") {
+		t.Errorf("expected the assertion to be reported, got %q", out.String())
+	}
+}
+
+// the values of a SET see the variables as they were before the step
+func TestSetSwap(t *testing.T) {
+	r, _ := newRunner(map[string]string{"a": "1", "b": "2"})
+	steps := []Step{{Action: Set, Variables: map[string]string{"a": "{{.Vars.b}}", "b": "{{.Vars.a}}"}}}
+
+	if err := r.run(context.Background(), steps); err != nil {
+		t.Fatal(err)
+	}
+	if r.vars["a"] != "2" || r.vars["b"] != "1" {
+		t.Errorf("expected a=2 b=1, got %v", r.vars)
+	}
+}
+
+func TestAssertFails(t *testing.T) {
+	r, _ := newRunner(map[string]string{"x": "1"})
+	steps := []Step{
+		{Action: Loop, Count: 2, Steps: []Step{{Action: Assert, Condition: `{{eq .Iteration 0}}`}}},
+		{Action: Set, Variables: map[string]string{"x": "2"}},
+	}
+
+	err := r.run(context.Background(), steps)
+	if err == nil || !strings.Contains(err.Error(), "iteration 1") {
+		t.Errorf("expected the assertion to fail at iteration 1, got %v", err)
+	}
+	if r.vars["x"] != "1" {
+		t.Errorf("expected the script to stop at the failed assertion, got x=%s", r.vars["x"])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r, _ := newRunner(map[string]string{"who": "abc"})
+	message := func(author, payload string) *client.Message {
+		e := envelope.New("news", author, "EU", payload)
+		e.Headers = map[string]string{"region": "EU"}
+		return &client.Message{Envelope: e}
+	}
+	two := 2
+	messages := []*client.Message{message("abc", "hello 0"), message("abc", "hello 1")}
+
+	tests := []struct {
+		expect Expect
+		pass   bool
+	}{
+		{Expect{Count: &two, Payloads: []string{"hello {{.Index}}", "hello {{.Index}}"}}, true},
+		{Expect{Match: `^hello \d$`, Author: "{{.Vars.who}}", Headers: map[string]string{"region": "EU"}}, true},
+		{Expect{ContentType: envelope.DefaultContentType}, true},
+		{Expect{Payloads: []string{"hello 0", "hello 1", "hello 2"}}, false},
+		{Expect{Payloads: []string{"hello 1"}}, false},
+		{Expect{Match: "0$"}, false},
+		{Expect{Author: "def"}, false},
+		{Expect{Headers: map[string]string{"region": "US"}}, false},
+		{Expect{ContentType: "application/json"}, false},
+	}
+	for _, test := range tests {
+		err := r.check(&test.expect, messages, "news")
+		if test.pass && err != nil {
+			t.Errorf("expected %+v to pass, got %v", test.expect, err)
+		}
+		if !test.pass && err == nil {
+			t.Errorf("expected %+v to fail", test.expect)
+		}
+	}
+}
+
+// a variable is expanded once, before the first step, and sees the ones before it
+func TestInitVars(t *testing.T) {
+	r, _ := newRunner(make(map[string]string))
+	r.script = &Script{Variables: map[string]string{
+		"a":   "run-{{random 6}}",
+		"b":   "{{.Vars.a}}/sensors",
+		"msg": "plain",
+	}}
+
+	if err := r.initVars(); err != nil {
+		t.Fatalf("expected the variables to be expanded, got %v", err)
+	}
+	if len(r.vars["a"]) != len("run-")+6 || r.vars["b"] != r.vars["a"]+"/sensors" || r.vars["msg"] != "plain" {
+		t.Errorf("expected b to extend a, got %v", r.vars)
+	}
+	if err := r.initVars(); err != nil || r.vars["b"] != r.vars["a"]+"/sensors" {
+		t.Errorf("expected the values to stay consistent, got %v, %v", r.vars, err)
+	}
+
+	r.script = &Script{Variables: map[string]string{"a": "{{.Vars.b}}", "b": "x"}}
+	r.vars = make(map[string]string)
+	if err := r.initVars(); err == nil {
+		t.Errorf("expected an error referring to a later variable, got %v", r.vars)
+	}
+}
@@ -0,0 +1,125 @@
+// Package scenario runs the scripts of the client: ordered steps mixing subscriptions and
+// messages, loops, waits, message templates, variables and assertions on what is received
+package scenario
+
+import "SDCC-A3-Project/utilities"
+
+// Script is what the client executes. The legacy lists run in the order subscribe, publish,
+// actions, steps, unsubscribe, unpublish; a script can also be made of steps only.
+type Script struct {
+	ID                string   `json:"user_id" yaml:"user_id" toml:"user_id"`
+	SubscribeTopics   []string `json:"subscribe_topics" yaml:"subscribe_topics" toml:"subscribe_topics"`       // need to activate a subscription for these topics
+	UnsubscribeTopics []string `json:"unsubscribe_topics" yaml:"unsubscribe_topics" toml:"unsubscribe_topics"` // need to unsubscribe these topics
+	PublishTopics     []string `json:"publish_topics" yaml:"publish_topics" toml:"publish_topics"`             // we send to these topics without subscribing
+	UnpublishTopics   []string `json:"unpublish_topics" yaml:"unpublish_topics" toml:"unpublish_topics"`       // we stop sending to these topics
+	Actions           []Step   `json:"actions" yaml:"actions" toml:"actions"`                                  // executed after the subscriptions
+	Steps             []Step   `json:"steps" yaml:"steps" toml:"steps"`                                        // executed after the actions, in order
+	// initial value of the variables, {{.Vars.name}} in the templates; templates themselves, expanded
+	// once before the first step in alphabetical order
+	Variables map[string]string `json:"variables" yaml:"variables" toml:"variables"`
+	// queue configuration of the topics created by our subscriptions
+	TopicSettings map[string]utilities.QueueSettings `json:"topic_settings" yaml:"topic_settings" toml:"topic_settings"`
+	// description of the topics created by our subscriptions
+	TopicDescriptions map[string]string `json:"topic_descriptions" yaml:"topic_descriptions" toml:"topic_descriptions"`
+	// filter policy of our subscriptions, the topics not listed receive every message
+	TopicFilters map[string]Policy `json:"topic_filters" yaml:"topic_filters" toml:"topic_filters"`
+	// master key encrypting the messages we send on a topic, the topics not listed are not encrypted
+	TopicKeys map[string]string `json:"topic_keys" yaml:"topic_keys" toml:"topic_keys"`
+}
+
+// actions of the steps
+const (
+	Subscribe   = "SUBSCRIBE"
+	Unsubscribe = "UNSUBSCRIBE"
+	Publish     = "PUBLISH"
+	Unpublish   = "UNPUBLISH"
+	Send        = "SEND"
+	Get         = "GET"
+	Request     = "REQUEST"
+	Reply       = "REPLY"
+	Replay      = "REPLAY"
+	List        = "LIST"
+	Create      = "CREATE"
+	Delete      = "DELETE"
+	Cancel      = "CANCEL"
+	Cron        = "CRON"
+	DeleteCron  = "DELETE_CRON"
+	ListCron    = "LIST_CRON"
+	Loop        = "LOOP"
+	Wait        = "WAIT"
+	Set         = "SET"
+	Assert      = "ASSERT"
+)
+
+// Step is an action of the script. Topic, messages, headers, variables and conditions are
+// text/template documents, see TemplateData.
+type Step struct {
+	Action  string   `json:"action" yaml:"action" toml:"action"`
+	Topic   string   `json:"topic" yaml:"topic" toml:"topic"`
+	Message []string `json:"messages" yaml:"messages" toml:"messages"`
+	Number  int      `json:"number" yaml:"number" toml:"number"` // messages to receive, GET, REPLY and REPLAY only
+	// envelope of the sent messages
+	ContentType   string            `json:"content_type" yaml:"content_type" toml:"content_type"`       // default text/plain
+	CorrelationID string            `json:"correlation_id" yaml:"correlation_id" toml:"correlation_id"` // ties together the messages of the same conversation
+	Headers       map[string]string `json:"headers" yaml:"headers" toml:"headers"`
+	// FIFO topics only
+	GroupID          string   `json:"group_id" yaml:"group_id" toml:"group_id"`                            // messages of the same group are delivered in order, default the user id
+	DeduplicationIDs []string `json:"deduplication_ids" yaml:"deduplication_ids" toml:"deduplication_ids"` // one for each message, by default the hash of the payload on topics with content based deduplication, the message id on the others
+	// the messages are kept by the server and delivered later
+	DeliverAt   string   `json:"deliver_at" yaml:"deliver_at" toml:"deliver_at"`       // RFC 3339 time of delivery
+	Delay       string   `json:"delay" yaml:"delay" toml:"delay"`                      // time before the delivery, e.g. "90m" or "48h"
+	ScheduleIDs []string `json:"schedule_ids" yaml:"schedule_ids" toml:"schedule_ids"` // scheduled messages to cancel, CANCEL only
+	// recurring publications run by the servers
+	Schedule string   `json:"schedule" yaml:"schedule" toml:"schedule"` // cron expression in UTC, CRON only
+	Template string   `json:"template" yaml:"template" toml:"template"` // text/template of the payload expanded by the servers, CRON only
+	JobIDs   []string `json:"job_ids" yaml:"job_ids" toml:"job_ids"`    // jobs to delete, DELETE_CRON only
+	// how long a REQUEST waits for the response, default 30s; how long a GET or REPLY
+	// waits for its messages, by default until utilities.Attempts polls find nothing
+	Timeout string `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// REPLAY starts from Offset, or from Since if set
+	Offset int64  `json:"offset" yaml:"offset" toml:"offset"`
+	Since  string `json:"since" yaml:"since" toml:"since"` // RFC 3339 time
+	// LOOP repeats Steps Count times
+	Count int    `json:"count" yaml:"count" toml:"count"`
+	Steps []Step `json:"steps" yaml:"steps" toml:"steps"`
+	// WAIT pauses for Duration, e.g. "5s"
+	Duration string `json:"duration" yaml:"duration" toml:"duration"`
+	// SET assigns the variables
+	Variables map[string]string `json:"variables" yaml:"variables" toml:"variables"`
+	// ASSERT fails the script unless Condition expands to "true"
+	Condition string `json:"condition" yaml:"condition" toml:"condition"`
+	// GET and REPLY check the messages received, the script fails otherwise
+	Expect *Expect `json:"expect" yaml:"expect" toml:"expect"`
+	// GET, REPLY and REQUEST store the payload of the last message received in this variable
+	Save string `json:"save" yaml:"save" toml:"save"`
+}
+
+// Expect describes the messages a step must receive
+type Expect struct {
+	Count       *int              `json:"count" yaml:"count" toml:"count"`                      // exact number of messages
+	Payloads    []string          `json:"payloads" yaml:"payloads" toml:"payloads"`             // payloads in order of reception, templates
+	Match       string            `json:"match" yaml:"match" toml:"match"`                      // regular expression every payload must match
+	Headers     map[string]string `json:"headers" yaml:"headers" toml:"headers"`                // headers every message must have, templates
+	ContentType string            `json:"content_type" yaml:"content_type" toml:"content_type"` // content type of every message
+	Author      string            `json:"author" yaml:"author" toml:"author"`                   // author of every message, a template
+}
+
+// Plan returns the steps of the script in the order they are executed
+func (sc *Script) Plan() []Step {
+	var plan []Step
+	for _, topic := range sc.SubscribeTopics {
+		plan = append(plan, Step{Action: Subscribe, Topic: topic})
+	}
+	for _, topic := range sc.PublishTopics {
+		plan = append(plan, Step{Action: Publish, Topic: topic})
+	}
+	plan = append(plan, sc.Actions...)
+	plan = append(plan, sc.Steps...)
+	for _, topic := range sc.UnsubscribeTopics {
+		plan = append(plan, Step{Action: Unsubscribe, Topic: topic})
+	}
+	for _, topic := range sc.UnpublishTopics {
+		plan = append(plan, Step{Action: Unpublish, Topic: topic})
+	}
+	return plan
+}
@@ -0,0 +1,67 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/imports/shortuuid-master"
+	"errors"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what the templates of a step can refer to
+type TemplateData struct {
+	User      string            // id of the user
+	Zone      string            // zone of the user
+	Topic     string            // topic of the step
+	Index     int               // position of the message in the step, from 0
+	Seq       int               // messages sent by the script, this one included
+	Iteration int               // iteration of the innermost loop, from 0
+	Time      time.Time         // when the template is expanded, UTC
+	Vars      map[string]string // variables of the script
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var templateFuncs = template.FuncMap{
+	// random returns n random letters and digits
+	"random": func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphanumeric[rand.Intn(len(alphanumeric))]
+		}
+		return string(b)
+	},
+	// randomInt returns a random number in [min, max]
+	"randomInt": func(min, max int) (int, error) {
+		if max < min {
+			return 0, errors.New("randomInt: max lower than min")
+		}
+		return min + rand.Intn(max-min+1), nil
+	},
+	"uuid":      shortuuid.New,
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+}
+
+// parseTemplate parses text, a missing variable is an error
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("step").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// expand executes the template text with data, a text without actions is returned as it is
+func expand(text string, data TemplateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,276 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/cronJobs"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/topics"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem is a mistake found in a script. Path locates it, e.g. steps[2].expect.match;
+// File and Line are set when the script was read from a file
+type Problem struct {
+	File    string
+	Line    int
+	Path    string
+	Message string
+}
+
+// String returns the problem as file:line: path: message, leaving out what is unknown
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(p.File)
+		if p.Line > 0 {
+			fmt.Fprintf(&b, ":%d", p.Line)
+		}
+		b.WriteString(": ")
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// Problems is the error returned by Validate, one entry for each mistake
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// the actions needing a topic
+var needTopic = map[string]bool{Subscribe: true, Unsubscribe: true, Publish: true, Unpublish: true, Send: true,
+	Get: true, Request: true, Reply: true, Replay: true, Create: true, Delete: true, Cancel: true, Cron: true}
+
+// Validate checks the script without contacting the server
+func (sc *Script) Validate() error {
+	v := new(validator)
+	for i, topic := range sc.SubscribeTopics {
+		v.topic(fmt.Sprintf("subscribe_topics[%d]", i), topic, true)
+	}
+	for i, topic := range sc.UnsubscribeTopics {
+		v.topic(fmt.Sprintf("unsubscribe_topics[%d]", i), topic, true)
+	}
+	for i, topic := range sc.PublishTopics {
+		v.topic(fmt.Sprintf("publish_topics[%d]", i), topic, false)
+	}
+	for i, topic := range sc.UnpublishTopics {
+		v.topic(fmt.Sprintf("unpublish_topics[%d]", i), topic, false)
+	}
+	// sorted, the problems are reported always in the same order
+	var settingsTopics, filterTopics []string
+	for topic := range sc.TopicSettings {
+		settingsTopics = append(settingsTopics, topic)
+	}
+	for topic := range sc.TopicFilters {
+		filterTopics = append(filterTopics, topic)
+	}
+	sort.Strings(settingsTopics)
+	sort.Strings(filterTopics)
+	for _, topic := range settingsTopics {
+		if err := sc.TopicSettings[topic].WithDefaults().Validate(); err != nil {
+			v.add("topic_settings."+topic, strings.TrimSpace(err.Error()))
+		}
+	}
+	for _, topic := range filterTopics {
+		if _, err := messageFilter.Parse(string(sc.TopicFilters[topic])); err != nil {
+			v.add("topic_filters."+topic, err.Error())
+		}
+	}
+	v.templates("variables", sc.Variables)
+	v.steps("actions", sc.Actions)
+	v.steps("steps", sc.Steps)
+	if len(v.problems) > 0 {
+		return v.problems
+	}
+	return nil
+}
+
+type validator struct {
+	problems Problems
+}
+
+func (v *validator) add(path, format string, a ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) steps(path string, steps []Step) {
+	for i := range steps {
+		v.step(fmt.Sprintf("%s[%d]", path, i), &steps[i])
+	}
+}
+
+// topic checks a topic name, unless it is a template known only at run time
+func (v *validator) topic(path, topic string, allowPattern bool) {
+	if strings.Contains(topic, "{{") {
+		v.template(path, topic)
+		return
+	}
+	if err := topics.Validate(topic, allowPattern); err != nil {
+		v.add(path, err.Error())
+	}
+}
+
+func (v *validator) template(path, text string) {
+	if _, err := parseTemplate(text); err != nil {
+		v.add(path, err.Error())
+	}
+}
+
+// templates checks the values of m, each one a template
+func (v *validator) templates(path string, m map[string]string) {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v.template(path+"."+key, m[key])
+	}
+}
+
+func (v *validator) duration(path, text string) {
+	if d, err := time.ParseDuration(text); err != nil {
+		v.add(path, err.Error())
+	} else if d <= 0 {
+		v.add(path, "the duration must be positive")
+	}
+}
+
+func (v *validator) time(path, text string) {
+	if _, err := time.Parse(time.RFC3339, text); err != nil {
+		v.add(path, "not an RFC 3339 time: "+err.Error())
+	}
+}
+
+func (v *validator) step(path string, s *Step) {
+	switch s.Action {
+	case Subscribe, Unsubscribe, Publish, Unpublish, Send, Get, Request, Reply, Replay, List, Create, Delete,
+		Cancel, Cron, DeleteCron, ListCron, Loop, Wait, Set, Assert:
+	case "":
+		v.add(path+".action", "the action is missing")
+		return
+	default:
+		v.add(path+".action", "unknown action %q", s.Action)
+		return
+	}
+
+	if needTopic[s.Action] && s.Topic == "" {
+		v.add(path+".topic", "%s needs a topic", s.Action)
+	} else if s.Topic != "" {
+		allowPattern := s.Action != Send && s.Action != Request && s.Action != Publish && s.Action != Unpublish
+		if s.Action == List || s.Action == ListCron {
+			// a selection, not a topic
+			v.template(path+".topic", s.Topic)
+		} else {
+			v.topic(path+".topic", s.Topic, allowPattern)
+		}
+	}
+	for i, message := range s.Message {
+		v.template(fmt.Sprintf("%s.messages[%d]", path, i), message)
+	}
+	v.templates(path+".headers", s.Headers)
+	v.template(path+".correlation_id", s.CorrelationID)
+	if s.DeliverAt != "" {
+		v.time(path+".deliver_at", s.DeliverAt)
+		if s.Delay != "" {
+			v.add(path+".delay", "either deliver_at or delay")
+		}
+	} else if s.Delay != "" {
+		v.duration(path+".delay", s.Delay)
+	}
+	if s.Timeout != "" {
+		v.duration(path+".timeout", s.Timeout)
+	}
+	if s.Save != "" && s.Action != Get && s.Action != Reply && s.Action != Request {
+		v.add(path+".save", "only GET, REPLY and REQUEST can save a payload")
+	}
+	if s.Expect != nil && s.Action != Get && s.Action != Reply {
+		v.add(path+".expect", "only GET and REPLY can check the messages received")
+	}
+
+	switch s.Action {
+	case Send, Request:
+		if len(s.Message) == 0 {
+			v.add(path+".messages", "%s needs at least a message", s.Action)
+		}
+	case Get, Reply:
+		if s.Number <= 0 {
+			v.add(path+".number", "the number of messages to receive must be positive")
+		}
+		if s.Action == Reply && len(s.Message) == 0 {
+			v.add(path+".messages", "REPLY needs the message to answer with")
+		}
+		if s.Expect != nil {
+			v.expect(path+".expect", s.Expect)
+		}
+	case Replay:
+		if s.Number < 0 {
+			v.add(path+".number", "the number of messages must not be negative")
+		}
+		if s.Since != "" {
+			v.time(path+".since", s.Since)
+		}
+	case Cancel:
+		if len(s.ScheduleIDs) == 0 {
+			v.add(path+".schedule_ids", "CANCEL needs the ids of the scheduled messages")
+		}
+	case Cron:
+		if _, err := cronJobs.ParseSchedule(s.Schedule); err != nil {
+			v.add(path+".schedule", err.Error())
+		}
+		if _, err := cronJobs.ParseTemplate(s.Template); err != nil {
+			v.add(path+".template", err.Error())
+		}
+	case DeleteCron:
+		if len(s.JobIDs) == 0 {
+			v.add(path+".job_ids", "DELETE_CRON needs the ids of the jobs")
+		}
+	case Loop:
+		if s.Count <= 0 {
+			v.add(path+".count", "the number of iterations must be positive")
+		}
+		if len(s.Steps) == 0 {
+			v.add(path+".steps", "LOOP needs the steps to repeat")
+		}
+		v.steps(path+".steps", s.Steps)
+	case Wait:
+		v.duration(path+".duration", s.Duration)
+	case Set:
+		if len(s.Variables) == 0 {
+			v.add(path+".variables", "SET needs the variables to assign")
+		}
+		v.templates(path+".variables", s.Variables)
+	case Assert:
+		if s.Condition == "" {
+			v.add(path+".condition", "ASSERT needs a condition")
+		}
+		v.template(path+".condition", s.Condition)
+	}
+}
+
+func (v *validator) expect(path string, e *Expect) {
+	if e.Count != nil && *e.Count < 0 {
+		v.add(path+".count", "the number of messages must not be negative")
+	}
+	for i, payload := range e.Payloads {
+		v.template(fmt.Sprintf("%s.payloads[%d]", path, i), payload)
+	}
+	if e.Match != "" {
+		if _, err := regexp.Compile(e.Match); err != nil {
+			v.add(path+".match", err.Error())
+		}
+	}
+	v.templates(path+".headers", e.Headers)
+	v.template(path+".author", e.Author)
+}
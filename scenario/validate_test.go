@@ -0,0 +1,79 @@
+package scenario
+
+import (
+	"SDCC-A3-Project/utilities"
+	"reflect"
+	"testing"
+)
+
+// paths returns where Validate found the problems of sc
+func paths(t *testing.T, sc Script) []string {
+	err := sc.Validate()
+	if err == nil {
+		return nil
+	}
+	problems, ok := err.(Problems)
+	if !ok {
+		t.Fatalf("expected Problems, got %v", err)
+	}
+	var paths []string
+	for _, p := range problems {
+		paths = append(paths, p.Path)
+	}
+	return paths
+}
+
+func TestValidateValid(t *testing.T) {
+	sc := Script{
+		SubscribeTopics: []string{"sensors/#", "run/{{.Vars.run}}"},
+		PublishTopics:   []string{"news"},
+		Variables:       map[string]string{"run": "{{random 8}}"},
+		Steps: []Step{
+			{Action: Subscribe, Topic: "news"},
+			{Action: Send, Topic: "news", Message: []string{"hello {{.Seq}}"}, Delay: "1m"},
+			{Action: Get, Topic: "news", Number: 1, Save: "last", Expect: &Expect{Match: "^hello"}},
+			{Action: Loop, Count: 2, Steps: []Step{{Action: Wait, Duration: "1s"}}},
+			{Action: Set, Variables: map[string]string{"x": "{{.Vars.last}}"}},
+			{Action: Assert, Condition: `{{eq .Vars.x "hello 1"}}`},
+			{Action: Cron, Topic: "news", Schedule: "@hourly", Template: "tick {{.Time}}"},
+			{Action: ListCron},
+		},
+	}
+	if problems := paths(t, sc); problems != nil {
+		t.Errorf("expected no problem, got %v", problems)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	tests := []struct {
+		script Script
+		paths  []string
+	}{
+		{Script{SubscribeTopics: []string{"a//b"}, PublishTopics: []string{"sensors/*"}}, []string{"subscribe_topics[0]", "publish_topics[0]"}},
+		{Script{
+			TopicSettings: map[string]utilities.QueueSettings{"b": {Compression: "lz4"}},
+			TopicFilters:  map[string]Policy{"a": Policy(`{"x": []}`)},
+		}, []string{"topic_settings.b", "topic_filters.a"}},
+		{Script{Variables: map[string]string{"a": "{{.Vars.x", "b": "ok"}}, []string{"variables.a"}},
+		{Script{Steps: []Step{{Topic: "news"}}}, []string{"steps[0].action"}},
+		{Script{Actions: []Step{{Action: "JUMP"}}}, []string{"actions[0].action"}},
+		{Script{Steps: []Step{{Action: Subscribe}}}, []string{"steps[0].topic"}},
+		{Script{Steps: []Step{{Action: Send, Topic: "news/#", Message: []string{"x"}}}}, []string{"steps[0].topic"}},
+		{Script{Steps: []Step{{Action: Send, Topic: "news"}}}, []string{"steps[0].messages"}},
+		{Script{Steps: []Step{{Action: Send, Topic: "news", Message: []string{"x"}, DeliverAt: "2024-01-01T10:00:00Z", Delay: "1m"}}}, []string{"steps[0].delay"}},
+		{Script{Steps: []Step{{Action: Send, Topic: "news", Message: []string{"x"}, DeliverAt: "tomorrow", Timeout: "-1s"}}}, []string{"steps[0].deliver_at", "steps[0].timeout"}},
+		{Script{Steps: []Step{{Action: Get, Topic: "news"}}}, []string{"steps[0].number"}},
+		{Script{Steps: []Step{{Action: Send, Topic: "news", Message: []string{"x"}, Save: "v", Expect: &Expect{}}}}, []string{"steps[0].save", "steps[0].expect"}},
+		{Script{Steps: []Step{{Action: Cron, Topic: "news", Schedule: "* * *", Template: "{{"}}}, []string{"steps[0].schedule", "steps[0].template"}},
+		{Script{Steps: []Step{{Action: Cancel, Topic: "news"}, {Action: DeleteCron}}}, []string{"steps[0].schedule_ids", "steps[1].job_ids"}},
+		{Script{Steps: []Step{{Action: Loop}}}, []string{"steps[0].count", "steps[0].steps"}},
+		{Script{Steps: []Step{{Action: Loop, Count: 1, Steps: []Step{{Action: Wait, Duration: "soon"}}}}}, []string{"steps[0].steps[0].duration"}},
+		{Script{Steps: []Step{{Action: Set}, {Action: Assert}}}, []string{"steps[0].variables", "steps[1].condition"}},
+	}
+
+	for _, test := range tests {
+		if problems := paths(t, test.script); !reflect.DeepEqual(problems, test.paths) {
+			t.Errorf("expected problems at %v, got %v", test.paths, problems)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"SDCC-A3-Project/envelope"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("scheduled message not found")
+	ErrNotOwner = errors.New("the scheduled message belongs to another user")
+)
+
+// Entry is a message waiting to be sent to its topic
+type Entry struct {
+	ID              string            `json:"id"`
+	Owner           string            `json:"owner"` // user id of who scheduled the message, the only one who can cancel it
+	Topic           string            `json:"topic"`
+	DueTime         time.Time         `json:"due_time"` // when the message must be released into the topic queue
+	Message         envelope.Envelope `json:"message"`
+	GroupID         string            `json:"group_id,omitempty"` // FIFO topics only
+	DeduplicationID string            `json:"deduplication_id,omitempty"`
+}
+
+// Scheduler holds the scheduled messages, every change is written to a file
+// so that the messages survive a restart of the server
+type Scheduler struct {
+	path    string
+	entries map[string]Entry
+	mtx     sync.Mutex
+}
+
+// Open loads the messages scheduled in the file at path, which is created with the first message
+func Open(path string) (*Scheduler, error) {
+	sc := &Scheduler{path: path, entries: make(map[string]Entry)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err = json.Unmarshal(b, &entries); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	for _, e := range entries {
+		sc.entries[e.ID] = e
+	}
+	return sc, nil
+}
+
+// Add schedules e, it is not kept if it cannot be written
+func (sc *Scheduler) Add(e Entry) error {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	sc.entries[e.ID] = e
+	if err := sc.save(); err != nil {
+		delete(sc.entries, e.ID)
+		return err
+	}
+	return nil
+}
+
+// Cancel removes the message id scheduled by owner
+func (sc *Scheduler) Cancel(id, owner string) error {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	e, exists := sc.entries[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if e.Owner != owner {
+		return ErrNotOwner
+	}
+	delete(sc.entries, id)
+	if err := sc.save(); err != nil {
+		sc.entries[id] = e
+		return err
+	}
+	return nil
+}
+
+// Remove forgets about the message id, once it has been released
+func (sc *Scheduler) Remove(id string) error {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	if _, exists := sc.entries[id]; !exists {
+		return nil
+	}
+	delete(sc.entries, id)
+	return sc.save()
+}
+
+// Due returns the messages whose time has come, the oldest first
+func (sc *Scheduler) Due(now time.Time) []Entry {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	var due []Entry
+	for _, e := range sc.entries {
+		if !e.DueTime.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DueTime.Before(due[j].DueTime) })
+	return due
+}
+
+// save rewrites the file, the old content is replaced only once the new one is complete.
+// The caller must hold the lock.
+func (sc *Scheduler) save() error {
+	entries := make([]Entry, 0, len(sc.entries))
+	for _, e := range sc.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DueTime.Before(entries[j].DueTime) })
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(sc.path), filepath.Base(sc.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), sc.path)
+}
+
+// Run hands every interval the due messages to release until ctx is done.
+// A message is removed once released, it is tried again at the next round otherwise.
+func Run(ctx context.Context, sc *Scheduler, interval time.Duration, release func(Entry) error) {
+	// This function must be called in a thread/goroutine
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, e := range sc.Due(now) {
+				if err := release(e); err != nil {
+					log.Printf("[WARNING] - scheduled message %s not released, retrying: %v", e.ID, err)
+					continue
+				}
+				if err := sc.Remove(e.ID); err != nil {
+					log.Printf("[WARNING] - released message %s still in the schedule: %v", e.ID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"SDCC-A3-Project/envelope"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+func entry(id, owner string, due time.Duration) Entry {
+	return Entry{ID: id, Owner: owner, Topic: "news", DueTime: now.Add(due), Message: *envelope.New("news", owner, "EU", id)}
+}
+
+func ids(entries []Entry) string {
+	s := ""
+	for _, e := range entries {
+		s += e.ID
+	}
+	return s
+}
+
+// the scheduled messages survive a restart, the due ones come out oldest first
+func TestDueAfterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	sc, err := Open(path)
+	if err != nil {
+		t.Fatalf("expected a missing file to open empty, got %v", err)
+	}
+	for _, e := range []Entry{entry("b", "abc", -time.Minute), entry("a", "abc", -time.Hour), entry("d", "abc", 0), entry("c", "abc", time.Minute)} {
+		if err = sc.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sc, err = Open(path)
+	if err != nil {
+		t.Fatalf("expected the file to open again, got %v", err)
+	}
+	due := sc.Due(now)
+	if ids(due) != "abd" {
+		t.Fatalf("expected a, b and d due, got %q", ids(due))
+	}
+	if due[0].Message.Payload != "a" {
+		t.Errorf("expected the message to be kept, got %+v", due[0].Message)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	sc, err := Open(filepath.Join(t.TempDir(), "scheduled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Add(entry("a", "abc", 0))
+	sc.Add(entry("b", "def", 0))
+
+	tests := []struct {
+		id, owner string
+		err       error
+	}{
+		{"x", "abc", ErrNotFound},
+		{"b", "abc", ErrNotOwner},
+		{"a", "abc", nil},
+		{"a", "abc", ErrNotFound},
+	}
+	for _, test := range tests {
+		if err = sc.Cancel(test.id, test.owner); err != test.err {
+			t.Errorf("expected %v cancelling %s as %s, got %v", test.err, test.id, test.owner, err)
+		}
+	}
+	if due := ids(sc.Due(now)); due != "b" {
+		t.Errorf("expected only b left, got %q", due)
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	if err := os.WriteFile(path, []byte("[{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("expected an error opening a corrupted file")
+	}
+}
+
+// a message is removed once released and tried again while the release fails
+func TestRun(t *testing.T) {
+	sc, err := Open(filepath.Join(t.TempDir(), "scheduled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Add(entry("a", "abc", -time.Hour))
+	sc.Add(entry("b", "abc", -time.Hour))
+
+	var mtx sync.Mutex
+	released := make(map[string]int)
+	attempts := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, sc, time.Millisecond, func(e Entry) error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if e.ID == "b" && attempts < 2 {
+				attempts++
+				return errors.New("topic unreachable")
+			}
+			released[e.ID]++
+			return nil
+		})
+		close(done)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); len(sc.Due(time.Now())) > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if left := sc.Due(time.Now()); len(left) != 0 {
+		t.Errorf("expected every message released, got %q left", ids(left))
+	}
+	if released["a"] != 1 || released["b"] != 1 || attempts != 2 {
+		t.Errorf("expected a and b released once after 2 failures, got %v after %d", released, attempts)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"SDCC-A3-Project/blobStorage"
+	"SDCC-A3-Project/cronJobs"
+	"SDCC-A3-Project/membership"
+	"SDCC-A3-Project/messageFilter"
+	"SDCC-A3-Project/payloadCodec"
+	"SDCC-A3-Project/retainedLog"
 	"SDCC-A3-Project/rpcFunctions"
+	"SDCC-A3-Project/scheduler"
 	"SDCC-A3-Project/snsManagement"
-	"SDCC-A3-Project/sqsManagement"
 	"SDCC-A3-Project/utilities"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"log"
 	"net"
 	"net/rpc"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 )
 
@@ -19,27 +28,93 @@ func main() {
 	// Program Parameters
 	serverPort := flag.Int("serverPort", utilities.ServerPort, "a port number")
 	serverZone := flag.String("zone", utilities.Zone, "server zone")
+	maxReceiveCount := flag.Int("maxReceiveCount", utilities.MaxReceiveCount, "deliveries of a message before moving it to the dead-letter queue")
+	scheduleFile := flag.String("scheduleFile", "scheduled.json", "file keeping the messages scheduled on this server")
+	cronTable := flag.String("cronTable", "", "DynamoDB table of the recurring publications, shared by the servers, empty to disable them")
+	logDir := flag.String("logDir", "logs", "directory of the delivery logs and of the last values of the topics retaining them")
+	blobStore := flag.String("blobStore", "", "blob store of the clients, e.g. s3://bucket: the large payloads of the retained messages are deleted when they leave the log")
+	keyDir := flag.String("keyDir", "", "directory of the <key id>.key files with the master keys encrypting the recurring publications")
 	flag.Parse()
 
-	initServer(serverPort, serverZone)
+	initServer(serverPort, serverZone, maxReceiveCount, scheduleFile, cronTable, logDir, blobStore, keyDir)
 
 }
 
-func initServer(serverPort *int, serverZone *string) {
+func initServer(serverPort *int, serverZone *string, maxReceiveCount *int, scheduleFile *string, cronTable *string, logDir *string, blobStore *string, keyDir *string) {
 
 	// Queue Initialization
 	s := new(rpcFunctions.Service)
 	s.URLQueueMap = make(map[string]string)
 	s.UsersIdMap = make(map[string][]string)
 	s.QueueSubscribersMap = make(map[string]int)
+	s.DeadLetterURLMap = make(map[string]string)
+	s.SettingsMap = make(map[string]utilities.QueueSettings)
+	s.FiltersMap = make(map[string]map[string]*messageFilter.Filter)
+	s.PatternsMap = make(map[string]int)
+	s.QueueNamesMap = make(map[string]string)
+	s.CatalogMap = make(map[string]utilities.TopicInfo)
+	s.EmptySinceMap = make(map[string]time.Time)
+	s.PublishersMap = make(map[string][]string)
+	s.MaxReceiveCount = *maxReceiveCount
 	s.Zone = *serverZone
-	snsManagement.SnsToSqsConfig(&s.QueueURL, &s.TopicARN, s.Zone)
+	sch, err := scheduler.Open(*scheduleFile)
+	if err != nil {
+		log.Fatal("[CRITICAL] - cannot load the scheduled messages: ", err)
+	}
+	s.Scheduler = sch
+	s.DeliveryLogs = retainedLog.NewLogs(*logDir)
+	defer s.DeliveryLogs.Close()
+	s.LastValues, err = retainedLog.OpenLastValues(filepath.Join(*logDir, "last-values.json"))
+	if err != nil {
+		log.Fatal("[CRITICAL] - cannot load the retained messages: ", err)
+	}
+	if *blobStore != "" {
+		if s.BlobStore, err = blobStorage.Open(*blobStore); err != nil {
+			log.Fatal("[CRITICAL] - cannot open the blob store: ", err)
+		}
+	}
+	if *keyDir != "" {
+		s.Keys = payloadCodec.NewFileKeyProvider(*keyDir)
+	}
+	if *cronTable != "" {
+		if s.CronStore, err = cronJobs.OpenStore(*cronTable); err != nil {
+			log.Fatal("[CRITICAL] - cannot open the recurring publications: ", err)
+		}
+	}
 
-	go func() { LookForMessages(s) }()
+	address, err := utilities.ExternalIP()
+	if err != nil {
+		log.Println("[WARNING] - cannot find the external ip address, announcing localhost: ", err)
+		address = "localhost"
+	}
+	self := utilities.ServerInfo{
+		Zone:      s.Zone,
+		Address:   address,
+		Port:      *serverPort,
+		StartTime: time.Now(),
+	}
+	snsManagement.SnsToSqsConfig(&s.QueueURL, &s.TopicARN, &s.SubscriptionARN, self)
+	s.Peers = membership.NewView(self)
+
+	// stop serving on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	replicationDone := make(chan struct{})
+	go func() {
+		rpcFunctions.LookForMessages(ctx, s)
+		close(replicationDone)
+	}()
+	go func() { membership.Run(ctx, s.Peers, &s.TopicARN) }()
+	go func() { rpcFunctions.ReapTopics(ctx, s) }()
+	go func() { rpcFunctions.ReleaseScheduled(ctx, s) }()
+	if s.CronStore != nil {
+		go func() { rpcFunctions.RunCronJobs(ctx, s) }()
+	}
 
 	// Register a new rpc server and the struct we created above.
 	server := rpc.NewServer()
-	err := server.RegisterName("MessageService", s)
+	err = server.RegisterName("MessageService", s)
 	if err != nil {
 		log.Fatal("[CRITICAL] - Format of service Queue is not correct: ", err)
 	}
@@ -51,79 +126,19 @@ func initServer(serverPort *int, serverZone *string) {
 		log.Fatal("[CRITICAL] - Listen error:", e)
 	}
 
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
 	log.Printf("[INFO] - server up and running. Listening port number: %d", *serverPort)
 	// Link rpc server to the socket, and allow rpc server to accept
 	// rpc requests coming from that socket.
 	server.Accept(l)
-}
 
-type UpdateInfo struct {
-	ID     string   `json:"ID"`
-	Topics []string `json:"Topics"`
-}
-
-func LookForMessages(s *rpcFunctions.Service) {
-	// This function must be called in a thread/goroutine
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	var to int64
-	to = 20 //visibility timeout
-	for {
-		msgResult, err := sqsManagement.GetMessages(sess, &s.QueueURL, &to)
-		if err != nil {
-			fmt.Println("Got an error receiving messages:")
-			fmt.Println(err)
-			return
-		}
-		if len(msgResult.Messages) == 0 {
-			fmt.Println("no messages available")
-			fmt.Printf("%v+\n", time.Now())
-			time.Sleep(time.Minute)
-			continue
-		}
-
-		fmt.Println((*msgResult.Messages[0]).String())
-		var jsonResult map[string]string
-		json.Unmarshal([]byte(*msgResult.Messages[0].Body), &jsonResult)
-
-		data := jsonResult["Message"]
-		var updates []UpdateInfo
-		json.Unmarshal([]byte(data), &updates)
-		fmt.Println(updates)
-
-		//otherwise the message return visible after the visibility timeout
-		err = sqsManagement.DeleteMessage(sess, &s.QueueURL, msgResult.Messages[0].ReceiptHandle)
-		if err != nil {
-			fmt.Println("Got an error deleting the message:")
-			fmt.Println(err)
-		}
-
-		s.RwMtx.Lock()
-		for i := 0; i < len(updates); i++ {
-			// per ogni id dall'esterno vedo la lista corrispondente se esiste
-			if myList, ok := s.UsersIdMap[updates[i].ID]; ok {
-				//per ogni topic nella lista updates
-				for j := 0; j < len(updates[i].Topics); j++ {
-					//vedo se ne sono gi a conoscenza
-					if !Contains(myList, updates[i].Topics[j]) {
-						myList = append(myList, updates[i].Topics[j])
-					}
-				}
-			} else {
-				s.UsersIdMap[updates[i].ID] = updates[i].Topics
-			}
-		}
-		s.RwMtx.Unlock()
-	}
-}
-
-// Contains tells whether a contains x.
-func Contains(a []string, x string) bool {
-	for _, n := range a {
-		if x == n {
-			return true
-		}
-	}
-	return false
+	// the listener has been closed, wait for the messages under processing
+	<-replicationDone
+	// nobody else reads the queue of this server
+	snsManagement.LeaveMasterTopic(&s.QueueURL, &s.SubscriptionARN)
+	log.Println("[INFO] - server stopped")
 }
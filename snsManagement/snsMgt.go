@@ -2,9 +2,12 @@ package snsManagement
 
 import (
 	"SDCC-A3-Project/sqsManagement"
+	"SDCC-A3-Project/topics"
 	"SDCC-A3-Project/utilities"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -12,6 +15,14 @@ import (
 	"strings"
 )
 
+// subjects used on the master topic to tell the kind of notification
+const (
+	UserListSubject  = "USERS"
+	HeartbeatSubject = "HEARTBEAT"
+	RetainedSubject  = "RETAINED"
+	FiltersSubject   = "FILTERS"
+)
+
 // ShowTopics retrieves information about the Amazon SNS topics
 func ShowTopics(svc snsiface.SNSAPI) (*sns.ListTopicsOutput, error) {
 	results, err := svc.ListTopics(nil)
@@ -21,9 +32,7 @@ func ShowTopics(svc snsiface.SNSAPI) (*sns.ListTopicsOutput, error) {
 func findMAsterTopic() (topicARN *string) {
 	var resTopicARN *string
 	resTopicARN = nil
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	sess := utilities.NewSession()
 
 	svc := sns.New(sess)
 
@@ -55,16 +64,31 @@ func findMAsterTopic() (topicARN *string) {
 	return resTopicARN
 }
 
-func SnsToSqsConfig(outQueueURL, outTopicARN *string, zone string) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+// maxQueueName is the longest name sqs accepts for a queue
+const maxQueueName = 80
+
+// MasterQueueName returns the name of the queue on which the server receives the notifications of
+// the master topic. Every server has its own: SNS delivers each notification to every queue
+// subscribed, while the servers sharing a queue would steal the notifications of each other.
+func MasterQueueName(info utilities.ServerInfo) string {
+	name := fmt.Sprintf("MASTER_%s_%s_%d", topics.Sanitize(info.Zone), topics.Sanitize(info.Address), info.Port)
+	if len(name) <= maxQueueName {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:16]
+	return name[:maxQueueName-len(hash)-1] + "-" + hash
+}
+
+// SnsToSqsConfig creates the queue of the server and subscribes it to the master topic
+func SnsToSqsConfig(outQueueURL, outTopicARN, outSubscriptionARN *string, info utilities.ServerInfo) {
+	sess := utilities.NewSession()
 	// Create new services for SQS and SNS
 	sqsSvc := sqs.New(sess)
 	snsSvc := sns.New(sess)
 
-	topicName := "MASTER_" + zone
-	queueRes, err := sqsManagement.CreateQueue(sess, &topicName) // listening sns queue
+	queueName := MasterQueueName(info)
+	queueRes, _, err := sqsManagement.CreateQueue(sess, &queueName, nil, nil) // listening sns queue
 	if err != nil {
 		log.Fatal("Got an error creating the queue:", err)
 	}
@@ -75,6 +99,23 @@ func SnsToSqsConfig(outQueueURL, outTopicARN *string, zone string) {
 	// No way to retrieve the queue ARN through the SDK, manual string replace to generate the ARN
 	queueARN := convertQueueURLToARN(*queueRes.QueueUrl)
 
+	// the topic must be allowed to send to the queue before the first notification
+	policyContent := "{\"Version\": \"2012-10-17\",  \"Id\": \"" + queueARN + "/SQSDefaultPolicy\",  \"Statement\": [    {     \"Sid\": \"Sid1580665629194\",      \"Effect\": \"Allow\",      \"Principal\": {        \"AWS\": \"*\"      },      \"Action\": \"SQS:SendMessage\",      \"Resource\": \"" + queueARN + "\",      \"Condition\": {        \"ArnEquals\": {         \"aws:SourceArn\": \"" + topicArn + "\"        }      }    }  ]}"
+
+	attr := make(map[string]*string, 1)
+	attr["Policy"] = &policyContent
+
+	setQueueAttrInput := sqs.SetQueueAttributesInput{
+		QueueUrl:   queueRes.QueueUrl,
+		Attributes: attr,
+	}
+
+	_, err = sqsSvc.SetQueueAttributes(&setQueueAttrInput)
+
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
 	subscribeQueueInput := sns.SubscribeInput{
 		TopicArn: &topicArn,
 		Protocol: &protocolName,
@@ -89,25 +130,27 @@ func SnsToSqsConfig(outQueueURL, outTopicARN *string, zone string) {
 
 	if createSubRes != nil {
 		fmt.Println("connected with other servers using this link: " + *createSubRes.SubscriptionArn)
+		*outSubscriptionARN = *createSubRes.SubscriptionArn
 	}
 
-	policyContent := "{\"Version\": \"2012-10-17\",  \"Id\": \"" + queueARN + "/SQSDefaultPolicy\",  \"Statement\": [    {     \"Sid\": \"Sid1580665629194\",      \"Effect\": \"Allow\",      \"Principal\": {        \"AWS\": \"*\"      },      \"Action\": \"SQS:SendMessage\",      \"Resource\": \"" + queueARN + "\",      \"Condition\": {        \"ArnEquals\": {         \"aws:SourceArn\": \"" + topicArn + "\"        }      }    }  ]}"
+	*outTopicARN = topicArn
+}
 
-	attr := make(map[string]*string, 1)
-	attr["Policy"] = &policyContent
+// LeaveMasterTopic unsubscribes the queue of a server stopping from the master topic and deletes it
+func LeaveMasterTopic(queueURL, subscriptionARN *string) {
+	sess := utilities.NewSession()
 
-	setQueueAttrInput := sqs.SetQueueAttributesInput{
-		QueueUrl:   queueRes.QueueUrl,
-		Attributes: attr,
+	if *subscriptionARN != "" {
+		_, err := sns.New(sess).Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: subscriptionARN})
+		if err != nil {
+			fmt.Println("Got an error unsubscribing from the master topic:")
+			fmt.Println(err)
+		}
 	}
-
-	_, err = sqsSvc.SetQueueAttributes(&setQueueAttrInput)
-
-	if err != nil {
-		fmt.Println(err.Error())
+	if err := sqsManagement.DeleteQueue(sess, queueURL); err != nil {
+		fmt.Println("Got an error deleting the queue:")
+		fmt.Println(err)
 	}
-
-	*outTopicARN = topicArn
 }
 
 func convertQueueURLToARN(inputURL string) string {
@@ -141,14 +184,23 @@ func PublishMessage(svc snsiface.SNSAPI, msg, topicARN *string) (*sns.PublishOut
 	return result, err
 }
 
+// PublishSubjectMessage is the same as PublishMessage, the subject tells the receiver how to decode msg
+func PublishSubjectMessage(svc snsiface.SNSAPI, msg, subject, topicARN *string) (*sns.PublishOutput, error) {
+	result, err := svc.Publish(&sns.PublishInput{
+		Message:  msg,
+		Subject:  subject,
+		TopicArn: topicARN,
+	})
+	return result, err
+}
+
 func PublishUserListUpdate(userIdMap map[string][]string, topicARN *string) {
 	msg := utilities.MapToJson(userIdMap)
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	sess := utilities.NewSession()
 
 	svc := sns.New(sess)
-	result, err := PublishMessage(svc, msg, topicARN)
+	subject := UserListSubject
+	result, err := PublishSubjectMessage(svc, msg, &subject, topicARN)
 	if err != nil {
 		fmt.Println("Got an error publishing the message:")
 		fmt.Println(err)
@@ -158,3 +210,62 @@ func PublishUserListUpdate(userIdMap map[string][]string, topicARN *string) {
 	fmt.Println("Message ID: " + *result.MessageId)
 
 }
+
+// PublishHeartbeat announces a server to all the others listening on the master topic
+func PublishHeartbeat(info utilities.ServerInfo, topicARN *string) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	msg := string(b)
+	sess := utilities.NewSession()
+
+	svc := sns.New(sess)
+	subject := HeartbeatSubject
+	_, err = PublishSubjectMessage(svc, &msg, &subject, topicARN)
+	if err != nil {
+		fmt.Println("Got an error publishing the heartbeat:")
+		fmt.Println(err)
+	}
+}
+
+// PublishFilters sends the filters of the subscriptions to the servers, so that they can tell
+// whether a message is wanted by somebody
+func PublishFilters(filters []utilities.SubscriptionFilter, topicARN *string) {
+	b, err := json.Marshal(filters)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	msg := string(b)
+	sess := utilities.NewSession()
+
+	svc := sns.New(sess)
+	subject := FiltersSubject
+	_, err = PublishSubjectMessage(svc, &msg, &subject, topicARN)
+	if err != nil {
+		fmt.Println("Got an error publishing the filters:")
+		fmt.Println(err)
+	}
+}
+
+// PublishRetained sends the last value of a topic to the servers, so that they can deliver it
+// to their new subscribers
+func PublishRetained(m utilities.RetainedMessage, topicARN *string) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	msg := string(b)
+	sess := utilities.NewSession()
+
+	svc := sns.New(sess)
+	subject := RetainedSubject
+	_, err = PublishSubjectMessage(svc, &msg, &subject, topicARN)
+	if err != nil {
+		fmt.Println("Got an error publishing the retained message:")
+		fmt.Println(err)
+	}
+}
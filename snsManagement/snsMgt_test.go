@@ -0,0 +1,37 @@
+package snsManagement
+
+import (
+	"SDCC-A3-Project/utilities"
+	"strings"
+	"testing"
+)
+
+var masterQueueTests = []struct {
+	info utilities.ServerInfo
+	name string
+}{
+	{utilities.ServerInfo{Zone: "eu", Address: "10.0.0.1", Port: 1234}, "MASTER_eu_10_0_0_1_1234"},
+	{utilities.ServerInfo{Zone: "eu", Address: "10.0.0.1", Port: 1235}, "MASTER_eu_10_0_0_1_1235"},
+	{utilities.ServerInfo{Zone: "us", Address: "localhost", Port: 1234}, "MASTER_us_localhost_1234"},
+	{utilities.ServerInfo{Zone: "eu", Address: "fe80::1", Port: 1234}, "MASTER_eu_fe80__1_1234"},
+}
+
+func TestMasterQueueName(t *testing.T) {
+	for _, test := range masterQueueTests {
+		if name := MasterQueueName(test.info); name != test.name {
+			t.Errorf("expected %q, got %q", test.name, name)
+		}
+	}
+}
+
+func TestMasterQueueNameLong(t *testing.T) {
+	host := strings.Repeat("server.", 12) + "example.com"
+	first := MasterQueueName(utilities.ServerInfo{Zone: "eu", Address: host + "1", Port: 1234})
+	second := MasterQueueName(utilities.ServerInfo{Zone: "eu", Address: host + "2", Port: 1234})
+	if len(first) > maxQueueName || len(second) > maxQueueName {
+		t.Errorf("expected at most %d characters, got %q and %q", maxQueueName, first, second)
+	}
+	if first == second {
+		t.Errorf("expected different queues, got %q twice", first)
+	}
+}
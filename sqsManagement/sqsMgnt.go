@@ -1,34 +1,251 @@
 package sqsManagement
 
 import (
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"encoding/json"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"strconv"
 )
 
-// CreateQueue creates an Amazon SQS queue
+// MaxBatchSize is the maximum number of messages sqs handles with a single batch call
+const MaxBatchSize = 10
+
+// BatchFailure reports an entry of a batch call that has not been executed
+type BatchFailure struct {
+	Index       int    // position of the entry in the slice passed to the batch function
+	Code        string // error code returned by sqs
+	Message     string // error description
+	SenderFault bool   // if true retrying the same entry fails again
+}
+
+// CreateQueue creates an Amazon SQS queue, a queue with the same name is left as it is
 // Inputs:
-//     sess is the
+//     sess is the current session, which provides configuration for the SDK's service clients
 //     queueName is the name of the queue
+//     settings is the configuration of the queue, nil for the default one
+//     tags are set on the queue when it is created, an existing queue keeps its own
 // Output:
-//     If success, the URL of the queue and nil
-//     Otherwise, an empty string and an error from the call to CreateQueue
-func CreateQueue(sess *session.Session, queue *string) (*sqs.CreateQueueOutput, error) {
+//     If success, the URL of the queue, the attributes of the queue if it existed with another
+//     configuration (nil otherwise) and nil
+//     Otherwise, nil and an error from the call to CreateQueue
+func CreateQueue(sess *session.Session, queue *string, settings *utilities.QueueSettings, tags map[string]string) (*sqs.CreateQueueOutput, map[string]string, error) {
 	// Create an SQS service client
 	svc := sqs.New(sess)
 
+	qs := utilities.DefaultQueueSettings()
+	if settings != nil {
+		qs = settings.WithDefaults()
+	}
 	result, err := svc.CreateQueue(&sqs.CreateQueueInput{
-		QueueName: queue,
+		QueueName:  queue,
+		Attributes: queueAttributes(qs),
+		Tags:       aws.StringMap(tags),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sqs.ErrCodeQueueNameExists {
+		// same name but another configuration, the queue keeps the one of who created it
+		urlResult, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: queue})
+		if err != nil {
+			return nil, nil, err
+		}
+		attributes, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+			QueueUrl:       urlResult.QueueUrl,
+			AttributeNames: []*string{aws.String(sqs.QueueAttributeNameAll)},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return &sqs.CreateQueueOutput{QueueUrl: urlResult.QueueUrl}, aws.StringValueMap(attributes.Attributes), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, nil, nil
+}
+
+func queueAttributes(qs utilities.QueueSettings) map[string]*string {
+	attributes := map[string]*string{
+		sqs.QueueAttributeNameDelaySeconds:                  aws.String(strconv.FormatInt(qs.DelaySeconds, 10)),
+		sqs.QueueAttributeNameMessageRetentionPeriod:        aws.String(strconv.FormatInt(qs.MessageRetentionPeriod, 10)),
+		sqs.QueueAttributeNameMaximumMessageSize:            aws.String(strconv.FormatInt(qs.MaximumMessageSize, 10)),
+		sqs.QueueAttributeNameVisibilityTimeout:             aws.String(strconv.FormatInt(*qs.VisibilityTimeout, 10)),
+		sqs.QueueAttributeNameReceiveMessageWaitTimeSeconds: aws.String(strconv.FormatInt(*qs.ReceiveWaitTime, 10)),
+	}
+	if qs.Fifo {
+		attributes[sqs.QueueAttributeNameFifoQueue] = aws.String("true")
+		attributes[sqs.QueueAttributeNameContentBasedDeduplication] = aws.String(strconv.FormatBool(qs.ContentBasedDeduplication))
+	}
+	return attributes
+}
+
+// WithAttributes returns qs with the fields kept by sqs replaced by the attributes of a queue,
+// the others are left as they are
+func WithAttributes(qs utilities.QueueSettings, attributes map[string]string) utilities.QueueSettings {
+	number := func(name string) (int64, bool) {
+		n, err := strconv.ParseInt(attributes[name], 10, 64)
+		return n, err == nil
+	}
+	if n, ok := number(sqs.QueueAttributeNameDelaySeconds); ok {
+		qs.DelaySeconds = n
+	}
+	if n, ok := number(sqs.QueueAttributeNameMessageRetentionPeriod); ok {
+		qs.MessageRetentionPeriod = n
+	}
+	if n, ok := number(sqs.QueueAttributeNameMaximumMessageSize); ok {
+		qs.MaximumMessageSize = n
+	}
+	if n, ok := number(sqs.QueueAttributeNameVisibilityTimeout); ok {
+		qs.VisibilityTimeout = utilities.Int64(n)
+	}
+	if n, ok := number(sqs.QueueAttributeNameReceiveMessageWaitTimeSeconds); ok {
+		qs.ReceiveWaitTime = utilities.Int64(n)
+	}
+	qs.Fifo = attributes[sqs.QueueAttributeNameFifoQueue] == "true"
+	qs.ContentBasedDeduplication = attributes[sqs.QueueAttributeNameContentBasedDeduplication] == "true"
+	return qs
+}
+
+// CreateDeadLetterQueue creates the queue receiving the messages that could not be processed,
+// they are kept for the maximum retention period (14 days).
+// The dead-letter queue of a FIFO queue must be FIFO too.
+func CreateDeadLetterQueue(sess *session.Session, queue *string, fifo bool) (*sqs.CreateQueueOutput, error) {
+	svc := sqs.New(sess)
+
+	attributes := map[string]*string{
+		"MessageRetentionPeriod": aws.String("1209600"),
+	}
+	if fifo {
+		attributes[sqs.QueueAttributeNameFifoQueue] = aws.String("true")
+	}
+	result, err := svc.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  queue,
+		Attributes: attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetQueueARN returns the Amazon Resource Name of the queue
+func GetQueueARN(sess *session.Session, queueURL *string) (string, error) {
+	svc := sqs.New(sess)
+
+	result, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       queueURL,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *result.Attributes[sqs.QueueAttributeNameQueueArn], nil
+}
+
+// QueueAttributes returns all the attributes of the queue
+func QueueAttributes(sess *session.Session, queueURL *string) (map[string]string, error) {
+	svc := sqs.New(sess)
+
+	result, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       queueURL,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameAll)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return aws.StringValueMap(result.Attributes), nil
+}
+
+// SetRedrivePolicy moves to the dead-letter queue deadLetterARN the messages of queueURL
+// that have been received maxReceiveCount times without being deleted
+func SetRedrivePolicy(sess *session.Session, queueURL *string, deadLetterARN string, maxReceiveCount int) error {
+	svc := sqs.New(sess)
+
+	policy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": deadLetterARN,
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		QueueUrl: queueURL,
 		Attributes: map[string]*string{
-			"DelaySeconds":           aws.String("60"),
-			"MessageRetentionPeriod": aws.String("86400"),
+			sqs.QueueAttributeNameRedrivePolicy: aws.String(string(policy)),
 		},
 	})
+	return err
+}
+
+// TagQueue adds the tags to the queue
+func TagQueue(sess *session.Session, queueURL *string, tags map[string]string) error {
+	svc := sqs.New(sess)
+
+	_, err := svc.TagQueue(&sqs.TagQueueInput{
+		QueueUrl: queueURL,
+		Tags:     aws.StringMap(tags),
+	})
+	return err
+}
+
+// QueueTags returns the tags of the queue
+func QueueTags(sess *session.Session, queueURL *string) (map[string]string, error) {
+	svc := sqs.New(sess)
+
+	result, err := svc.ListQueueTags(&sqs.ListQueueTagsInput{
+		QueueUrl: queueURL,
+	})
 	if err != nil {
 		return nil, err
 	}
+	return aws.StringValueMap(result.Tags), nil
+}
 
-	return result, nil
+// CountMessages returns the approximate number of messages available in the queue
+func CountMessages(sess *session.Session, queueURL *string) (int64, error) {
+	svc := sqs.New(sess)
+
+	result, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       queueURL,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(*result.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages], 10, 64)
+}
+
+// ListQueues returns the URL of the queues whose name starts with prefix
+func ListQueues(sess *session.Session, prefix string) ([]string, error) {
+	svc := sqs.New(sess)
+
+	var urls []string
+	err := svc.ListQueuesPages(&sqs.ListQueuesInput{
+		QueueNamePrefix: aws.String(prefix),
+	}, func(page *sqs.ListQueuesOutput, lastPage bool) bool {
+		urls = append(urls, aws.StringValueSlice(page.QueueUrls)...)
+		return true
+	})
+	return urls, err
+}
+
+// PurgeQueue deletes all the messages in the queue
+func PurgeQueue(sess *session.Session, queueURL *string) error {
+	svc := sqs.New(sess)
+
+	_, err := svc.PurgeQueue(&sqs.PurgeQueueInput{
+		QueueUrl: queueURL,
+	})
+	return err
 }
 
 func DeleteQueue(sess *session.Session, queueURL *string) error {
@@ -49,15 +266,17 @@ func DeleteQueue(sess *session.Session, queueURL *string) error {
 // Inputs:
 //     sess is the current session, which provides configuration for the SDK's service clients
 //     queueURL is the URL of the queue
+//     groupID orders the messages of a FIFO queue, empty for standard queues
+//     deduplicationID identifies the message in a FIFO queue, empty for standard queues
+//         or when the queue uses content based deduplication
 // Output:
 //     If success, nil
 //     Otherwise, an error from the call to SendMessage
-func SendMsg(sess *session.Session, queueURL *string, message *string, author *string) error {
+func SendMsg(sess *session.Session, queueURL *string, message *string, author *string, groupID, deduplicationID string) error {
 	// Create an SQS service client
 	svc := sqs.New(sess)
 
-	_, err := svc.SendMessage(&sqs.SendMessageInput{
-		DelaySeconds: aws.Int64(10),
+	input := &sqs.SendMessageInput{
 		MessageAttributes: map[string]*sqs.MessageAttributeValue{
 			"Author": &sqs.MessageAttributeValue{
 				DataType:    aws.String("String"),
@@ -66,7 +285,14 @@ func SendMsg(sess *session.Session, queueURL *string, message *string, author *s
 		},
 		MessageBody: aws.String(*message),
 		QueueUrl:    queueURL,
-	})
+	}
+	if groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+	}
+	if deduplicationID != "" {
+		input.MessageDeduplicationId = aws.String(deduplicationID)
+	}
+	_, err := svc.SendMessage(input)
 	if err != nil {
 		return err
 	}
@@ -74,27 +300,90 @@ func SendMsg(sess *session.Session, queueURL *string, message *string, author *s
 	return nil
 }
 
+// ForwardMessage sends to queueURL a copy of msg, received from another queue,
+// with the same body, message attributes and, for FIFO queues, group and deduplication id
+func ForwardMessage(sess *session.Session, queueURL *string, msg *sqs.Message) error {
+	svc := sqs.New(sess)
+
+	_, err := svc.SendMessage(&sqs.SendMessageInput{
+		MessageAttributes:      msg.MessageAttributes,
+		MessageBody:            msg.Body,
+		MessageGroupId:         msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId],
+		MessageDeduplicationId: msg.Attributes[sqs.MessageSystemAttributeNameMessageDeduplicationId],
+		QueueUrl:               queueURL,
+	})
+	return err
+}
+
+// RequeuedAttribute counts how many times a message has been put back in its queue by RequeueMessage
+const RequeuedAttribute = "Requeued"
+
+// RequeueMessage sends to the back of queueURL a copy of msg, received from the same queue, that
+// the receiver doesn't want: unlike a visibility change, the copy starts with no receive counted
+// by the redrive policy. The caller deletes msg once the copy has been sent.
+// Inputs:
+//     sess is the current session, which provides configuration for the SDK's service clients
+//     queueURL is the URL of the queue
+//     msg is the message, received with its attributes and, for FIFO queues, its group id
+//     delay is how long, in seconds, the copy stays invisible; ignored by FIFO queues
+// Output:
+//     If success, nil
+//     Otherwise, an error from the call to SendMessage
+func RequeueMessage(sess *session.Session, queueURL *string, msg *sqs.Message, delay int64) error {
+	svc := sqs.New(sess)
+
+	attributes := make(map[string]*sqs.MessageAttributeValue, len(msg.MessageAttributes)+1)
+	for name, value := range msg.MessageAttributes {
+		attributes[name] = value
+	}
+	requeued := int64(0)
+	if value, exists := msg.MessageAttributes[RequeuedAttribute]; exists {
+		requeued, _ = strconv.ParseInt(aws.StringValue(value.StringValue), 10, 64)
+	}
+	attributes[RequeuedAttribute] = &sqs.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.FormatInt(requeued+1, 10)),
+	}
+	input := &sqs.SendMessageInput{
+		MessageAttributes: attributes,
+		MessageBody:       msg.Body,
+		QueueUrl:          queueURL,
+	}
+	if groupID, fifo := msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]; fifo {
+		// the copy goes to the back of its group; the original id would make it a duplicate
+		input.MessageGroupId = groupID
+		input.MessageDeduplicationId = msg.MessageId
+	} else {
+		input.DelaySeconds = aws.Int64(delay)
+	}
+	_, err := svc.SendMessage(input)
+	return err
+}
+
 //GetMessages gets the messages from an Amazon SQS queue
 // Inputs:
 //     sess is the current session, which provides configuration for the SDK's service clients
 //     queueURL is the URL of the queue
+//     maxMessages is the maximum number of messages returned, at most 10
 //     timeout is how long, in seconds, the message is unavailable to other consumers
 // Output:
-//     If success, the latest message and nil
+//     If success, the latest messages and nil
 //     Otherwise, nil and an error from the call to ReceiveMessage
-func GetMessages(sess *session.Session, queueURL *string, timeout *int64) (*sqs.ReceiveMessageOutput, error) {
+func GetMessages(sess *session.Session, queueURL *string, maxMessages, timeout *int64) (*sqs.ReceiveMessageOutput, error) {
 	// Create an SQS service client
 	svc := sqs.New(sess)
 
 	msgResult, err := svc.ReceiveMessage(&sqs.ReceiveMessageInput{
 		AttributeNames: []*string{
 			aws.String(sqs.MessageSystemAttributeNameSentTimestamp),
+			aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
+			aws.String(sqs.MessageSystemAttributeNameMessageGroupId),
 		},
 		MessageAttributeNames: []*string{
 			aws.String(sqs.QueueAttributeNameAll),
 		},
 		QueueUrl:            queueURL,
-		MaxNumberOfMessages: aws.Int64(1),
+		MaxNumberOfMessages: maxMessages,
 		VisibilityTimeout:   timeout,
 	})
 
@@ -105,6 +394,44 @@ func GetMessages(sess *session.Session, queueURL *string, timeout *int64) (*sqs.
 	return msgResult, nil
 }
 
+// ReceiveMessages waits up to waitTime seconds for messages from an Amazon SQS queue (long polling)
+// Inputs:
+//     ctx cancels the wait
+//     sess is the current session, which provides configuration for the SDK's service clients
+//     queueURL is the URL of the queue
+//     maxMessages is the maximum number of messages returned, at most 10
+//     timeout is how long, in seconds, the messages are unavailable to other consumers
+//     waitTime is how long, in seconds, the call waits for a message to arrive, at most 20
+// Output:
+//     If success, the messages received (possibly none) and nil
+//     Otherwise, nil and an error from the call to ReceiveMessage
+func ReceiveMessages(ctx context.Context, sess *session.Session, queueURL *string, maxMessages, timeout, waitTime *int64) (*sqs.ReceiveMessageOutput, error) {
+	// Create an SQS service client
+	svc := sqs.New(sess)
+
+	msgResult, err := svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		AttributeNames: []*string{
+			aws.String(sqs.MessageSystemAttributeNameSentTimestamp),
+			aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
+			aws.String(sqs.MessageSystemAttributeNameMessageGroupId),
+			aws.String(sqs.MessageSystemAttributeNameMessageDeduplicationId),
+		},
+		MessageAttributeNames: []*string{
+			aws.String(sqs.QueueAttributeNameAll),
+		},
+		QueueUrl:            queueURL,
+		MaxNumberOfMessages: maxMessages,
+		VisibilityTimeout:   timeout,
+		WaitTimeSeconds:     waitTime,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msgResult, nil
+}
+
 // DeleteMessage deletes a message from an Amazon SQS queue
 // Inputs:
 //     sess is the current session, which provides configuration for the SDK's service clients
@@ -114,9 +441,14 @@ func GetMessages(sess *session.Session, queueURL *string, timeout *int64) (*sqs.
 //     If success, nil
 //     Otherwise, an error from the call to DeleteMessage
 func DeleteMessage(sess *session.Session, queueURL *string, messageHandle *string) error {
+	return DeleteMessageWithContext(context.Background(), sess, queueURL, messageHandle)
+}
+
+// DeleteMessageWithContext is DeleteMessage, ctx cancels the call
+func DeleteMessageWithContext(ctx context.Context, sess *session.Session, queueURL *string, messageHandle *string) error {
 	svc := sqs.New(sess)
 
-	_, err := svc.DeleteMessage(&sqs.DeleteMessageInput{
+	_, err := svc.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
 		QueueUrl:      queueURL,
 		ReceiptHandle: messageHandle,
 	})
@@ -127,9 +459,7 @@ func DeleteMessage(sess *session.Session, queueURL *string, messageHandle *strin
 }
 
 func GetQueueURL(queue *string) (*sqs.GetQueueUrlOutput, error) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	sess := utilities.NewSession()
 	// Create an SQS service client
 	svc := sqs.New(sess)
 
@@ -141,3 +471,125 @@ func GetQueueURL(queue *string) (*sqs.GetQueueUrlOutput, error) {
 	}
 	return result, nil
 }
+
+// IsQueueMissing tells whether err reports that the queue does not exist
+func IsQueueMissing(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == sqs.ErrCodeQueueDoesNotExist
+}
+
+// SendMsgBatch sends the messages to an Amazon SQS queue, MaxBatchSize at a time
+// Inputs:
+//     ctx cancels the calls not yet made and the one in progress
+//     sess is the current session, which provides configuration for the SDK's service clients
+//     queueURL is the URL of the queue
+//     messages are the envelopes to send
+//     groupID orders the messages of a FIFO queue, empty for standard queues
+//     deduplicationIDs has an id for each message of a FIFO queue, nil for standard queues;
+//         a FIFO message without id is deduplicated on its message id
+// Output:
+//     The messages that have not been sent, if any, and nil
+//     Otherwise, an error from the call to SendMessageBatch; the messages before the failed
+//     batch have been sent
+func SendMsgBatch(ctx context.Context, sess *session.Session, queueURL *string, messages []*envelope.Envelope, groupID string, deduplicationIDs []string) ([]BatchFailure, error) {
+	svc := sqs.New(sess)
+
+	var failures []BatchFailure
+	for start := 0; start < len(messages); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		var entries []*sqs.SendMessageBatchRequestEntry
+		for i := start; i < end; i++ {
+			body, attributes, err := messages[i].Encode()
+			if err != nil {
+				failures = append(failures, BatchFailure{Index: i, Code: "InvalidEnvelope", Message: err.Error(), SenderFault: true})
+				continue
+			}
+			entry := &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				MessageAttributes: attributes,
+				MessageBody:       aws.String(body),
+			}
+			if groupID != "" {
+				entry.MessageGroupId = aws.String(groupID)
+			}
+			if i < len(deduplicationIDs) && deduplicationIDs[i] != "" {
+				entry.MessageDeduplicationId = aws.String(deduplicationIDs[i])
+			} else if groupID != "" {
+				// the body holds a unique message id, the content based deduplication would never apply
+				entry.MessageDeduplicationId = aws.String(messages[i].MessageID)
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		result, err := svc.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+			Entries:  entries,
+			QueueUrl: queueURL,
+		})
+		if err != nil {
+			return failures, err
+		}
+		failures = append(failures, batchFailures(result.Failed)...)
+	}
+
+	return failures, nil
+}
+
+// DeleteMsgBatch deletes messages from an Amazon SQS queue, MaxBatchSize at a time
+// Inputs:
+//     ctx cancels the calls not yet made and the one in progress
+//     sess is the current session, which provides configuration for the SDK's service clients
+//     queueURL is the URL of the queue
+//     messageHandles are the receipt handles of the messages
+// Output:
+//     The messages that have not been deleted, if any, and nil
+//     Otherwise, an error from the call to DeleteMessageBatch; the messages before the failed
+//     batch have been deleted
+func DeleteMsgBatch(ctx context.Context, sess *session.Session, queueURL *string, messageHandles []*string) ([]BatchFailure, error) {
+	svc := sqs.New(sess)
+
+	var failures []BatchFailure
+	for start := 0; start < len(messageHandles); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(messageHandles) {
+			end = len(messageHandles)
+		}
+		var entries []*sqs.DeleteMessageBatchRequestEntry
+		for i := start; i < end; i++ {
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: messageHandles[i],
+			})
+		}
+
+		result, err := svc.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+			Entries:  entries,
+			QueueUrl: queueURL,
+		})
+		if err != nil {
+			return failures, err
+		}
+		failures = append(failures, batchFailures(result.Failed)...)
+	}
+
+	return failures, nil
+}
+
+func batchFailures(failed []*sqs.BatchResultErrorEntry) []BatchFailure {
+	var failures []BatchFailure
+	for _, f := range failed {
+		index, _ := strconv.Atoi(aws.StringValue(f.Id))
+		failures = append(failures, BatchFailure{
+			Index:       index,
+			Code:        aws.StringValue(f.Code),
+			Message:     aws.StringValue(f.Message),
+			SenderFault: aws.BoolValue(f.SenderFault),
+		})
+	}
+	return failures
+}
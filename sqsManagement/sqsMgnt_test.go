@@ -0,0 +1,190 @@
+package sqsManagement
+
+import (
+	"SDCC-A3-Project/awsFake"
+	"SDCC-A3-Project/envelope"
+	"SDCC-A3-Project/utilities"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var ctx = context.Background()
+
+// newQueue creates a queue of a new account held in memory
+func newQueue(t *testing.T, name string, settings *utilities.QueueSettings) (*awsFake.Cloud, string) {
+	cloud := awsFake.New()
+	result, _, err := CreateQueue(cloud.Session(), &name, settings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cloud, *result.QueueUrl
+}
+
+func count(t *testing.T, cloud *awsFake.Cloud, url string) int64 {
+	t.Helper()
+	n, err := CountMessages(cloud.Session(), &url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// an envelope sent again, e.g. after a timeout, is dropped by a FIFO queue
+func TestSendMsgBatchFifoRetry(t *testing.T) {
+	cloud, url := newQueue(t, "orders_eu.fifo", &utilities.QueueSettings{Fifo: true, ContentBasedDeduplication: true})
+	e := envelope.New("orders", "abc", "eu", "hello")
+	for i := 0; i < 2; i++ {
+		failures, err := SendMsgBatch(ctx, cloud.Session(), &url, []*envelope.Envelope{e}, "abc", nil)
+		if err != nil || len(failures) > 0 {
+			t.Fatalf("expected the message sent, got %v %v", failures, err)
+		}
+	}
+	if n := count(t, cloud, url); n != 1 {
+		t.Errorf("expected the retry dropped, got %d messages", n)
+	}
+
+	// same payload, another message
+	other := envelope.New("orders", "abc", "eu", "hello")
+	if _, err := SendMsgBatch(ctx, cloud.Session(), &url, []*envelope.Envelope{other}, "abc", nil); err != nil {
+		t.Fatal(err)
+	}
+	// the id of the caller wins
+	if _, err := SendMsgBatch(ctx, cloud.Session(), &url, []*envelope.Envelope{envelope.New("orders", "abc", "eu", "bye")}, "abc", []string{other.MessageID}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, cloud, url); n != 2 {
+		t.Errorf("expected 2 messages, got %d", n)
+	}
+}
+
+var fifoTests = []struct {
+	name         string
+	settings     *utilities.QueueSettings
+	fifo         string
+	contentBased string
+}{
+	{"news_eu", nil, "", ""},
+	{"news_eu", &utilities.QueueSettings{}, "", ""},
+	{"orders_eu.fifo", &utilities.QueueSettings{Fifo: true}, "true", "false"},
+	{"orders_eu.fifo", &utilities.QueueSettings{Fifo: true, ContentBasedDeduplication: true}, "true", "true"},
+}
+
+func TestCreateQueueFifo(t *testing.T) {
+	for _, test := range fifoTests {
+		cloud, url := newQueue(t, test.name, test.settings)
+		attributes, err := QueueAttributes(cloud.Session(), &url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fifo := attributes[sqs.QueueAttributeNameFifoQueue]; fifo != test.fifo {
+			t.Errorf("%s: expected FifoQueue %q, got %q", test.name, test.fifo, fifo)
+		}
+		if contentBased := attributes[sqs.QueueAttributeNameContentBasedDeduplication]; contentBased != test.contentBased {
+			t.Errorf("%s: expected ContentBasedDeduplication %q, got %q", test.name, test.contentBased, contentBased)
+		}
+		// the settings read back from the queue
+		qs := WithAttributes(utilities.DefaultQueueSettings(), attributes)
+		if test.settings != nil && (qs.Fifo != test.settings.Fifo || qs.ContentBasedDeduplication != test.settings.ContentBasedDeduplication) {
+			t.Errorf("%s: expected fifo %v content based %v, got %v %v", test.name, test.settings.Fifo, test.settings.ContentBasedDeduplication, qs.Fifo, qs.ContentBasedDeduplication)
+		}
+	}
+}
+
+// a queue created again with another configuration keeps the one of who created it
+func TestCreateQueueExisting(t *testing.T) {
+	cloud, url := newQueue(t, "orders_eu.fifo", &utilities.QueueSettings{Fifo: true, ContentBasedDeduplication: true})
+	name := "orders_eu.fifo"
+	result, existing, err := CreateQueue(cloud.Session(), &name, &utilities.QueueSettings{Fifo: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result.QueueUrl != url {
+		t.Errorf("expected %q, got %q", url, *result.QueueUrl)
+	}
+	if existing == nil || existing[sqs.QueueAttributeNameContentBasedDeduplication] != "true" {
+		t.Errorf("expected the attributes of the existing queue, got %v", existing)
+	}
+}
+
+// envelopes returns n envelopes, the ones at the positions in large too big for the queues below
+func envelopes(n int, large ...int) []*envelope.Envelope {
+	var list []*envelope.Envelope
+	for i := 0; i < n; i++ {
+		list = append(list, envelope.New("news", "abc", "eu", strconv.Itoa(i)))
+	}
+	for _, i := range large {
+		list[i].Payload = strings.Repeat("x", 2048)
+	}
+	return list
+}
+
+// the failed entries are reported with their position among all the messages, the others are sent
+func TestSendMsgBatchPartial(t *testing.T) {
+	cloud, url := newQueue(t, "news_eu", &utilities.QueueSettings{MaximumMessageSize: 1024})
+	failures, err := SendMsgBatch(ctx, cloud.Session(), &url, envelopes(12, 3, 11), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures[0].Index != 3 || failures[1].Index != 11 {
+		t.Fatalf("expected the messages 3 and 11 failed, got %v", failures)
+	}
+	if !failures[0].SenderFault || failures[0].Code == "" {
+		t.Errorf("expected a sender fault with its code, got %v", failures[0])
+	}
+	if calls := cloud.Calls("SendMessageBatch"); calls != 2 {
+		t.Errorf("expected 2 batches, got %d", calls)
+	}
+	if n := count(t, cloud, url); n != 10 {
+		t.Errorf("expected 10 messages, got %d", n)
+	}
+}
+
+func TestSendMsgBatchError(t *testing.T) {
+	cloud, url := newQueue(t, "news_eu", nil)
+	cloud.Fail("SendMessageBatch", awserr.New("AccessDenied", "Access to the resource is denied.", nil))
+	if _, err := SendMsgBatch(ctx, cloud.Session(), &url, envelopes(12), "", nil); err == nil {
+		t.Errorf("expected the error of the call")
+	}
+	// the batches after the failed one are not sent
+	if n := count(t, cloud, url); n != 0 {
+		t.Errorf("expected no message, got %d", n)
+	}
+}
+
+func TestDeleteMsgBatchPartial(t *testing.T) {
+	cloud, url := newQueue(t, "news_eu", nil)
+	if _, err := SendMsgBatch(ctx, cloud.Session(), &url, envelopes(12), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	var handles []*string
+	maxMessages, timeout, waitTime := int64(MaxBatchSize), int64(30), int64(0)
+	for len(handles) < 12 {
+		result, err := ReceiveMessages(ctx, cloud.Session(), &url, &maxMessages, &timeout, &waitTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Messages) == 0 {
+			t.Fatalf("expected 12 messages, got %d", len(handles))
+		}
+		for _, msg := range result.Messages {
+			handles = append(handles, msg.ReceiptHandle)
+		}
+	}
+	handles[2], handles[11] = aws.String("unknown"), aws.String("unknown")
+
+	failures, err := DeleteMsgBatch(ctx, cloud.Session(), &url, handles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures[0].Index != 2 || failures[1].Index != 11 {
+		t.Fatalf("expected the handles 2 and 11 failed, got %v", failures)
+	}
+	if n := cloud.Messages("news_eu"); n != 2 {
+		t.Errorf("expected 2 messages left, got %d", n)
+	}
+}
@@ -0,0 +1,165 @@
+package topics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Topic names are hierarchical, the levels are separated by Separator (e.g. sensors/rome/temp).
+// A subscription can use a pattern: SingleLevel matches exactly one level and MultiLevel,
+// allowed only as last level, matches any number of levels, none included.
+const (
+	Separator   = "/"
+	SingleLevel = "*"
+	MultiLevel  = "#"
+)
+
+// naming policy: the levels are made of letters, digits, '-', '_' and '.', not of dots only
+const (
+	MaxLength       = 256 // characters of a topic name
+	MaxSuffixLength = 9   // room left in the queue names for the suffixes of the dead-letter and FIFO queues ("_DLQ.fifo")
+	maxQueueName    = 80  // characters of a sqs queue name
+	hashLength      = 16  // hex digits of the hash ending a shortened queue name
+)
+
+// ReplyPrefix starts the names of the temporary reply queues, REPLY_<zone>_<id>: no topic queue
+// can start with it
+const ReplyPrefix = "REPLY_"
+
+// Validate checks that name is a well formed topic name or, if allowPattern, subscription pattern
+func Validate(name string, allowPattern bool) error {
+	if name == "" {
+		return errors.New("the topic name is empty")
+	}
+	if len(name) > MaxLength {
+		return errors.New(fmt.Sprintf("the topic name is longer than %d characters", MaxLength))
+	}
+	if strings.HasPrefix(Sanitize(name), ReplyPrefix) {
+		return errors.New("topic " + name + " starts with " + ReplyPrefix + ", reserved to the reply queues")
+	}
+	levels := strings.Split(name, Separator)
+	for i, level := range levels {
+		if level == "" {
+			return errors.New("empty level in topic " + name)
+		}
+		if strings.Trim(level, ".") == "" {
+			// "." and ".." would be taken as paths by the blob stores keyed by topic
+			return errors.New("level " + level + " made only of dots in topic " + name)
+		}
+		isWildcard := level == SingleLevel || level == MultiLevel
+		if !isWildcard && strings.ContainsAny(level, SingleLevel+MultiLevel) {
+			return errors.New("a wildcard must be a whole level in topic " + name)
+		}
+		if !isWildcard {
+			for _, c := range level {
+				if !isLegal(c) && c != '.' {
+					return errors.New(fmt.Sprintf("character %q not allowed in topic %s, use letters, digits, '-', '_' and '.'", c, name))
+				}
+			}
+		}
+		if isWildcard && !allowPattern {
+			return errors.New("wildcards are allowed only in subscriptions, topic " + name)
+		}
+		if level == MultiLevel && i != len(levels)-1 {
+			return errors.New(MultiLevel + " must be the last level in topic " + name)
+		}
+	}
+	return nil
+}
+
+// IsPattern tells whether name contains wildcards
+func IsPattern(name string) bool {
+	for _, level := range strings.Split(name, Separator) {
+		if level == SingleLevel || level == MultiLevel {
+			return true
+		}
+	}
+	return false
+}
+
+// Match tells whether topic is matched by pattern, a name without wildcards matches only itself
+func Match(pattern, topic string) bool {
+	p := strings.Split(pattern, Separator)
+	t := strings.Split(topic, Separator)
+	for i := 0; i < len(p); i++ {
+		if p[i] == MultiLevel {
+			return true
+		}
+		if i >= len(t) || (p[i] != SingleLevel && p[i] != t[i]) {
+			return false
+		}
+	}
+	return len(p) == len(t)
+}
+
+// isLegal tells whether c is allowed in a sqs queue name
+func isLegal(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// Sanitize turns a topic name into a string allowed in a sqs queue name: the separators become
+// '-' and every other character outside [A-Za-z0-9_-] becomes '_'
+func Sanitize(topic string) string {
+	var b strings.Builder
+	for _, c := range topic {
+		switch {
+		case isLegal(c):
+			b.WriteRune(c)
+		case string(c) == Separator:
+			b.WriteByte('-')
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// QueueName returns the name of the queue of the topic in zone, without suffixes.
+// The name depends only on topic and zone; when the sanitized name would leave no room for
+// MaxSuffixLength characters it is cut and ended with a hash of the topic, so that different
+// long topics sharing a prefix still get different queues.
+// Sanitizing is not injective (a/b and a-b give the same name): the caller must detect collisions.
+func QueueName(topic, zone string) string {
+	name := Sanitize(topic) + "_" + Sanitize(zone)
+	limit := maxQueueName - MaxSuffixLength
+	if len(name) <= limit {
+		return name
+	}
+	sum := sha256.Sum256([]byte(topic + "_" + zone))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	return name[:limit-hashLength-1] + "-" + hash
+}
+
+// ReplyQueuePrefix returns the start of the names of the reply queues of zone
+func ReplyQueuePrefix(zone string) string {
+	return ReplyPrefix + Sanitize(zone) + "_"
+}
+
+// the id ending a reply queue name is made of letters and digits
+var replyQueueName = regexp.MustCompile(`^` + ReplyPrefix + `[A-Za-z0-9_-]+_[A-Za-z0-9]+$`)
+
+// IsReplyQueueURL tells whether replyTo is the URL of a reply queue in the same account and
+// region of the queue at queueURL. The responders check it: replyTo comes from the requester.
+func IsReplyQueueURL(replyTo, queueURL string) bool {
+	reply, err := url.Parse(replyTo)
+	if err != nil {
+		return false
+	}
+	queue, err := url.Parse(queueURL)
+	if err != nil {
+		return false
+	}
+	// https://sqs.<region>.amazonaws.com/<account>/<name>
+	replyPath := strings.Split(strings.TrimPrefix(reply.Path, "/"), "/")
+	queuePath := strings.Split(strings.TrimPrefix(queue.Path, "/"), "/")
+	if reply.Scheme != queue.Scheme || reply.Host != queue.Host || reply.User != nil || reply.RawQuery != "" ||
+		len(replyPath) != 2 || len(queuePath) != 2 || replyPath[0] != queuePath[0] {
+		return false
+	}
+	return replyQueueName.MatchString(replyPath[1])
+}
@@ -0,0 +1,173 @@
+package topics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowPattern bool
+		valid        bool
+	}{
+		{"news", false, true},
+		{"sensors/rome/temp.c", false, true},
+		{"", false, false},
+		{"sensors//temp", false, false},
+		{"sensors/", false, false},
+		{"/sensors", false, false},
+		{"sensors/*", false, false},
+		{"sensors/#", false, false},
+		{"sensors/*/temp", true, true},
+		{"sensors/#", true, true},
+		{"#", true, true},
+		{"sensors/#/temp", true, false},
+		{"sensors/ro*", true, false},
+		{"sensors/#x", true, false},
+		{"sensors/rome temp", false, false},
+		{"città", false, false},
+		{strings.Repeat("a", MaxLength), false, true},
+		{strings.Repeat("a", MaxLength+1), false, false},
+		{"REPLY_EU_abc", false, false},
+		{"REPLY.EU", false, false},
+		{"REPLY/EU", false, true},
+		{"reply_EU_abc", false, true},
+		{".", false, false},
+		{"..", false, false},
+		{"sensors/../news", false, false},
+		{"sensors/./temp", false, false},
+		{"sensors/...", true, false},
+		{"../#", true, false},
+		{".hidden/temp.", false, true},
+		{"v1..2", false, true},
+	}
+
+	for _, test := range tests {
+		err := Validate(test.name, test.allowPattern)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid, got %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %q to be rejected (patterns allowed: %v)", test.name, test.allowPattern)
+		}
+	}
+}
+
+var matchVector = []struct {
+	pattern, topic string
+	match          bool
+}{
+	{"news", "news", true},
+	{"news", "news/sport", false},
+	{"news/sport", "news", false},
+	{"sensors/*/temp", "sensors/rome/temp", true},
+	{"sensors/*/temp", "sensors/rome/humidity", false},
+	{"sensors/*", "sensors/rome/temp", false},
+	{"*/temp", "temp", false},
+	{"sensors/#", "sensors", true},
+	{"sensors/#", "sensors/rome/temp", true},
+	{"sensors/#", "alarms/rome", false},
+	{"#", "anything/at/all", true},
+}
+
+func TestMatch(t *testing.T) {
+	for _, test := range matchVector {
+		if match := Match(test.pattern, test.topic); match != test.match {
+			t.Errorf("expected %q on %q to be %v, got %v", test.pattern, test.topic, test.match, match)
+		}
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	for _, name := range []string{"sensors/*", "sensors/#", "*/temp"} {
+		if !IsPattern(name) {
+			t.Errorf("expected %q to be a pattern", name)
+		}
+	}
+	// a wildcard inside a level is not a wildcard, Validate rejects it
+	for _, name := range []string{"sensors/rome", "sensors/ro*"} {
+		if IsPattern(name) {
+			t.Errorf("expected %q not to be a pattern", name)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"news", "news"},
+		{"sensors/rome/temp", "sensors-rome-temp"},
+		{"temp.c", "temp_c"},
+		{"città", "citt_"},
+		{"a_b-c", "a_b-c"},
+	}
+
+	for _, test := range tests {
+		if out := Sanitize(test.in); out != test.out {
+			t.Errorf("expected %q, got %q", test.out, out)
+		}
+	}
+}
+
+func TestQueueName(t *testing.T) {
+	if name := QueueName("sensors/rome", "EU"); name != "sensors-rome_EU" {
+		t.Errorf("expected %q, got %q", "sensors-rome_EU", name)
+	}
+}
+
+// long topics sharing a prefix get different names, short enough for the suffixes
+func TestQueueNameLong(t *testing.T) {
+	prefix := strings.Repeat("level/", 20)
+	a := QueueName(prefix+"a", "EU")
+	b := QueueName(prefix+"b", "EU")
+
+	if a == b {
+		t.Errorf("expected different names, got %q twice", a)
+	}
+	for _, name := range []string{a, b} {
+		if len(name)+MaxSuffixLength > maxQueueName {
+			t.Errorf("expected at most %d characters, got %d in %q", maxQueueName-MaxSuffixLength, len(name), name)
+		}
+	}
+	if a != QueueName(prefix+"a", "EU") {
+		t.Errorf("expected the name of a topic not to change")
+	}
+}
+
+func TestIsReplyQueueURL(t *testing.T) {
+	queue := "https://sqs.eu-west-1.amazonaws.com/123456789012/news_EU"
+	tests := []struct {
+		replyTo string
+		valid   bool
+	}{
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc123", true},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_US-east_abc123", true},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/news_EU", false},
+		{"https://sqs.eu-west-1.amazonaws.com/210987654321/REPLY_EU_abc123", false},
+		{"https://sqs.us-east-1.amazonaws.com/123456789012/REPLY_EU_abc123", false},
+		{"http://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc123", false},
+		{"https://user@sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc123", false},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc123?Action=DeleteQueue", false},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc123/x", false},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_EU_abc.123", false},
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/REPLY_abc123", false},
+		{"%zz", false},
+	}
+
+	for _, test := range tests {
+		if valid := IsReplyQueueURL(test.replyTo, queue); valid != test.valid {
+			t.Errorf("expected %v for %q, got %v", test.valid, test.replyTo, valid)
+		}
+	}
+	if IsReplyQueueURL(tests[0].replyTo, "%zz") {
+		t.Errorf("expected false for an invalid queue URL")
+	}
+}
+
+func TestReplyQueuePrefix(t *testing.T) {
+	if prefix := ReplyQueuePrefix("us.east"); prefix != "REPLY_us_east_" {
+		t.Errorf("expected %q, got %q", "REPLY_us_east_", prefix)
+	}
+}
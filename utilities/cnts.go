@@ -1,17 +1,172 @@
 package utilities
 
+import (
+	"SDCC-A3-Project/envelope"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"time"
+)
+
 const (
 	ServerPort        = 1234
 	Zone              = "Rome"
 	Attempts          = 10
 	VisibilityTimeOut = 20
+	MaxReceiveCount   = 5                 // deliveries of a message before it is moved to the dead-letter queue
+	HeartbeatInterval = 30 * time.Second  // how often a server announces itself to the others
+	PeerTimeout       = 150 * time.Second // a peer silent for longer than this is considered dead
+	ReaperInterval    = time.Minute       // how often the topics left empty are checked against their policy
+	SchedulerInterval = time.Second       // how often the scheduled messages are checked
+	MaxScheduleDelay  = 366 * 24 * 3600   // longest delay, in seconds, of a scheduled message
+	CronInterval      = 15 * time.Second  // how often the recurring publications are checked
+	ReplyQueueTTL     = 300               // default seconds a reply queue lives without being renewed
+	MaxReplyQueueTTL  = 12 * 3600         // longest lease of a reply queue
+	MaxReplay         = 100               // records returned at most by a Replay
 )
 
 type RequestArg struct {
-	ID  string // id of the user
-	Tag string //queue tag (name of the topic)
+	ID          string         // id of the user
+	Tag         string         //queue tag (name of the topic)
+	Settings    *QueueSettings // queue configuration, used only when the topic is created
+	Filter      string         // filter policy of the subscription, empty to receive every message
+	Description string         // description of the topic, used only when the topic is created
 }
 
 type SubscriptionOutput struct {
-	QueueURL string //
+	QueueURL string         //
+	Settings QueueSettings  // configuration of the queue of the topic
+	Retained []*sqs.Message // last value of the topics subscribed retaining it, not to be deleted
+}
+
+type ReceiveArg struct {
+	ID                string // id of the user
+	Tag               string // name of the topic or pattern
+	MaxMessages       int64  // at most 10
+	VisibilityTimeout int64  // seconds the returned messages are hidden to the other subscribers
+}
+
+type MessagesOutput struct {
+	Messages  []*sqs.Message // messages passing the filter of the subscription, to be deleted by the receiver
+	QueueURLs []string       // queue of each message, they differ when receiving with a pattern
+}
+
+// delivery modes of a topic
+const (
+	StandardDelivery = "standard" // at least once, best effort ordering
+	FifoDelivery     = "fifo"     // exactly once, ordered by message group
+)
+
+type TopicInfo struct {
+	Name         string
+	Description  string
+	Owner        string    // user whose subscription created the topic, empty if unknown
+	CreationTime time.Time // when this server created the topic or found its queue
+	DeliveryMode string    // StandardDelivery or FifoDelivery
+	Subscribers  int       // subscriptions on this server, patterns included
+	Publishers   int       // publishers registered on this server
+	Zone         string
+}
+
+type ScheduleArg struct {
+	ID              string            // id of the user
+	Tag             string            // topic the message is published on
+	Message         envelope.Envelope // already packed, it is sent as it is
+	DeliverAt       time.Time         // when the message must be delivered, if zero now plus Delay
+	Delay           int64             // seconds before the message is delivered
+	GroupID         string            // FIFO topics only
+	DeduplicationID string            // FIFO topics only
+	ScheduleID      string            // message to cancel, returned by ScheduleMessage
+}
+
+type CronArg struct {
+	ID          string            // id of the user
+	Tag         string            // topic the messages are published on
+	Schedule    string            // cron expression, evaluated in UTC
+	Template    string            // text/template of the payload, see cronJobs.TemplateData
+	ContentType string            // content type of the payload, default text/plain
+	Headers     map[string]string // headers of every message
+	KeyID       string            // master key encrypting the payloads, none if empty
+	JobID       string            // job to delete, returned by RegisterCronJob
+}
+
+type CronJob struct {
+	ID           string            `json:"id"`
+	Owner        string            `json:"owner"` // user id of who registered the job, the only one who can delete it
+	Topic        string            `json:"topic"`
+	Schedule     string            `json:"schedule"`
+	Template     string            `json:"template"`
+	ContentType  string            `json:"content_type"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Zone         string            `json:"zone"`                  // the job is run by one of the servers of this zone
+	Compression  string            `json:"compression,omitempty"` // of the topic, applied to the payloads
+	KeyID        string            `json:"key_id,omitempty"`      // master key encrypting the payloads, none if empty
+	CreationTime time.Time         `json:"creation_time"`
+	LastRun      time.Time         `json:"-"` // last run published, kept apart by the store
+}
+
+type ReplyQueueArg struct {
+	ID   string // id of the user
+	Name string // reply queue to renew or delete, returned by CreateReplyQueue
+	TTL  int64  // seconds the queue lives unless renewed, default ReplyQueueTTL
+}
+
+type ReplyQueueOutput struct {
+	Name     string
+	QueueURL string    // where the responses are sent, the reply-to of the requests
+	Expires  time.Time // when the queue is deleted unless renewed
+}
+
+type ReplayArg struct {
+	ID          string    // id of the user
+	Tag         string    // topic to replay
+	Offset      int64     // first record to return, used when Since is zero
+	Since       time.Time // if not zero, replay the messages retained from then on
+	MaxMessages int64     // records to return at most, default and at most MaxReplay
+}
+
+type ReplayOutput struct {
+	Messages   []*sqs.Message // as they have been delivered, without receipt handle
+	Offsets    []int64        // offset of each message in the delivery log of the server
+	NextOffset int64          // where to continue from
+}
+
+// RetainedMessage is the last value of a topic, replicated to the servers of its zone
+type RetainedMessage struct {
+	Topic      string                                `json:"topic"`
+	Zone       string                                `json:"zone"`
+	MessageID  string                                `json:"message_id"`
+	Time       time.Time                             `json:"time"` // when the message entered the topic, the newest wins
+	Body       string                                `json:"body"`
+	Attributes map[string]*sqs.MessageAttributeValue `json:"attributes,omitempty"`
+	PayloadRef string                                `json:"payload_ref,omitempty"` // copy of the stored payload, deleted with the value
+}
+
+// SubscriptionFilter is the filter of a subscription, replicated to the servers of its zone:
+// a message is discarded only if no subscriber of the zone wants it
+type SubscriptionFilter struct {
+	Zone    string `json:"zone"`
+	ID      string `json:"id"`     // id of the user
+	Tag     string `json:"tag"`    // topic or pattern subscribed
+	Filter  string `json:"filter"` // policy of the filter
+	Removed bool   `json:"removed,omitempty"`
+}
+
+type SearchArg struct {
+	ID           string // id of the user
+	Query        string // text to look for in the name or description of the topics
+	Owner        string // if not empty, only the topics created by this user
+	DeliveryMode string // if not empty, only the topics with this delivery mode
+}
+
+type ServerInfo struct {
+	Zone      string    // zone the server belongs to
+	Address   string    // external ip address of the server
+	Port      int       // rpc port number
+	StartTime time.Time // when the server has been started
+	LastSeen  time.Time // last heartbeat received (local clock)
+}
+
+type DeadLetterOutput struct {
+	QueueURL string   // url of the dead-letter queue
+	Count    int64    // approximate number of messages in the dead-letter queue
+	Messages []string // body of some of the messages, still in the queue
 }
@@ -0,0 +1,14 @@
+package utilities
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// NewSession returns a session that gets the credential values from ~/.aws/credentials
+// and the default region from ~/.aws/config.
+// The tests replace it to talk with services held in memory.
+var NewSession = func() *session.Session {
+	return session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+}
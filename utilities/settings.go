@@ -0,0 +1,142 @@
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// QueueSettings is the configuration of the queue of a topic, chosen when the topic is created.
+// A zero field takes the default value, a nil one for the fields that can be set to 0.
+type QueueSettings struct {
+	DelaySeconds           int64 `json:"delay_seconds" yaml:"delay_seconds" toml:"delay_seconds"`                                  // delivery delay of every message, 0-900
+	MessageRetentionPeriod int64 `json:"message_retention_period" yaml:"message_retention_period" toml:"message_retention_period"` // seconds a message is kept, 60-1209600
+	MaximumMessageSize     int64 `json:"maximum_message_size" yaml:"maximum_message_size" toml:"maximum_message_size"`             // bytes, 1024-262144
+	// nil takes the default value, 0 is a valid setting
+	VisibilityTimeout *int64 `json:"visibility_timeout" yaml:"visibility_timeout" toml:"visibility_timeout"` // seconds a received message is hidden to the others, 0-43200
+	ReceiveWaitTime   *int64 `json:"receive_wait_time" yaml:"receive_wait_time" toml:"receive_wait_time"`    // seconds a receive waits for a message, 0-20
+	// FIFO queues deliver in order the messages of the same group, exactly once
+	Fifo                      bool `json:"fifo" yaml:"fifo" toml:"fifo"`
+	ContentBasedDeduplication bool `json:"content_based_deduplication" yaml:"content_based_deduplication" toml:"content_based_deduplication"` // deduplication id computed from the body, FIFO only
+	// compression applied by the producers to the payloads: "", "gzip" or "zstd"
+	Compression string `json:"compression" yaml:"compression" toml:"compression"`
+	// what happens to the topic when its last subscriber leaves
+	EmptyPolicy string `json:"empty_policy" yaml:"empty_policy" toml:"empty_policy"` // KeepTopic, GraceTopic or DeleteTopic (default)
+	GracePeriod int64  `json:"grace_period" yaml:"grace_period" toml:"grace_period"` // seconds an empty topic is kept with GraceTopic
+	// the messages delivered by a server are kept in its delivery log of the topic, to be replayed:
+	// it is not a log of the topic, each server logs only its deliveries, with its own offsets
+	Retain         bool  `json:"retain" yaml:"retain" toml:"retain"`
+	RetentionTime  int64 `json:"retention_time" yaml:"retention_time" toml:"retention_time"`    // seconds a message is kept in the log, 0 for no limit
+	RetentionBytes int64 `json:"retention_bytes" yaml:"retention_bytes" toml:"retention_bytes"` // size of the log above which the oldest messages go, 0 for no limit
+	// the newest message entered in the topic, as seen by the servers, is delivered to every new subscriber
+	RetainLast bool `json:"retain_last" yaml:"retain_last" toml:"retain_last"`
+}
+
+// policies for the topics left without subscribers
+const (
+	KeepTopic          = "keep"   // the topic stays until DeleteTopic
+	GraceTopic         = "grace"  // the topic is deleted if still empty after GracePeriod
+	DeleteTopic        = "delete" // the topic is deleted at once, unread messages included
+	DefaultGracePeriod = 3600
+)
+
+// DefaultQueueSettings delivers messages as soon as possible and keeps them for a day,
+// the topic is deleted when its last subscriber leaves
+func DefaultQueueSettings() QueueSettings {
+	return QueueSettings{
+		DelaySeconds:           0,
+		MessageRetentionPeriod: 86400,
+		MaximumMessageSize:     262144,
+		VisibilityTimeout:      Int64(VisibilityTimeOut),
+		ReceiveWaitTime:        Int64(20),
+		EmptyPolicy:            DeleteTopic,
+		GracePeriod:            DefaultGracePeriod,
+	}
+}
+
+// WithDefaults returns a copy of qs where the zero, or nil, fields are replaced by the default ones
+func (qs QueueSettings) WithDefaults() QueueSettings {
+	def := DefaultQueueSettings()
+	if qs.MessageRetentionPeriod == 0 {
+		qs.MessageRetentionPeriod = def.MessageRetentionPeriod
+	}
+	if qs.MaximumMessageSize == 0 {
+		qs.MaximumMessageSize = def.MaximumMessageSize
+	}
+	if qs.VisibilityTimeout == nil {
+		qs.VisibilityTimeout = def.VisibilityTimeout
+	}
+	if qs.ReceiveWaitTime == nil {
+		qs.ReceiveWaitTime = def.ReceiveWaitTime
+	}
+	if qs.EmptyPolicy == "" {
+		qs.EmptyPolicy = def.EmptyPolicy
+	}
+	if qs.GracePeriod == 0 {
+		qs.GracePeriod = def.GracePeriod
+	}
+	return qs
+}
+
+// Validate checks the settings against the limits of sqs
+func (qs QueueSettings) Validate() error {
+	if err := checkRange("delay_seconds", qs.DelaySeconds, 0, 900); err != nil {
+		return err
+	}
+	if err := checkRange("message_retention_period", qs.MessageRetentionPeriod, 60, 1209600); err != nil {
+		return err
+	}
+	if err := checkRange("maximum_message_size", qs.MaximumMessageSize, 1024, 262144); err != nil {
+		return err
+	}
+	if qs.VisibilityTimeout != nil {
+		if err := checkRange("visibility_timeout", *qs.VisibilityTimeout, 0, 43200); err != nil {
+			return err
+		}
+	}
+	if qs.Compression != "" && qs.Compression != "gzip" && qs.Compression != "zstd" {
+		return errors.New("compression must be gzip or zstd, got " + qs.Compression)
+	}
+	if qs.EmptyPolicy != KeepTopic && qs.EmptyPolicy != GraceTopic && qs.EmptyPolicy != DeleteTopic {
+		return errors.New("empty_policy must be keep, grace or delete, got " + qs.EmptyPolicy)
+	}
+	if qs.GracePeriod < 0 {
+		return errors.New("grace_period must not be negative")
+	}
+	if qs.RetentionTime < 0 || qs.RetentionBytes < 0 {
+		return errors.New("retention_time and retention_bytes must not be negative")
+	}
+	if !qs.Retain && (qs.RetentionTime != 0 || qs.RetentionBytes != 0) {
+		return errors.New("retention_time and retention_bytes require retain")
+	}
+	if qs.ContentBasedDeduplication && !qs.Fifo {
+		return errors.New("content_based_deduplication requires a fifo topic")
+	}
+	if qs.ReceiveWaitTime != nil {
+		return checkRange("receive_wait_time", *qs.ReceiveWaitTime, 0, 20)
+	}
+	return nil
+}
+
+// GobEncode sends the settings as JSON: gob leaves out the pointers to 0, they would arrive as nil
+func (qs QueueSettings) GobEncode() ([]byte, error) {
+	type plain QueueSettings // without the gob methods
+	return json.Marshal(plain(qs))
+}
+
+func (qs *QueueSettings) GobDecode(b []byte) error {
+	type plain QueueSettings
+	return json.Unmarshal(b, (*plain)(qs))
+}
+
+// Int64 returns a pointer to n, to fill the optional fields of the settings
+func Int64(n int64) *int64 {
+	return &n
+}
+
+func checkRange(name string, value, min, max int64) error {
+	if value < min || value > max {
+		return errors.New(fmt.Sprintf("%s must be between %d and %d, got %d", name, min, max, value))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestDefaultQueueSettingsValid(t *testing.T) {
+	if err := DefaultQueueSettings().Validate(); err != nil {
+		t.Errorf("expected the default settings to be valid, got %v", err)
+	}
+}
+
+func TestWithDefaultsKeepsSetFields(t *testing.T) {
+	qs := QueueSettings{DelaySeconds: 5, MessageRetentionPeriod: 60}.WithDefaults()
+	def := DefaultQueueSettings()
+
+	if qs.DelaySeconds != 5 || qs.MessageRetentionPeriod != 60 {
+		t.Errorf("expected the fields set to be kept, got %+v", qs)
+	}
+	if qs.MaximumMessageSize != def.MaximumMessageSize || *qs.VisibilityTimeout != *def.VisibilityTimeout ||
+		*qs.ReceiveWaitTime != *def.ReceiveWaitTime || qs.EmptyPolicy != def.EmptyPolicy || qs.GracePeriod != def.GracePeriod {
+		t.Errorf("expected the zero fields to take the defaults %+v, got %+v", def, qs)
+	}
+}
+
+// 0 is a setting of its own for the visibility timeout and the wait time
+func TestWithDefaultsKeepsZero(t *testing.T) {
+	qs := QueueSettings{VisibilityTimeout: Int64(0), ReceiveWaitTime: Int64(0)}.WithDefaults()
+	if *qs.VisibilityTimeout != 0 || *qs.ReceiveWaitTime != 0 {
+		t.Errorf("expected a zero visibility timeout and wait time, got %d and %d", *qs.VisibilityTimeout, *qs.ReceiveWaitTime)
+	}
+}
+
+// the settings travel by RPC: a zero must not arrive as a missing field
+func TestGobKeepsZero(t *testing.T) {
+	var buf bytes.Buffer
+	sent := map[string]QueueSettings{
+		"a": {VisibilityTimeout: Int64(0), Fifo: true},
+		"b": {},
+	}
+	if err := gob.NewEncoder(&buf).Encode(sent); err != nil {
+		t.Fatal(err)
+	}
+	var received map[string]QueueSettings
+	if err := gob.NewDecoder(&buf).Decode(&received); err != nil {
+		t.Fatal(err)
+	}
+
+	a := received["a"]
+	if a.VisibilityTimeout == nil || *a.VisibilityTimeout != 0 || a.ReceiveWaitTime != nil || !a.Fifo {
+		t.Errorf("expected %+v, got %+v", sent["a"], a)
+	}
+	if b := received["b"]; b.VisibilityTimeout != nil || b.ReceiveWaitTime != nil {
+		t.Errorf("expected the fields not set to stay nil, got %+v", b)
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	tests := []struct {
+		settings QueueSettings
+		valid    bool
+	}{
+		{QueueSettings{DelaySeconds: 900}, true},
+		{QueueSettings{DelaySeconds: 901}, false},
+		{QueueSettings{DelaySeconds: -1}, false},
+		{QueueSettings{MessageRetentionPeriod: 1209600}, true},
+		{QueueSettings{MessageRetentionPeriod: 59}, false},
+		{QueueSettings{MaximumMessageSize: 1024}, true},
+		{QueueSettings{MaximumMessageSize: 262145}, false},
+		{QueueSettings{VisibilityTimeout: Int64(0)}, true},
+		{QueueSettings{VisibilityTimeout: Int64(43201)}, false},
+		{QueueSettings{ReceiveWaitTime: Int64(0)}, true},
+		{QueueSettings{ReceiveWaitTime: Int64(21)}, false},
+		{QueueSettings{Fifo: true, ContentBasedDeduplication: true}, true},
+		{QueueSettings{ContentBasedDeduplication: true}, false},
+		{QueueSettings{Compression: "zstd"}, true},
+		{QueueSettings{Compression: "lz4"}, false},
+		{QueueSettings{EmptyPolicy: DeleteTopic}, true},
+		{QueueSettings{EmptyPolicy: "forever"}, false},
+		{QueueSettings{GracePeriod: -1}, false},
+		{QueueSettings{Retain: true, RetentionTime: 3600, RetentionBytes: 1 << 20}, true},
+		{QueueSettings{Retain: true, RetentionTime: -1}, false},
+		{QueueSettings{RetentionBytes: 1024}, false},
+	}
+
+	for _, test := range tests {
+		err := test.settings.WithDefaults().Validate()
+		if test.valid && err != nil {
+			t.Errorf("expected %+v to be valid, got %v", test.settings, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %+v to be rejected", test.settings)
+		}
+	}
+}